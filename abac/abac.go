@@ -0,0 +1,131 @@
+// Package abac 基于属性的访问控制
+//
+// 规则由 Go 谓词组合而成，可读取当前 Principal、session item、请求属性，
+// 以及由 ResourceLoader 按路由参数加载的资源属性：
+//
+//	rule := abac.Any(
+//		abac.Owner("owner_id"),
+//		abac.All(abac.HasRole("support"), abac.StateIs(1)),
+//	)
+//	r.PUT("/tickets/:id", abac.Mw(rule, loadTicket), handler)
+//
+// 拒绝时记录可读的原因，如 "(not owner of resource and not role support)"。
+package abac
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+	"github.com/mulan-ext/auth/session"
+)
+
+// CtxKeyResult gin.Context 中保存求值结果的key
+const CtxKeyResult = "github.com/mulan-ext/auth/abac/result"
+
+// ErrResourceNotFound ResourceLoader 返回该错误时响应 404
+var ErrResourceNotFound = errors.New("abac: resource not found")
+
+// ResourceLoader 按请求属性（通常是路由参数）加载资源属性
+type ResourceLoader func(ctx context.Context, attrs *Attributes) (map[string]any, error)
+
+// Evaluate 加载资源并对规则求值，loader 可为 nil
+func Evaluate(ctx context.Context, rule Rule, attrs *Attributes, loader ResourceLoader) (Result, error) {
+	if loader != nil {
+		resource, err := loader(ctx, attrs)
+		if err != nil {
+			return Result{}, err
+		}
+		attrs.Resource = resource
+	}
+	return rule(attrs), nil
+}
+
+// Mw 授权中间件，需挂在认证中间件之后
+//
+// 拒绝时未认证返回 401，否则返回 403；资源不存在返回 404，加载失败返回 500。
+func Mw(rule Rule, loader ...ResourceLoader) gin.HandlerFunc {
+	load := first(loader)
+	return func(c *gin.Context) {
+		attrs := newAttributes(c.Request)
+		attrs.param = c.Param
+		result, status := decide(c.Request, rule, attrs, load)
+		c.Set(CtxKeyResult, result)
+		if status != http.StatusOK {
+			problem.Abort(c, problem.ForStatus(c.Request, status))
+			return
+		}
+		c.Next()
+	}
+}
+
+// Middleware net/http 版本的 Mw，路由参数取自 r.PathValue
+func Middleware(rule Rule, loader ...ResourceLoader) func(http.Handler) http.Handler {
+	load := first(loader)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attrs := newAttributes(r)
+			attrs.param = r.PathValue
+			if _, status := decide(r, rule, attrs, load); status != http.StatusOK {
+				problem.Write(w, r, problem.ForStatus(r, status))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// decide 求值并返回结果与状态码，拒绝原因写入日志
+func decide(r *http.Request, rule Rule, attrs *Attributes, loader ResourceLoader) (Result, int) {
+	result, err := Evaluate(r.Context(), rule, attrs, loader)
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+		return result, http.StatusNotFound
+	case err != nil:
+		zap.L().Error("abac resource loader failed", zap.String("path", attrs.Path), zap.Error(err))
+		return result, http.StatusInternalServerError
+	case result.Allow:
+		return result, http.StatusOK
+	}
+	fields := []zap.Field{
+		zap.String("method", attrs.Method),
+		zap.String("path", attrs.Path),
+		zap.String("reason", result.Reason),
+	}
+	if attrs.Principal != nil {
+		fields = append(fields, zap.Uint64("id", attrs.Principal.ID))
+	}
+	zap.L().Info("abac denied", fields...)
+	if attrs.Principal == nil {
+		return result, http.StatusUnauthorized
+	}
+	return result, http.StatusForbidden
+}
+
+// newAttributes 从请求提取 Principal、session item 与请求属性
+func newAttributes(r *http.Request) *Attributes {
+	attrs := &Attributes{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header,
+	}
+	if p, ok := principal.FromContext(r.Context()); ok {
+		attrs.Principal = p
+	}
+	if sess, ok := session.FromContext(r.Context()); ok && !sess.IsNil {
+		attrs.Items = sess.Data().Items()
+	}
+	return attrs
+}
+
+func first(loaders []ResourceLoader) ResourceLoader {
+	if len(loaders) > 0 {
+		return loaders[0]
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package abac_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/abac"
+	"github.com/mulan-ext/auth/principal"
+)
+
+var tickets = map[string]map[string]any{
+	"1": {"owner_id": float64(7), "status": "open"},
+	"2": {"owner_id": float64(8), "status": "closed"},
+}
+
+func loadTicket(_ context.Context, attrs *abac.Attributes) (map[string]any, error) {
+	ticket, ok := tickets[attrs.Param("id")]
+	if !ok {
+		return nil, abac.ErrResourceNotFound
+	}
+	return ticket, nil
+}
+
+// 资源所有者，或状态为 1 的 support 角色
+var ticketRule = abac.Any(
+	abac.Owner("owner_id"),
+	abac.All(abac.HasRole("support"), abac.StateIs(1)),
+)
+
+func TestRuleReasons(t *testing.T) {
+	attrs := &abac.Attributes{
+		Principal: &principal.Principal{ID: 9, Roles: []string{"support"}, State: 2},
+		Resource:  tickets["1"],
+	}
+	result := ticketRule(attrs)
+	if result.Allow || result.Reason != "(not owner of resource and not state 1)" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	attrs.Principal.ID = 7
+	if result = ticketRule(attrs); !result.Allow || result.Reason != "owner of resource" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result = abac.Not(abac.ResourceEquals("status", "closed"))(attrs); !result.Allow {
+		t.Fatalf("Not: %+v", result)
+	}
+	if result = abac.ItemEquals("level", 3)(&abac.Attributes{Items: map[string]any{"level": float64(3)}}); !result.Allow {
+		t.Fatalf("numeric item comparison after JSON round trip: %+v", result)
+	}
+}
+
+func TestMw(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		switch c.GetHeader("X-User") {
+		case "owner":
+			c.Request = c.Request.WithContext(principal.NewContext(c.Request.Context(), &principal.Principal{ID: 7}))
+		case "support":
+			c.Request = c.Request.WithContext(principal.NewContext(c.Request.Context(), &principal.Principal{ID: 9, Roles: []string{"support"}, State: 1}))
+		case "other":
+			c.Request = c.Request.WithContext(principal.NewContext(c.Request.Context(), &principal.Principal{ID: 9}))
+		}
+	})
+	router.PUT("/tickets/:id", abac.Mw(ticketRule, loadTicket), func(c *gin.Context) {
+		result := c.MustGet(abac.CtxKeyResult).(abac.Result)
+		c.String(http.StatusOK, result.Reason)
+	})
+
+	cases := []struct {
+		user, id string
+		want     int
+	}{
+		{"owner", "1", http.StatusOK},
+		{"owner", "2", http.StatusForbidden},
+		{"support", "2", http.StatusOK},
+		{"other", "1", http.StatusForbidden},
+		{"", "1", http.StatusUnauthorized},
+		{"owner", "404", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/tickets/"+tc.id, nil)
+		req.Header.Set("X-User", tc.user)
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("user %q ticket %s: got %d want %d", tc.user, tc.id, w.Code, tc.want)
+		}
+	}
+}
+
+func TestMiddlewarePathValue(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", abac.Middleware(abac.ParamMatchesID("id"))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	for path, want := range map[string]int{"/users/7": http.StatusNoContent, "/users/8": http.StatusForbidden} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(principal.NewContext(req.Context(), &principal.Principal{ID: 7}))
+		mux.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: got %d want %d", path, w.Code, want)
+		}
+	}
+}
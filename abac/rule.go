@@ -0,0 +1,197 @@
+package abac
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mulan-ext/auth/principal"
+)
+
+// Attributes 规则求值时可用的属性
+type Attributes struct {
+	Principal *principal.Principal // 未认证时为 nil
+	Items     map[string]any       // 当前session的item
+	Method    string
+	Path      string
+	Header    http.Header
+	Resource  map[string]any // 由 ResourceLoader 加载，未配置时为 nil
+	param     func(string) string
+}
+
+// Param 返回路由参数，gin 取自 c.Params，net/http 取自 r.PathValue
+func (a *Attributes) Param(name string) string {
+	if a.param == nil {
+		return ""
+	}
+	return a.param(name)
+}
+
+// Result 求值结果，Reason 说明允许或拒绝的原因，用于日志
+type Result struct {
+	Allow  bool
+	Reason string
+}
+
+// Rule 授权规则
+type Rule func(*Attributes) Result
+
+// Predicate 以命名谓词构造规则，拒绝原因为 "not <name>"
+func Predicate(name string, fn func(*Attributes) bool) Rule {
+	return func(a *Attributes) Result {
+		if fn(a) {
+			return Result{Allow: true, Reason: name}
+		}
+		return Result{Reason: "not " + name}
+	}
+}
+
+// All 全部规则通过时允许，拒绝原因为第一个未通过的规则
+func All(rules ...Rule) Rule {
+	return func(a *Attributes) Result {
+		reasons := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			r := rule(a)
+			if !r.Allow {
+				return r
+			}
+			reasons = append(reasons, r.Reason)
+		}
+		return Result{Allow: true, Reason: join(reasons, " and ")}
+	}
+}
+
+// Any 任一规则通过时允许，拒绝原因列出全部未通过的规则
+func Any(rules ...Rule) Rule {
+	return func(a *Attributes) Result {
+		reasons := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			r := rule(a)
+			if r.Allow {
+				return r
+			}
+			reasons = append(reasons, r.Reason)
+		}
+		return Result{Reason: join(reasons, " and ")}
+	}
+}
+
+// Not 取反
+func Not(rule Rule) Rule {
+	return func(a *Attributes) Result {
+		r := rule(a)
+		return Result{Allow: !r.Allow, Reason: "not (" + r.Reason + ")"}
+	}
+}
+
+func join(reasons []string, sep string) string {
+	if len(reasons) == 1 {
+		return reasons[0]
+	}
+	return "(" + strings.Join(reasons, sep) + ")"
+}
+
+// Authenticated 已认证
+func Authenticated() Rule {
+	return Predicate("authenticated", func(a *Attributes) bool { return a.Principal != nil })
+}
+
+// HasRole 拥有任一角色
+func HasRole(roles ...string) Rule {
+	return Predicate("role "+strings.Join(roles, "|"), func(a *Attributes) bool {
+		return a.Principal != nil && slices.ContainsFunc(roles, a.Principal.HasRole)
+	})
+}
+
+// StateIs 用户状态等于 state
+func StateIs(state uint16) Rule {
+	return Predicate(fmt.Sprintf("state %d", state), func(a *Attributes) bool {
+		return a.Principal != nil && a.Principal.State == state
+	})
+}
+
+// Owner 资源属性 field 等于当前用户ID
+func Owner(field string) Rule {
+	return Predicate("owner of resource", func(a *Attributes) bool {
+		if a.Principal == nil || a.Principal.ID == 0 {
+			return false
+		}
+		id, ok := toUint64(a.Resource[field])
+		return ok && id == a.Principal.ID
+	})
+}
+
+// ItemEquals session item key 等于 value
+func ItemEquals(key string, value any) Rule {
+	return Predicate(fmt.Sprintf("item %s=%v", key, value), func(a *Attributes) bool {
+		return equal(a.Items[key], value)
+	})
+}
+
+// ResourceEquals 资源属性 field 等于 value
+func ResourceEquals(field string, value any) Rule {
+	return Predicate(fmt.Sprintf("resource %s=%v", field, value), func(a *Attributes) bool {
+		return equal(a.Resource[field], value)
+	})
+}
+
+// ParamMatchesID 路由参数 name 等于当前用户ID，如 /users/:id
+func ParamMatchesID(name string) Rule {
+	return Predicate("param "+name+" is self", func(a *Attributes) bool {
+		return a.Principal != nil && a.Principal.ID != 0 && a.Param(name) == strconv.FormatUint(a.Principal.ID, 10)
+	})
+}
+
+// HeaderEquals 请求头 name 等于 value
+func HeaderEquals(name, value string) Rule {
+	return Predicate("header "+name+"="+value, func(a *Attributes) bool {
+		return a.Header.Get(name) == value
+	})
+}
+
+// MethodIn 请求方法属于 methods
+func MethodIn(methods ...string) Rule {
+	return Predicate("method "+strings.Join(methods, "|"), func(a *Attributes) bool {
+		return slices.Contains(methods, a.Method)
+	})
+}
+
+// equal 比较属性值，数字按数值比较以兼容JSON往返后的 float64
+func equal(got, want any) bool {
+	if g, ok := toNumber(got); ok {
+		if w, ok := toNumber(want); ok {
+			return g == w
+		}
+	}
+	return reflect.DeepEqual(got, want)
+}
+
+// toUint64 转换ID类属性，接受数字与十进制字符串
+func toUint64(v any) (uint64, bool) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.ParseUint(s, 10, 64)
+		return n, err == nil
+	}
+	return toNumber(v)
+}
+
+func toNumber(v any) (uint64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return v, true
+	case uint:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case int:
+		return uint64(v), v >= 0
+	case int64:
+		return uint64(v), v >= 0
+	case float64:
+		return uint64(v), v >= 0 && v == float64(uint64(v))
+	}
+	return 0, false
+}
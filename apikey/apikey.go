@@ -1,18 +1,46 @@
 package apikey
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/credential"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+	"github.com/mulan-ext/auth/session"
+	"github.com/mulan-ext/rdb"
 )
 
 type Config struct {
 	Name   string   `json:"name" yaml:"name"`
 	Value  string   `json:"value" yaml:"value"`
 	Values []string `json:"values" yaml:"values"`
+	// Hashes key 哈希，格式为 "[id:]hash"，hash 支持 sha256$<hex>、argon2id(PHC) 与 bcrypt，
+	// argon2id/bcrypt 必须带 ID，可由 GenerateKey 生成
+	Hashes []string `json:"hashes" yaml:"hashes"`
+	// Driver KeyStore 驱动，为空时只使用上述静态配置，见 NewKeyStore
+	Driver string     `json:"driver" yaml:"driver"`
+	File   string     `json:"file" yaml:"file"`
+	RDB    rdb.Config `json:"rdb" yaml:"rdb"`
+	// Sources key来源，按顺序检查，格式见 credential.Parse，为空时使用 DefaultExtractors
+	Sources []string `json:"sources" yaml:"sources"`
 }
 
+// KeyStore 内置驱动名称
+const (
+	DriverMemory = "memory"
+	DriverFile   = "file"
+	DriverRedis  = "rdb"
+)
+
 func (c *Config) FlagSet() *pflag.FlagSet { return FlagSet() }
 
 func FlagSet() *pflag.FlagSet {
@@ -20,41 +48,308 @@ func FlagSet() *pflag.FlagSet {
 	fs.String("apikey.name", "apikey", "APIKey Name")
 	fs.String("apikey.value", "", "APIKey Value")
 	fs.StringSlice("apikey.values", []string{}, "APIKey Value List")
+	fs.StringSlice("apikey.hashes", []string{}, "APIKey Hash List ([id:]sha256$<hex>, argon2id or bcrypt)")
+	fs.StringSlice("apikey.sources", []string{}, "APIKey sources in order (header:<name>, bearer, scheme:<scheme>, cookie:<name>, query:<name>, form:<name>)")
+	fs.String("apikey.driver", "", "APIKey store driver (memory, file, rdb), empty for static keys only")
+	// driver file
+	fs.String("apikey.file", "", "APIKey store JSON file")
+	// driver redis
+	fs.String("apikey.rdb.host", "127.0.0.1", "APIKey store rdb host")
+	fs.String("apikey.rdb.pass", "", "APIKey store rdb pass")
+	fs.Int("apikey.rdb.port", 6379, "APIKey store rdb port")
+	fs.Int("apikey.rdb.db", 0, "APIKey store rdb db")
+	fs.Bool("apikey.rdb.debug", false, "APIKey store rdb debug")
 	return fs
 }
 
-func Mw(cfg *Config) func(*gin.Context) {
-	apikeys := map[string]struct{}{}
-	if cfg.Value != "" {
-		apikeys[cfg.Value] = struct{}{}
+func (c *Config) Validate() error {
+	if c == nil {
+		return errors.New("apikey: config is nil")
+	}
+	if name := strings.TrimSpace(c.Name); name != "" {
+		if err := (&http.Cookie{Name: name, Value: "apikey"}).Valid(); err != nil {
+			return fmt.Errorf("apikey: invalid name: %w", err)
+		}
+	}
+	if !validKey(c.Value) {
+		return errors.New("apikey: value contains whitespace or control characters")
+	}
+	for i, key := range c.Values {
+		if !validKey(key) {
+			return fmt.Errorf("apikey: values[%d] contains whitespace or control characters", i)
+		}
+	}
+	if _, err := newKeyring(c.Hashes); err != nil {
+		return err
 	}
-	for _, key := range cfg.Values {
-		if key = strings.TrimSpace(key); key != "" {
-			apikeys[key] = struct{}{}
+	if _, err := credential.ParseChain(c.Sources); err != nil {
+		return fmt.Errorf("apikey: %w", err)
+	}
+	switch c.Driver {
+	case "", DriverMemory:
+	case DriverFile:
+		if strings.TrimSpace(c.File) == "" {
+			return errors.New("apikey: file is required for the file driver")
+		}
+	case DriverRedis:
+		if strings.TrimSpace(c.RDB.Host) == "" {
+			return errors.New("apikey: rdb.host is required for the rdb driver")
+		}
+		if c.RDB.Port <= 0 || c.RDB.Port > 65535 {
+			return errors.New("apikey: rdb.port is invalid")
 		}
+		if c.RDB.DB < 0 {
+			return errors.New("apikey: rdb.db cannot be negative")
+		}
+	default:
+		return fmt.Errorf("apikey: unknown driver %q", c.Driver)
 	}
-	if len(apikeys) == 0 {
-		return func(c *gin.Context) { c.Next() }
+	return nil
+}
+
+// NewKeyStore 根据配置创建 KeyStore，未配置驱动时返回 nil
+func NewKeyStore(cfg *Config) (KeyStore, error) {
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case DriverMemory:
+		return NewMemKeyStore(), nil
+	case DriverFile:
+		return NewFileKeyStore(cfg.File)
+	case DriverRedis:
+		client, err := rdb.New(&cfg.RDB)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisKeyStore(client)
+	default:
+		return nil, fmt.Errorf("apikey: unknown driver %q", cfg.Driver)
 	}
-	name := strings.TrimSpace(cfg.Name)
-	if name == "" {
-		name = "apikey"
+}
+
+// validKey 检查去除首尾空白后的key是否可通过Header传输
+func validKey(key string) bool {
+	return !strings.ContainsFunc(strings.TrimSpace(key), func(r rune) bool { return r <= ' ' || r == 0x7f })
+}
+
+// keys 将配置中的明文key与哈希合并为 keyring，明文key在启动时转换为 SHA-256
+func (c *Config) keys() (*keyring, error) {
+	k, err := newKeyring(c.Hashes)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	for _, key := range append([]string{c.Value}, c.Values...) {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		hash, _ := HashKey(key, SHA256)
+		h, _ := parseHash(hash)
+		_ = k.add(h)
+	}
+	return k, nil
+}
+
+// Option Mw 可选项
+type Option func(*options)
+
+type options struct {
+	store      KeyStore
+	source     *FileSource
+	extractors credential.Chain
+}
+
+// WithStore 从 KeyStore 校验带ID的key，通过时将key的所有者、角色写入 Principal
+func WithStore(store KeyStore) Option {
+	return func(o *options) { o.store = store }
+}
+
+// WithSource 同时校验可重新加载的 FileSource 中的key，文件为空时拒绝全部请求
+func WithSource(source *FileSource) Option {
+	return func(o *options) { o.source = source }
+}
+
+// WithExtractor 代码中配置的key来源，可包含 credential.Func，优先于 Config.Sources
+func WithExtractor(extractors ...credential.Extractor) Option {
+	return func(o *options) { o.extractors = extractors }
+}
+
+// DefaultExtractors 默认的key来源：name 请求头、Cookie name、ApiKey 与 Bearer 方案的 Authorization
+func DefaultExtractors(name string) credential.Chain {
+	return credential.Chain{
+		credential.Header(name),
+		credential.Cookie(name),
+		credential.Scheme("ApiKey"),
+		credential.Bearer(),
+	}
+}
+
+// Init 创建 API Key 中间件，配置的哈希或 Sources 无效时返回错误
+func Init(cfg *Config, opts ...Option) (gin.HandlerFunc, error) {
+	authenticate, err := newAuthenticator(cfg, opts...)
+	if err != nil {
+		return nil, err
 	}
 	return func(c *gin.Context) {
-		current := strings.TrimSpace(c.GetHeader(name))
-		if current == "" {
-			current, _ = c.Cookie(name)
-			current = strings.TrimSpace(current)
+		r, ok := authenticate(c.Request)
+		if !ok {
+			problem.Abort(c, unauthorized())
+			return
 		}
-		if current == "" {
-			current = strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		c.Request = r
+		if p, ok := principal.FromContext(r.Context()); ok {
+			// 静态key与 KeyStore 中的key使用同一规则，net/http 与 gin 的 AuthMW 结果一致
+			session.PopulatePrincipal(c, p)
 		}
-		for apikey := range apikeys {
-			if apikey == current {
-				c.Next()
+		c.Next()
+	}, nil
+}
+
+// InitMiddleware net/http 版本的 Init
+func InitMiddleware(cfg *Config, opts ...Option) (func(http.Handler) http.Handler, error) {
+	authenticate, err := newAuthenticator(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, ok := authenticate(r)
+			if !ok {
+				problem.Write(w, r, unauthorized())
 				return
 			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// Mw API Key 中间件，key通过时与session一样填充 CtxKeyID、CtxKeyAccount、CtxKeyRoles 等，
+// 可直接配合 session.AuthMW、session.RoleMW 使用
+//
+// 配置无效时记录错误并以 500 拒绝全部请求，需要在启动时发现配置错误请使用 Init。
+func Mw(cfg *Config, opts ...Option) func(*gin.Context) {
+	mw, err := Init(cfg, opts...)
+	if err != nil {
+		zap.L().Error("apikey: invalid config, rejecting all requests", zap.Error(err))
+		return func(c *gin.Context) { problem.Abort(c, problem.ForStatus(c.Request, http.StatusInternalServerError)) }
+	}
+	return mw
+}
+
+// Middleware net/http 版本的 Mw
+func Middleware(cfg *Config, opts ...Option) func(http.Handler) http.Handler {
+	mw, err := InitMiddleware(cfg, opts...)
+	if err != nil {
+		zap.L().Error("apikey: invalid config, rejecting all requests", zap.Error(err))
+		return func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				problem.Write(w, r, problem.ForStatus(r, http.StatusInternalServerError))
+			})
+		}
+	}
+	return mw
+}
+
+type labelCtxKey struct{}
+
+// LabelFromContext 获取当前请求通过校验的静态配置key的标识，带ID的哈希为ID，其余为key的摘要（audit.TokenID）
+func LabelFromContext(ctx context.Context) (string, bool) {
+	label, ok := ctx.Value(labelCtxKey{}).(string)
+	return label, ok && label != ""
+}
+
+// withStatic 静态配置的key无法声明 scope，拥有全部 scope，应只用于受信任的内部调用方
+func withStatic(r *http.Request, id, key string) *http.Request {
+	if id == "" {
+		id = audit.TokenID(key)
+	}
+	ctx := context.WithValue(r.Context(), labelCtxKey{}, id)
+	return r.WithContext(principal.NewContext(ctx, &principal.Principal{
+		Scopes: []string{"*"},
+		Method: principal.MethodAPIKey,
+	}))
+}
+
+func unauthorized() *problem.Problem {
+	return problem.New(http.StatusUnauthorized, "invalid_api_key").WithChallenge(problem.APIKey())
+}
+
+// newAuthenticator 返回校验请求API Key的框架无关实现，通过时返回携带 Principal 的请求
+func newAuthenticator(cfg *Config, opts ...Option) (func(*http.Request) (*http.Request, bool), error) {
+	if cfg == nil {
+		return nil, errors.New("apikey: config is nil")
+	}
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	keys, err := cfg.keys()
+	if err != nil {
+		return nil, err
+	}
+	var stored *storeVerifier
+	if o.store != nil {
+		stored = &storeVerifier{store: o.store}
+	}
+	if keys.count == 0 && stored == nil && o.source == nil {
+		return func(r *http.Request) (*http.Request, bool) { return r, true }, nil
+	}
+	chain := o.extractors
+	if len(chain) == 0 {
+		if chain, err = credential.ParseChain(cfg.Sources); err != nil {
+			return nil, fmt.Errorf("apikey: %w", err)
+		}
+	}
+	if len(chain) == 0 {
+		name := strings.TrimSpace(cfg.Name)
+		if name == "" {
+			name = "apikey"
 		}
-		c.AbortWithStatus(401)
+		chain = DefaultExtractors(name)
 	}
+	return func(r *http.Request) (*http.Request, bool) {
+		current, _, err := chain.Extract(r)
+		var reason string
+		switch {
+		case err != nil:
+			// 多个来源携带不同的key，不猜测调用方意图
+			reason = "conflict"
+		case current == "":
+			reason = "missing"
+		case !ValidChecksum(current):
+			// 校验码错误的key无需查询存储
+			reason = "malformed"
+		default:
+			reason = "invalid"
+			if stored != nil {
+				k, why := stored.verify(r.Context(), current)
+				if k != nil {
+					return withKey(r, k), true
+				}
+				if why != "" {
+					reason = why
+				}
+			}
+			if reason == "invalid" {
+				id, ok := keys.verify(current)
+				if !ok && o.source != nil {
+					id, ok = o.source.verify(current)
+				}
+				if ok {
+					return withStatic(r, id, current), true
+				}
+			}
+		}
+		event := audit.Event{Type: audit.APIKeyRejected, Method: principal.MethodAPIKey, Reason: reason}
+		if current != "" {
+			// 只记录摘要，不记录原始 key
+			event.Fields = map[string]any{"key": audit.TokenID(current)}
+		}
+		audit.Emit(r.Context(), event)
+		return r, false
+	}, nil
 }
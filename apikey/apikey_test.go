@@ -3,17 +3,26 @@ package apikey_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/mulan-ext/auth/apikey"
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/credential"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
 )
 
 func newRouter(cfg *apikey.Config) *gin.Engine {
+	return newRouterWith(apikey.Mw(cfg))
+}
+
+func newRouterWith(mw gin.HandlerFunc) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	r.Use(apikey.Mw(cfg))
+	r.Use(mw)
 	r.GET("/protected", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
@@ -68,6 +77,12 @@ func TestMw_RejectsRequestWhenKeyMissing(t *testing.T) {
 	if w.Code != http.StatusUnauthorized {
 		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
+	if got := w.Header().Get("WWW-Authenticate"); got != "ApiKey" {
+		t.Fatalf("unexpected challenge %q", got)
+	}
+	if !strings.Contains(w.Body.String(), `"error":"invalid_api_key"`) {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
 }
 
 func TestMw_AllowsRequestWithCustomHeader(t *testing.T) {
@@ -127,6 +142,20 @@ func TestMw_AllowsRequestWithValuesList(t *testing.T) {
 	}
 }
 
+func TestMw_AllowsStaticKeyWithUnderscore(t *testing.T) {
+	r := newRouter(&apikey.Config{
+		Name:   "X-API-Key",
+		Values: []string{"my_key.v1", "sk_live.abc"},
+	})
+
+	for _, key := range []string{"my_key.v1", "sk_live.abc"} {
+		w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", key, http.StatusOK, w.Code)
+		}
+	}
+}
+
 func TestMw_RejectsRequestWithWrongKey(t *testing.T) {
 	r := newRouter(&apikey.Config{
 		Name:   "X-API-Key",
@@ -161,3 +190,177 @@ func TestMw_TrimSpacesFromHeaderAndCookie(t *testing.T) {
 		t.Fatalf("expected cookie auth status %d, got %d", http.StatusOK, w.Code)
 	}
 }
+
+func TestConfigValidate(t *testing.T) {
+	valid := []*apikey.Config{
+		{},
+		{Name: "X-API-Key", Value: " secret-key ", Values: []string{"", "other-key"}},
+	}
+	for _, cfg := range valid {
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("config %+v: unexpected error %v", cfg, err)
+		}
+	}
+	invalid := []*apikey.Config{
+		nil,
+		{Name: "X API Key"},
+		{Value: "secret key"},
+		{Values: []string{"ok", "bad\tkey"}},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("config %+v: expected validation error", cfg)
+		}
+	}
+}
+
+func TestMw_PopulatesPrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apikey.Mw(&apikey.Config{Name: "X-API-Key", Value: "secret-key"}))
+	r.GET("/protected", func(c *gin.Context) {
+		p, ok := principal.FromContext(c.Request.Context())
+		if !ok {
+			c.String(http.StatusOK, "missing")
+			return
+		}
+		c.String(http.StatusOK, p.Method)
+	})
+
+	w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": "secret-key"}, nil)
+	if w.Body.String() != principal.MethodAPIKey {
+		t.Fatalf("expected principal method %q, got %q", principal.MethodAPIKey, w.Body.String())
+	}
+}
+
+func TestMiddleware_NetHTTP(t *testing.T) {
+	handler := apikey.Middleware(&apikey.Config{Name: "X-API-Key", Value: "secret-key"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := principal.FromContext(r.Context()); !ok {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	if w := performRequest(handler, http.MethodGet, "/", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	w := performRequest(handler, http.MethodGet, "/", map[string]string{"Authorization": "Bearer secret-key"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestStaticKeyAuthConsistent(t *testing.T) {
+	cfg := &apikey.Config{Name: "X-API-Key", Value: "secret-key"}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apikey.Mw(cfg), session.AuthMW())
+	r.GET("/protected", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	handler := apikey.Middleware(cfg)(session.AuthMiddleware()(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })))
+
+	for name, h := range map[string]http.Handler{"gin": r, "net/http": handler} {
+		w := performRequest(h, http.MethodGet, "/protected", map[string]string{"X-API-Key": "secret-key"}, nil)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: static key rejected by AuthMW: %d", name, w.Code)
+		}
+	}
+}
+
+func TestStaticKeyHasAllScopes(t *testing.T) {
+	cfg := &apikey.Config{Name: "X-API-Key", Value: "secret-key"}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apikey.Mw(cfg))
+	r.GET("/reports", session.RequireScope("read:reports"), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	handler := apikey.Middleware(cfg)(session.RequireScopeMiddleware("read:reports")(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })))
+
+	for name, h := range map[string]http.Handler{"gin": r, "net/http": handler} {
+		w := performRequest(h, http.MethodGet, "/reports", map[string]string{"X-API-Key": "secret-key"}, nil)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: static key rejected by RequireScope: %d", name, w.Code)
+		}
+	}
+}
+
+func TestInitInvalidConfig(t *testing.T) {
+	for _, cfg := range []*apikey.Config{
+		{Hashes: []string{"sha256$zz"}},
+		{Value: "secret-key", Sources: []string{"unknown:X"}},
+	} {
+		if _, err := apikey.Init(cfg); err == nil {
+			t.Errorf("Init(%+v): expected error", cfg)
+		}
+		if _, err := apikey.InitMiddleware(cfg); err == nil {
+			t.Errorf("InitMiddleware(%+v): expected error", cfg)
+		}
+		// Mw 不再 panic，而是拒绝全部请求
+		w := performRequest(newRouter(cfg), http.MethodGet, "/health", nil, nil)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Mw(%+v): got %d", cfg, w.Code)
+		}
+	}
+}
+
+func TestMw_AuditsRejection(t *testing.T) {
+	ch := make(chan audit.Event, 2)
+	audit.SetDefault(audit.NewLogger(audit.NewChanSink(ch)))
+	defer audit.SetDefault(nil)
+	r := newRouter(&apikey.Config{Name: "X-API-Key", Value: "secret-key"})
+
+	performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": "guessed-key"}, nil)
+	performRequest(r, http.MethodGet, "/protected", nil, nil)
+	invalid, missing := <-ch, <-ch
+	if invalid.Type != audit.APIKeyRejected || invalid.Reason != "invalid" || invalid.Fields["key"] != audit.TokenID("guessed-key") {
+		t.Fatalf("unexpected event %+v", invalid)
+	}
+	if missing.Reason != "missing" || missing.Fields != nil {
+		t.Fatalf("unexpected event %+v", missing)
+	}
+}
+
+func TestMw_Sources(t *testing.T) {
+	ch := make(chan audit.Event, 2)
+	audit.SetDefault(audit.NewLogger(audit.NewChanSink(ch)))
+	defer audit.SetDefault(nil)
+
+	r := newRouter(&apikey.Config{Name: "X-API-Key", Value: "secret-key"})
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"apikey scheme", map[string]string{"Authorization": "ApiKey secret-key"}, http.StatusOK},
+		{"basic scheme is not a key", map[string]string{"Authorization": "Basic secret-key"}, http.StatusUnauthorized},
+		{"same key in two sources", map[string]string{"X-API-Key": "secret-key", "Authorization": "Bearer secret-key"}, http.StatusOK},
+		{"conflicting keys", map[string]string{"X-API-Key": "secret-key", "Authorization": "Bearer other-key"}, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if w := performRequest(r, http.MethodGet, "/protected", tc.headers, nil); w.Code != tc.want {
+			t.Errorf("%s: got %d want %d", tc.name, w.Code, tc.want)
+		}
+	}
+	<-ch
+	if event := <-ch; event.Reason != "conflict" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	r = newRouter(&apikey.Config{Value: "secret-key", Sources: []string{"query:api_key"}})
+	if w := performRequest(r, http.MethodGet, "/protected?api_key=secret-key", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("query source: got %d", w.Code)
+	}
+	if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"apikey": "secret-key"}, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("unconfigured header source: got %d", w.Code)
+	}
+
+	r = newRouterWith(apikey.Mw(&apikey.Config{Value: "secret-key"}, apikey.WithExtractor(
+		credential.Func("custom", func(r *http.Request) string { return r.Header.Get("X-Custom") }),
+	)))
+	if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-Custom": "secret-key"}, nil); w.Code != http.StatusOK {
+		t.Errorf("custom source: got %d", w.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+	"github.com/mulan-ext/auth/session"
+)
+
+// CreateRequest 自助创建key的请求体
+type CreateRequest struct {
+	Name      string    `json:"name" binding:"required,max=64"`
+	Roles     []string  `json:"roles"`               // 必须是当前用户拥有的角色，默认无角色
+	Scopes    []string  `json:"scopes"`              // 默认无 scope
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // 与 ExpiresIn 二选一
+	ExpiresIn int       `json:"expires_in"`          // 有效期秒数
+}
+
+// CreateResponse 自助创建key的响应，Key 只返回这一次
+type CreateResponse struct {
+	Secret string `json:"key"`
+	*Key
+}
+
+// ListHandler 列出当前用户在当前租户内的key，需挂在 session.Mw 之后
+func (m *Manager) ListHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess, ok := m.owner(c)
+		if !ok {
+			return
+		}
+		keys, err := m.Owned(c.Request.Context(), sess.ID(), sess.Tenant())
+		if err != nil {
+			m.abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// CreateHandler 为当前用户签发key，需挂在 session.Mw 之后
+func (m *Manager) CreateHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess, ok := m.owner(c)
+		if !ok {
+			return
+		}
+		var req CreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Abort(c, problem.New(http.StatusBadRequest, "invalid_request").WithDetail(err.Error()))
+			return
+		}
+		// 按当前租户内生效的角色比较，其他租户的角色不能授予
+		owned := session.RolesFor(sess.Roles(), sess.Tenant())
+		for _, role := range req.Roles {
+			granted := session.RolesFor([]string{role}, sess.Tenant())
+			if len(granted) == 0 || !slices.Contains(owned, granted[0]) {
+				problem.Abort(c, problem.New(http.StatusForbidden, "insufficient_role").WithDetail("cannot grant role "+role))
+				return
+			}
+		}
+		k := &Key{
+			UserID:    sess.ID(),
+			Account:   sess.Account(),
+			Name:      strings.TrimSpace(req.Name),
+			Roles:     req.Roles,
+			Scopes:    req.Scopes,
+			Tenant:    sess.Tenant(),
+			ExpiresAt: req.ExpiresAt,
+		}
+		if req.ExpiresIn > 0 {
+			k.ExpiresAt = m.now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		}
+		key, err := m.Issue(c.Request.Context(), k)
+		if err != nil {
+			m.abort(c, err)
+			return
+		}
+		k.Hash = ""
+		c.JSON(http.StatusCreated, CreateResponse{Secret: key, Key: k})
+	}
+}
+
+// RevokeHandler 吊销当前用户的key，key ID 取自路由参数 param（默认 "id"），需挂在 session.Mw 之后
+func (m *Manager) RevokeHandler(param ...string) gin.HandlerFunc {
+	name := "id"
+	if len(param) > 0 && param[0] != "" {
+		name = param[0]
+	}
+	return func(c *gin.Context) {
+		sess, ok := m.owner(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		k, err := m.store.Get(ctx, c.Param(name))
+		// 不属于当前用户的key与不存在的key响应一致
+		if err == nil && (k.UserID != sess.ID() || k.Tenant != sess.Tenant()) {
+			err = ErrKeyNotFound
+		}
+		if err == nil {
+			err = m.Revoke(ctx, k.ID)
+		}
+		if err != nil {
+			m.abort(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// owner 返回已登录的 session；API Key 认证或代为操作时拒绝，避免凭证自我繁殖
+func (m *Manager) owner(c *gin.Context) (*session.Session, bool) {
+	sess, ok := session.FromGin(c)
+	p, _ := principal.FromContext(c.Request.Context())
+	if !ok || sess.IsNil || sess.ID() == 0 || p == nil || p.Method == principal.MethodAPIKey {
+		problem.Abort(c, problem.Unauthenticated(c.Request))
+		return nil, false
+	}
+	if p.Impersonated() {
+		problem.Abort(c, problem.New(http.StatusForbidden, "impersonation_forbidden"))
+		return nil, false
+	}
+	return sess, true
+}
+
+func (m *Manager) abort(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+		problem.Abort(c, problem.New(http.StatusNotFound, "key_not_found"))
+	case errors.Is(err, ErrTooManyKeys):
+		problem.Abort(c, problem.New(http.StatusConflict, "too_many_keys"))
+	case errors.Is(err, ErrKeyExpired):
+		problem.Abort(c, problem.New(http.StatusBadRequest, "invalid_request").WithDetail("expiry must be in the future"))
+	case errors.Is(err, ErrInvalidScope):
+		problem.Abort(c, problem.New(http.StatusBadRequest, "invalid_request").WithDetail(err.Error()))
+	default:
+		zap.L().Error("apikey: self-service request failed", zap.Error(err))
+		problem.Abort(c, problem.ForStatus(c.Request, http.StatusInternalServerError))
+	}
+}
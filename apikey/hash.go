@@ -0,0 +1,308 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm API Key 哈希算法
+type Algorithm string
+
+const (
+	// SHA256 适用于 GenerateKey 生成的高熵key，校验开销最小
+	SHA256 Algorithm = "sha256"
+	// Argon2id 适用于人工设置的低熵key，首次校验后缓存 SHA-256 摘要
+	Argon2id Algorithm = "argon2id"
+	// Bcrypt 同 Argon2id
+	Bcrypt Algorithm = "bcrypt"
+)
+
+// argon2id 参数，参考 OWASP 建议：19 MiB、2 次迭代、1 线程
+const (
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2KeyLen  = 32
+)
+
+// idLen GenerateKey 生成的key ID长度
+const idLen = 8
+
+// GenerateKey 生成新的 API Key 及其在 Config.Hashes 中的配置项
+//
+// key 形如 "<id>.<secret>"，只展示给调用方一次；entry 形如 "<id>:sha256$<hex>"，写入配置。
+func GenerateKey(alg Algorithm) (key, entry string, err error) {
+	id, key, hash, err := newKey("", alg)
+	if err != nil {
+		return "", "", err
+	}
+	return key, id + ":" + hash, nil
+}
+
+// newKey 生成key，prefix 非空时格式为 "<prefix>_<id>.<secret>_<checksum>"，见 ValidChecksum
+func newKey(prefix string, alg Algorithm) (id, key, hash string, err error) {
+	id = strings.ToLower(rand.Text()[:idLen])
+	key = id + "." + rand.Text()
+	if prefix != "" {
+		key = prefix + "_" + key
+		key += "_" + checksum(key)
+	}
+	hash, err = HashKey(key, alg)
+	return id, key, hash, err
+}
+
+// checksum 返回 CRC32 校验码（8 位十六进制）
+func checksum(s string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(s)))
+}
+
+// ValidChecksum 检查 Manager 签发的key的校验码，可在查询存储前拒绝输错或伪造的key
+//
+// 只检查 "<prefix>_<id>.<secret>_<checksum>" 格式的key，其他key（包括 "sk_live.abc" 等含 "_" 的静态key）总是返回 true。
+func ValidChecksum(key string) bool {
+	body, sum, ok := checksummed(key)
+	if !ok {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(checksum(body)), []byte(sum)) == 1
+}
+
+// checksummed 按 newKey 的格式拆分出校验内容与校验码
+func checksummed(key string) (body, sum string, ok bool) {
+	i := strings.LastIndexByte(key, '_')
+	if i < 0 || !isChecksum(key[i+1:]) {
+		return "", "", false
+	}
+	body, sum = key[:i], key[i+1:]
+	prefix, rest, _ := strings.Cut(body, "_")
+	id, secret, found := strings.Cut(rest, ".")
+	if prefix == "" || !validPrefix(prefix) || len(id) != idLen || !validID(id) ||
+		!found || secret == "" || strings.ContainsAny(secret, "._") {
+		return "", "", false
+	}
+	return body, sum, true
+}
+
+func isChecksum(s string) bool {
+	return len(s) == 8 && !strings.ContainsFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && (r < 'a' || r > 'f')
+	})
+}
+
+// validPrefix 前缀只允许小写字母与数字，便于密钥扫描工具识别
+func validPrefix(prefix string) bool {
+	return len(prefix) <= 16 && !strings.ContainsFunc(prefix, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+}
+
+// HashKey 计算 key 的哈希，格式为 "sha256$<hex>"、PHC 格式的 argon2id 或 bcrypt
+func HashKey(key string, alg Algorithm) (string, error) {
+	switch alg {
+	case SHA256, "":
+		sum := sha256.Sum256([]byte(key))
+		return "sha256$" + hex.EncodeToString(sum[:]), nil
+	case Argon2id:
+		salt := make([]byte, 16)
+		_, _ = rand.Read(salt)
+		sum := argon2.IDKey([]byte(key), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(sum)), nil
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.DefaultCost)
+		return string(hash), err
+	default:
+		return "", fmt.Errorf("apikey: unsupported algorithm %q", alg)
+	}
+}
+
+// KeyID 返回 key 中 "." 之前的ID部分（不含前缀），不含ID时返回空
+func KeyID(key string) string {
+	id, _, ok := strings.Cut(key, ".")
+	if _, after, prefixed := strings.Cut(id, "_"); prefixed {
+		id = after
+	}
+	if !ok || !validID(id) {
+		return ""
+	}
+	return id
+}
+
+// validID key ID 不能包含分隔符
+func validID(id string) bool {
+	return id != "" && len(id) <= 64 && !strings.ContainsAny(id, ":$._")
+}
+
+// hashedKey 一个已配置的key哈希
+type hashedKey struct {
+	id     string
+	raw    string // 原始哈希，用于判断 KeyStore 中的key是否已轮换
+	verify func(key string) bool
+	// slow 为 argon2id/bcrypt 时，校验通过后缓存 key 的 SHA-256 摘要
+	slow   bool
+	mu     sync.Mutex
+	digest []byte
+}
+
+// parseHash 解析 "[id:]hash" 配置项
+func parseHash(entry string) (*hashedKey, error) {
+	entry = strings.TrimSpace(entry)
+	id, hash := "", entry
+	if before, after, ok := strings.Cut(entry, ":"); ok {
+		id, hash = before, after
+	}
+	if id != "" && !validID(id) {
+		return nil, fmt.Errorf("apikey: invalid key ID %q", id)
+	}
+	h := &hashedKey{id: id, raw: hash}
+	switch {
+	case strings.HasPrefix(hash, "sha256$"):
+		want, err := hex.DecodeString(strings.TrimPrefix(hash, "sha256$"))
+		if err != nil || len(want) != sha256.Size {
+			return nil, errors.New("apikey: invalid sha256 hash")
+		}
+		h.digest = want
+		h.verify = func(key string) bool {
+			sum := sha256.Sum256([]byte(key))
+			return subtle.ConstantTimeCompare(sum[:], want) == 1
+		}
+	case strings.HasPrefix(hash, "$argon2id$"):
+		verify, err := parseArgon2id(hash)
+		if err != nil {
+			return nil, err
+		}
+		h.verify, h.slow = verify, true
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("apikey: invalid bcrypt hash: %w", err)
+		}
+		h.verify = func(key string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte(key)) == nil
+		}
+		h.slow = true
+	default:
+		return nil, errors.New("apikey: unsupported hash format, want sha256$<hex>, $argon2id$... or bcrypt")
+	}
+	if h.slow && id == "" {
+		return nil, errors.New("apikey: argon2id and bcrypt hashes require a key ID")
+	}
+	return h, nil
+}
+
+func parseArgon2id(hash string) (func(string) bool, error) {
+	var (
+		version        int
+		memory, passes uint32
+		threads        uint8
+	)
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, errors.New("apikey: invalid argon2id hash")
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.New("apikey: unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &passes, &threads); err != nil || passes == 0 || threads == 0 {
+		return nil, errors.New("apikey: invalid argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, errors.New("apikey: invalid argon2id salt")
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return nil, errors.New("apikey: invalid argon2id hash")
+	}
+	return func(key string) bool {
+		sum := argon2.IDKey([]byte(key), salt, passes, memory, threads, uint32(len(want)))
+		return subtle.ConstantTimeCompare(sum, want) == 1
+	}, nil
+}
+
+// match 校验 key，慢哈希校验通过后缓存摘要，之后以常量时间比较摘要
+func (h *hashedKey) match(key string) bool {
+	if !h.slow {
+		return h.verify(key)
+	}
+	sum := sha256.Sum256([]byte(key))
+	h.mu.Lock()
+	digest := h.digest
+	h.mu.Unlock()
+	if digest != nil {
+		return subtle.ConstantTimeCompare(sum[:], digest) == 1
+	}
+	if !h.verify(key) {
+		return false
+	}
+	h.mu.Lock()
+	h.digest = sum[:]
+	h.mu.Unlock()
+	return true
+}
+
+// keyring 已配置的全部key，按ID查找
+type keyring struct {
+	byID  map[string]*hashedKey
+	noID  []*hashedKey
+	count int
+}
+
+func newKeyring(hashes []string) (*keyring, error) {
+	k := &keyring{byID: make(map[string]*hashedKey)}
+	for i, entry := range hashes {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		h, err := parseHash(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w (hashes[%d])", err, i)
+		}
+		if err := k.add(h); err != nil {
+			return nil, fmt.Errorf("%w (hashes[%d])", err, i)
+		}
+	}
+	return k, nil
+}
+
+func (k *keyring) add(h *hashedKey) error {
+	if h.id == "" {
+		k.noID = append(k.noID, h)
+	} else {
+		if _, dup := k.byID[h.id]; dup {
+			return fmt.Errorf("apikey: duplicate key ID %q", h.id)
+		}
+		k.byID[h.id] = h
+	}
+	k.count++
+	return nil
+}
+
+// verify 按key ID查找并校验，通过时返回匹配哈希的ID；ID未配置或校验失败时依次以常量时间比较
+// 不带ID的 SHA-256 哈希，此时返回的ID为空，明文配置的key可能恰好带有与其他哈希相同的ID
+func (k *keyring) verify(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	if h, ok := k.byID[KeyID(key)]; ok && h.match(key) {
+		return h.id, true
+	}
+	matched := false
+	for _, h := range k.noID {
+		// 不提前返回，耗时与匹配位置无关
+		if h.match(key) {
+			matched = true
+		}
+	}
+	return "", matched
+}
@@ -0,0 +1,85 @@
+package apikey_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mulan-ext/auth/apikey"
+)
+
+func TestMw_AcceptsHashedKeys(t *testing.T) {
+	for _, alg := range []apikey.Algorithm{apikey.SHA256, apikey.Argon2id, apikey.Bcrypt} {
+		t.Run(string(alg), func(t *testing.T) {
+			key, entry, err := apikey.GenerateKey(alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(entry, key) || !strings.HasPrefix(entry, apikey.KeyID(key)+":") {
+				t.Fatalf("unexpected entry %q for key %q", entry, key)
+			}
+			cfg := &apikey.Config{Name: "X-API-Key", Hashes: []string{entry}}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			r := newRouter(cfg)
+			// 第二次命中缓存摘要
+			for range 2 {
+				if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil); w.Code != http.StatusOK {
+					t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+				}
+			}
+			forged := apikey.KeyID(key) + ".forged"
+			if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": forged}, nil); w.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+			}
+		})
+	}
+}
+
+func TestMw_AcceptsHashWithoutID(t *testing.T) {
+	hash, err := apikey.HashKey("legacy-key", apikey.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := apikey.HashKey("other-key", apikey.SHA256)
+	r := newRouter(&apikey.Config{Name: "X-API-Key", Hashes: []string{other, hash}, Values: []string{"plain-key"}})
+	for _, key := range []string{"legacy-key", "other-key", "plain-key"} {
+		if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", key, http.StatusOK, w.Code)
+		}
+	}
+	if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": "wrong-key"}, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestMw_FallsBackWhenIDCollides(t *testing.T) {
+	hashed, _ := apikey.HashKey("ops.hashed", apikey.SHA256)
+	// 明文key "ops.plain" 与带ID的哈希同为 ops，ID 校验失败后仍需比较不带ID的key
+	r := newRouter(&apikey.Config{Name: "X-API-Key", Hashes: []string{"ops:" + hashed}, Values: []string{"ops.plain"}})
+	for _, key := range []string{"ops.hashed", "ops.plain"} {
+		if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", key, http.StatusOK, w.Code)
+		}
+	}
+	if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": "ops.wrong"}, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestConfigValidateHashes(t *testing.T) {
+	argon, _ := apikey.HashKey("k", apikey.Argon2id)
+	sha, _ := apikey.HashKey("k", apikey.SHA256)
+	for _, hashes := range [][]string{
+		{"md5$abc"},
+		{"sha256$zz"},
+		{argon},
+		{"a:" + sha, "a:" + sha},
+		{"a:$argon2id$v=19$m=x$salt$hash"},
+	} {
+		if err := (&apikey.Config{Hashes: hashes}).Validate(); err == nil {
+			t.Fatalf("expected error for %v", hashes)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mulan-ext/auth/audit"
+)
+
+// DefaultPrefix Manager 签发key的默认前缀
+const DefaultPrefix = "mk"
+
+var (
+	// ErrKeyRevoked key已吊销
+	ErrKeyRevoked = errors.New("apikey: key revoked")
+	// ErrKeyExpired key已过期
+	ErrKeyExpired = errors.New("apikey: key expired")
+	// ErrKeyRotated key已轮换，只能轮换最新的key
+	ErrKeyRotated = errors.New("apikey: key already rotated")
+	// ErrTooManyKeys 用户可用的key数量达到上限
+	ErrTooManyKeys = errors.New("apikey: too many keys")
+)
+
+type ManagerOption func(*Manager)
+
+// WithPrefix 签发key的前缀，只允许小写字母与数字，为空时不带前缀与校验码
+func WithPrefix(prefix string) ManagerOption {
+	return func(m *Manager) { m.prefix = prefix }
+}
+
+// WithAlgorithm 签发key的哈希算法，默认 SHA256
+func WithAlgorithm(alg Algorithm) ManagerOption {
+	return func(m *Manager) { m.alg = alg }
+}
+
+// WithMaxTTL key最长有效期，未指定或超过时截断为该值，0 不限制
+func WithMaxTTL(ttl time.Duration) ManagerOption {
+	return func(m *Manager) { m.maxTTL = ttl }
+}
+
+// WithMaxKeys 每个用户可用（未吊销、未过期）key的数量上限，0 不限制
+func WithMaxKeys(n int) ManagerOption {
+	return func(m *Manager) { m.maxKeys = n }
+}
+
+// WithClock 自定义时钟，用于测试
+func WithClock(now func() time.Time) ManagerOption {
+	return func(m *Manager) {
+		if now != nil {
+			m.now = now
+		}
+	}
+}
+
+// Manager 在 KeyStore 之上提供签发、轮换与吊销
+type Manager struct {
+	store   KeyStore
+	prefix  string
+	alg     Algorithm
+	maxTTL  time.Duration
+	maxKeys int
+	now     func() time.Time
+}
+
+func NewManager(store KeyStore, opts ...ManagerOption) (*Manager, error) {
+	if store == nil {
+		return nil, errors.New("apikey: store is nil")
+	}
+	m := &Manager{store: store, prefix: DefaultPrefix, alg: SHA256, now: time.Now}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if !validPrefix(m.prefix) {
+		return nil, fmt.Errorf("apikey: invalid prefix %q", m.prefix)
+	}
+	if _, err := HashKey("", m.alg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Store 返回底层存储，用于 WithStore
+func (m *Manager) Store() KeyStore { return m.store }
+
+// Issue 签发新key，返回只展示一次的明文key，格式为 "<prefix>_<id>.<secret>_<checksum>"
+func (m *Manager) Issue(ctx context.Context, k *Key) (string, error) {
+	now := m.now()
+	if m.maxTTL > 0 && (k.ExpiresAt.IsZero() || k.ExpiresAt.After(now.Add(m.maxTTL))) {
+		k.ExpiresAt = now.Add(m.maxTTL)
+	}
+	if !k.ExpiresAt.IsZero() && !k.ExpiresAt.After(now) {
+		return "", ErrKeyExpired
+	}
+	if m.maxKeys > 0 && k.UserID != 0 {
+		owned, err := m.Owned(ctx, k.UserID, k.Tenant)
+		if err != nil {
+			return "", err
+		}
+		active := 0
+		for _, o := range owned {
+			if o.Active(now) {
+				active++
+			}
+		}
+		if active >= m.maxKeys {
+			return "", ErrTooManyKeys
+		}
+	}
+	key, err := issue(ctx, m.store, k, m.prefix, m.alg, now)
+	if err != nil {
+		return "", err
+	}
+	emit(ctx, audit.APIKeyIssued, k, nil)
+	return key, nil
+}
+
+// Rotate 为 id 签发替代key，旧key在 overlap 后失效，期间新旧key均可使用；overlap 为 0 时旧key立即吊销
+//
+// 新key继承所有者、名称、角色、scope 与有效期长度。
+func (m *Manager) Rotate(ctx context.Context, id string, overlap time.Duration) (string, *Key, error) {
+	old, err := m.store.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	now := m.now()
+	switch {
+	case old.Revoked():
+		return "", nil, ErrKeyRevoked
+	case old.Expired(now):
+		return "", nil, ErrKeyExpired
+	case old.ReplacedBy != "":
+		return "", nil, ErrKeyRotated
+	}
+	next := cloneKey(old)
+	next.LastUsedAt = time.Time{}
+	if !old.ExpiresAt.IsZero() {
+		next.ExpiresAt = now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+	}
+	if m.maxTTL > 0 && (next.ExpiresAt.IsZero() || next.ExpiresAt.After(now.Add(m.maxTTL))) {
+		next.ExpiresAt = now.Add(m.maxTTL)
+	}
+	next.CreatedAt = time.Time{}
+	key, err := issue(ctx, m.store, next, m.prefix, m.alg, now)
+	if err != nil {
+		return "", nil, err
+	}
+	old.ReplacedBy = next.ID
+	if overlap <= 0 {
+		old.RevokedAt = now
+	} else if end := now.Add(overlap); old.ExpiresAt.IsZero() || end.Before(old.ExpiresAt) {
+		old.ExpiresAt = end
+	}
+	if err := m.store.Put(ctx, old); err != nil {
+		return "", nil, err
+	}
+	emit(ctx, audit.APIKeyRotated, old, map[string]any{"replaced_by": next.ID, "overlap": overlap.String()})
+	next.Hash = ""
+	return key, next, nil
+}
+
+// Revoke 立即吊销key，已吊销时不报错
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+	k, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if k.Revoked() {
+		return nil
+	}
+	k.RevokedAt = m.now()
+	if err := m.store.Put(ctx, k); err != nil {
+		return err
+	}
+	emit(ctx, audit.APIKeyRevoked, k, nil)
+	return nil
+}
+
+// RevokeUser 吊销用户在租户内的全部有效key，签名与 session.StateHook 一致，锁定用户时传给 session.UpdateUserState
+//
+// key 不随用户状态变化，锁定的用户不吊销时仍可使用已签发的key。
+func (m *Manager) RevokeUser(ctx context.Context, tenant string, id uint64) error {
+	keys, err := m.Owned(ctx, id, tenant)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, k := range keys {
+		if !k.Revoked() {
+			errs = append(errs, m.Revoke(ctx, k.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Owned 返回用户在租户内的全部key（含已吊销、已过期），不含哈希
+func (m *Manager) Owned(ctx context.Context, userID uint64, tenant string) ([]*Key, error) {
+	keys, err := m.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	owned := keys[:0]
+	for _, k := range keys {
+		if k.UserID == userID && k.Tenant == tenant {
+			k.Hash = ""
+			owned = append(owned, k)
+		}
+	}
+	return owned, nil
+}
+
+func emit(ctx context.Context, typ audit.Type, k *Key, fields map[string]any) {
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["key_id"], fields["owner"] = k.ID, k.UserID
+	if k.Name != "" {
+		fields["name"] = k.Name
+	}
+	audit.Emit(ctx, audit.Event{Type: typ, Tenant: k.Tenant, Fields: fields})
+}
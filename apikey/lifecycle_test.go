@@ -0,0 +1,232 @@
+package apikey_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/apikey"
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestKeyFormatChecksum(t *testing.T) {
+	m, err := apikey.NewManager(apikey.NewMemKeyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := m.Issue(context.Background(), &apikey.Key{Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, apikey.DefaultPrefix+"_") || apikey.KeyID(key) == "" || !apikey.ValidChecksum(key) {
+		t.Fatalf("unexpected key %q", key)
+	}
+	// 修改 secret 中的一个字符
+	i := strings.IndexByte(key, '.') + 1
+	typo := key[:i] + string(key[i]^1) + key[i+1:]
+	if apikey.ValidChecksum(typo) {
+		t.Fatalf("typo %q passed checksum", typo)
+	}
+	// 不是 Manager 格式的静态key不检查校验码
+	for _, static := range []string{"my_key.v1", "sk_live.abc", "plain-key", "abcdefgh.secret"} {
+		if !apikey.ValidChecksum(static) {
+			t.Fatalf("static key %q rejected as malformed", static)
+		}
+	}
+	if _, err := apikey.NewManager(apikey.NewMemKeyStore(), apikey.WithPrefix("Bad_")); err == nil {
+		t.Fatal("expected invalid prefix error")
+	}
+}
+
+func TestManagerRotateAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := apikey.NewMemKeyStore()
+	m, _ := apikey.NewManager(store, apikey.WithClock(func() time.Time { return now }), apikey.WithMaxTTL(90*24*time.Hour))
+	old, err := m.Issue(ctx, &apikey.Key{UserID: 1, Name: "deploy", Scopes: []string{"write:deploy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(apikey.Mw(&apikey.Config{Name: "X-API-Key"}, apikey.WithStore(store)))
+	r.GET("/protected", func(c *gin.Context) {})
+	status := func(key string) int {
+		return performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil).Code
+	}
+
+	next, nextKey, err := m.Rotate(ctx, apikey.KeyID(old), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextKey.Name != "deploy" || nextKey.Scopes[0] != "write:deploy" || !nextKey.ExpiresAt.Equal(now.Add(90*24*time.Hour)) {
+		t.Fatalf("metadata not inherited: %+v", nextKey)
+	}
+	// 重叠期内新旧key均可用
+	if status(old) != http.StatusOK || status(next) != http.StatusOK {
+		t.Fatalf("overlap: old %d, new %d", status(old), status(next))
+	}
+	if _, _, err := m.Rotate(ctx, apikey.KeyID(old), time.Hour); err != apikey.ErrKeyRotated {
+		t.Fatalf("expected ErrKeyRotated, got %v", err)
+	}
+	if k, _ := store.Get(ctx, apikey.KeyID(old)); !k.ExpiresAt.Equal(now.Add(time.Hour)) || k.ReplacedBy != nextKey.ID {
+		t.Fatalf("old key not scheduled to expire: %+v", k)
+	}
+
+	if err := m.Revoke(ctx, nextKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status(next) != http.StatusUnauthorized {
+		t.Fatal("revoked key still accepted")
+	}
+	if _, _, err := m.Rotate(ctx, nextKey.ID, 0); err != apikey.ErrKeyRevoked {
+		t.Fatalf("expected ErrKeyRevoked, got %v", err)
+	}
+}
+
+func TestSelfServiceHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, _ := apikey.NewManager(apikey.NewMemKeyStore(), apikey.WithMaxKeys(1))
+	r := gin.New()
+	r.Use(session.Mw("token", session.NewMemStore()))
+	r.POST("/login", func(c *gin.Context) {
+		_ = session.Default(c).Login(func(s *session.Session) error {
+			s.SetID(1)
+			s.SetAccount("alice")
+			s.SetRoles([]string{"reader"})
+			return nil
+		})
+	})
+	keys := r.Group("/keys")
+	keys.GET("", m.ListHandler())
+	keys.POST("", m.CreateHandler())
+	keys.DELETE("/:id", m.RevokeHandler())
+	api := r.Group("/api", apikey.Mw(&apikey.Config{}, apikey.WithStore(m.Store())), session.RoleMW("reader"))
+	api.GET("/reports", func(c *gin.Context) {})
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("X-Token", token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := do(http.MethodGet, "/keys", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous list: %d", w.Code)
+	}
+	token := do(http.MethodPost, "/login", "", "").Header().Get("X-Token")
+
+	if w := do(http.MethodPost, "/keys", token, `{"name":"ci","roles":["admin"]}`); w.Code != http.StatusForbidden {
+		t.Fatalf("escalation allowed: %d %s", w.Code, w.Body)
+	}
+	w := do(http.MethodPost, "/keys", token, `{"name":"ci","roles":["reader"],"expires_in":3600}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	var created apikey.CreateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Secret == "" || created.Key == nil || created.Hash != "" {
+		t.Fatalf("unexpected create response %s: %v", w.Body, err)
+	}
+	if w := do(http.MethodPost, "/keys", token, `{"name":"second"}`); w.Code != http.StatusConflict {
+		t.Fatalf("key limit not enforced: %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/reports", nil)
+	req.Header.Set("apikey", created.Secret)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("issued key rejected: %d", rec.Code)
+	}
+
+	w = do(http.MethodGet, "/keys", token, "")
+	var listed []apikey.Key
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil || len(listed) != 1 || listed[0].ID != created.ID || strings.Contains(w.Body.String(), "sha256") {
+		t.Fatalf("unexpected list %s: %v", w.Body, err)
+	}
+	if w := do(http.MethodDelete, "/keys/unknown", token, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("revoke unknown: %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/keys/"+created.ID, token, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: %d %s", w.Code, w.Body)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked key accepted: %d", rec.Code)
+	}
+}
+
+func TestCreateHandlerUsesTenantRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, _ := apikey.NewManager(apikey.NewMemKeyStore())
+	r := gin.New()
+	r.Use(session.TenantMW(session.TenantFromHeader("X-Tenant")), session.Mw("token", session.NewMemStore()))
+	r.POST("/login", func(c *gin.Context) {
+		_ = session.Default(c).Login(func(s *session.Session) error {
+			s.SetID(1)
+			s.SetRoles([]string{"editor@acme", "admin@other"})
+			return nil
+		})
+	})
+	r.POST("/keys", m.CreateHandler())
+	do := func(token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/keys", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant", "acme")
+		req.Header.Set("X-Token", token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.Header.Set("X-Tenant", "acme")
+	r.ServeHTTP(login, req)
+	token := login.Header().Get("X-Token")
+
+	if w := do(token, `{"name":"ci","roles":["editor"]}`); w.Code != http.StatusCreated {
+		t.Fatalf("tenant role not grantable: %d %s", w.Code, w.Body)
+	}
+	for _, role := range []string{"admin", "admin@other", "editor@other"} {
+		if w := do(token, `{"name":"ci","roles":["`+role+`"]}`); w.Code != http.StatusForbidden {
+			t.Fatalf("role %s from another tenant granted: %d %s", role, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRevokeUserOnLock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	m, _ := apikey.NewManager(apikey.NewMemKeyStore())
+	mine, err := m.Issue(ctx, &apikey.Key{UserID: 7, Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.Issue(ctx, &apikey.Key{UserID: 7, Name: "ci", Tenant: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := func(s uint16) uint16 { return s | session.StateLocked }
+	if _, err := session.UpdateUserState(ctx, session.NewMemStore(), "", 7, lock, m.RevokeUser); err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(apikey.Mw(&apikey.Config{}, apikey.WithStore(m.Store())))
+	r.GET("/protected", func(c *gin.Context) {})
+	if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"apikey": mine}, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("key of locked user accepted: %d", w.Code)
+	}
+	if k, err := m.Store().Get(ctx, apikey.KeyID(other)); err != nil || k.Revoked() {
+		t.Fatalf("key of the same ID in another tenant revoked: %v", err)
+	}
+}
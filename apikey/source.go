@@ -0,0 +1,200 @@
+package apikey
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/audit"
+)
+
+// FileSource 从文件加载key哈希，支持不重启重新加载
+//
+// 文件每行一个 Config.Hashes 格式的配置项 "[id:]hash"，空行与 # 开头的行被忽略。
+// Reload 先完整解析校验再原子替换，解析失败时继续使用原有key。
+type FileSource struct {
+	path string
+	keys atomic.Pointer[keyring]
+
+	mu      sync.Mutex // 串行化 Reload
+	modTime time.Time
+	size    int64
+}
+
+// NewFileSource 加载 path，文件不存在或格式错误时返回错误
+func NewFileSource(path string) (*FileSource, error) {
+	s := &FileSource{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload 重新读取文件并原子替换key集合，记录新增与移除的key ID（不记录哈希）
+func (s *FileSource) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	buf, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	// 无论成功与否都记录，避免轮询时对同一错误文件反复报错
+	s.modTime, s.size = info.ModTime(), info.Size()
+	entries, err := parseLines(buf)
+	if err != nil {
+		return fmt.Errorf("%w (%s)", err, s.path)
+	}
+	next, err := newKeyring(entries)
+	if err != nil {
+		return fmt.Errorf("%w (%s)", err, s.path)
+	}
+	prev := s.keys.Load()
+	next.reuse(prev)
+	s.keys.Store(next)
+	if prev != nil {
+		added, removed, changed := prev.diff(next)
+		zap.L().Info("apikey: keys reloaded",
+			zap.String("path", s.path),
+			zap.Int("total", next.count),
+			zap.Strings("added", added),
+			zap.Strings("removed", removed),
+			zap.Strings("changed", changed))
+	}
+	return nil
+}
+
+// Watch 每隔 interval 检查文件修改时间与大小，变化时调用 Reload，直到 ctx 取消
+func (s *FileSource) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(s.path)
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		changed := !info.ModTime().Equal(s.modTime) || info.Size() != s.size
+		s.mu.Unlock()
+		if changed {
+			if err := s.Reload(); err != nil {
+				zap.L().Error("apikey: reload failed, keeping previous keys", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ReloadOnSignal 收到信号（默认 SIGHUP）时调用 Reload，直到 ctx 取消
+func (s *FileSource) ReloadOnSignal(ctx context.Context, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			if err := s.Reload(); err != nil {
+				zap.L().Error("apikey: reload failed, keeping previous keys", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *FileSource) verify(key string) (string, bool) { return s.keys.Load().verify(key) }
+
+func parseLines(buf []byte) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := parseHash(line); err != nil {
+			return nil, fmt.Errorf("%w (line %d)", err, n)
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
+
+// label 日志中标识key：优先使用ID，无ID时使用哈希摘要
+func (h *hashedKey) label() string {
+	if h.id != "" {
+		return h.id
+	}
+	return "sha256:" + audit.TokenID(h.raw)
+}
+
+// reuse 沿用 prev 中未变化的key，保留慢哈希已缓存的摘要
+func (k *keyring) reuse(prev *keyring) {
+	if prev == nil {
+		return
+	}
+	for id, h := range k.byID {
+		if old, ok := prev.byID[id]; ok && old.raw == h.raw {
+			k.byID[id] = old
+		}
+	}
+}
+
+// diff 返回 next 相对 k 新增、移除与哈希变化的key标识
+func (k *keyring) diff(next *keyring) (added, removed, changed []string) {
+	for id, h := range next.byID {
+		if old, ok := k.byID[id]; !ok {
+			added = append(added, id)
+		} else if old.raw != h.raw {
+			changed = append(changed, id)
+		}
+	}
+	for id := range k.byID {
+		if _, ok := next.byID[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	labels := func(hs []*hashedKey) map[string]struct{} {
+		m := make(map[string]struct{}, len(hs))
+		for _, h := range hs {
+			m[h.label()] = struct{}{}
+		}
+		return m
+	}
+	before, after := labels(k.noID), labels(next.noID)
+	for l := range after {
+		if _, ok := before[l]; !ok {
+			added = append(added, l)
+		}
+	}
+	for l := range before {
+		if _, ok := after[l]; !ok {
+			removed = append(removed, l)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+	return added, removed, changed
+}
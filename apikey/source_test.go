@@ -0,0 +1,142 @@
+package apikey_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/mulan-ext/auth/apikey"
+)
+
+func writeKeys(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("# api keys\n"+strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSourceReload(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	path := filepath.Join(t.TempDir(), "apikeys")
+	oldKey, oldEntry, _ := apikey.GenerateKey(apikey.SHA256)
+	newKey, newEntry, _ := apikey.GenerateKey(apikey.SHA256)
+	legacyHash, _ := apikey.HashKey("legacy-key", apikey.SHA256)
+	writeKeys(t, path, oldEntry, legacyHash)
+
+	source, err := apikey.NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRouterWith(apikey.Mw(&apikey.Config{Name: "X-API-Key"}, apikey.WithSource(source)))
+	status := func(key string) int {
+		return performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil).Code
+	}
+	if status(oldKey) != http.StatusOK || status("legacy-key") != http.StatusOK || status(newKey) != http.StatusUnauthorized {
+		t.Fatal("initial key set not applied")
+	}
+
+	// 并发请求期间替换key集合
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					status(newKey)
+				}
+			}
+		})
+	}
+	writeKeys(t, path, newEntry)
+	if err := source.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+	if status(oldKey) != http.StatusUnauthorized || status("legacy-key") != http.StatusUnauthorized || status(newKey) != http.StatusOK {
+		t.Fatal("reloaded key set not applied")
+	}
+
+	// 无效文件不替换
+	writeKeys(t, path, newEntry, "md5$broken")
+	if err := source.Reload(); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected parse error with line number, got %v", err)
+	}
+	if status(newKey) != http.StatusOK {
+		t.Fatal("invalid file replaced the key set")
+	}
+
+	entries := logs.FilterMessage("apikey: keys reloaded").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected one reload log, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if got := fields["added"].([]any); len(got) != 1 || got[0] != apikey.KeyID(newKey) {
+		t.Fatalf("added = %v", got)
+	}
+	if got := fields["removed"].([]any); len(got) != 2 {
+		t.Fatalf("removed = %v", got)
+	}
+	for _, e := range logs.All() {
+		for _, secret := range []string{oldKey, newKey, oldEntry, newEntry, legacyHash} {
+			for _, v := range e.ContextMap() {
+				if strings.Contains(strings.Join(toStrings(v), " "), secret) {
+					t.Fatalf("secret logged in %q", e.Message)
+				}
+			}
+		}
+	}
+}
+
+func TestFileSourceWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys")
+	key, entry, _ := apikey.GenerateKey(apikey.SHA256)
+	writeKeys(t, path)
+	source, err := apikey.NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRouterWith(apikey.Mw(&apikey.Config{Name: "X-API-Key"}, apikey.WithSource(source)))
+	if w := performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("empty source accepted key: %d", w.Code)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go source.Watch(ctx, 10*time.Millisecond)
+	writeKeys(t, path, entry)
+	_ = os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	deadline := time.Now().Add(2 * time.Second)
+	for performRequest(r, http.MethodGet, "/protected", map[string]string{"X-API-Key": key}, nil).Code != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("watch did not reload the file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func toStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var out []string
+		for _, s := range v {
+			out = append(out, toStrings(s)...)
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var _ KeyStore = (*FileKeyStore)(nil)
+
+// FileKeyStore 以单个 JSON 文件保存全部key，写入时先写临时文件再重命名
+//
+// 适用于单实例部署，多进程共享同一文件时请使用 RedisKeyStore。
+type FileKeyStore struct {
+	mu   sync.RWMutex
+	path string
+	keys map[string]*Key
+}
+
+// NewFileKeyStore 打开 path，文件不存在时创建空存储
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	s := &FileKeyStore{path: path, keys: make(map[string]*Key)}
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*Key
+	if err := json.Unmarshal(buf, &keys); err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+func (s *FileKeyStore) Get(_ context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return cloneKey(k), nil
+}
+
+func (s *FileKeyStore) Put(_ context.Context, k *Key) error {
+	if k.ID == "" {
+		return errors.New("apikey: key ID is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, existed := s.keys[k.ID]
+	s.keys[k.ID] = cloneKey(k)
+	if err := s.flush(); err != nil {
+		if existed {
+			s.keys[k.ID] = prev
+		} else {
+			delete(s.keys, k.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileKeyStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.keys[id]
+	if !ok {
+		return nil
+	}
+	delete(s.keys, id)
+	if err := s.flush(); err != nil {
+		s.keys[id] = prev
+		return err
+	}
+	return nil
+}
+
+func (s *FileKeyStore) List(_ context.Context) ([]*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list(), nil
+}
+
+func (s *FileKeyStore) Touch(_ context.Context, id string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	k.LastUsedAt = t
+	return s.flush()
+}
+
+func (s *FileKeyStore) list() []*Key {
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, cloneKey(k))
+	}
+	sortKeys(keys)
+	return keys
+}
+
+// flush 将全部key写入文件，调用方需持有写锁
+func (s *FileKeyStore) flush() error {
+	buf, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
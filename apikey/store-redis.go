@@ -0,0 +1,119 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultKeyPrefix RedisKeyStore 默认key前缀
+const DefaultKeyPrefix = "ginx:auth:apikey:"
+
+var _ KeyStore = (*RedisKeyStore)(nil)
+
+// RedisKeyStore 每个key保存为一个 Redis Hash：data 为 JSON，last_used 单独更新避免覆盖并发写入
+type RedisKeyStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+func NewRedisKeyStore(client redis.UniversalClient, prefix ...string) (*RedisKeyStore, error) {
+	s := &RedisKeyStore{client: client, keyPrefix: DefaultKeyPrefix}
+	if len(prefix) > 0 && prefix[0] != "" {
+		s.keyPrefix = prefix[0]
+	}
+	return s, client.Ping(context.Background()).Err()
+}
+
+func (s *RedisKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	vals, err := s.client.HGetAll(ctx, s.keyPrefix+id).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return decodeKey(vals)
+}
+
+func (s *RedisKeyStore) Put(ctx context.Context, k *Key) error {
+	if k.ID == "" {
+		return errors.New("apikey: key ID is required")
+	}
+	buf, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	key := s.keyPrefix + k.ID
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "data", buf)
+	if !k.LastUsedAt.IsZero() {
+		pipe.HSet(ctx, key, "last_used", k.LastUsedAt.UnixNano())
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisKeyStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, s.keyPrefix+id).Err()
+}
+
+func (s *RedisKeyStore) List(ctx context.Context) ([]*Key, error) {
+	var keys []*Key
+	iter := s.client.Scan(ctx, 0, s.keyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		k, err := s.Get(ctx, strings.TrimPrefix(iter.Val(), s.keyPrefix))
+		if errors.Is(err, ErrKeyNotFound) {
+			// 遍历期间被删除
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+// touchScript 仅在key存在时更新 last_used，避免与 Delete 并发时已删除的key被重新创建
+var touchScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return 0
+end
+redis.call('HSET', KEYS[1], 'last_used', ARGV[1])
+return 1
+`)
+
+func (s *RedisKeyStore) Touch(ctx context.Context, id string, t time.Time) error {
+	ok, err := touchScript.Run(ctx, s.client, []string{s.keyPrefix + id}, t.UnixNano()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func decodeKey(vals map[string]string) (*Key, error) {
+	k := &Key{}
+	if err := json.Unmarshal([]byte(vals["data"]), k); err != nil {
+		return nil, err
+	}
+	if v, ok := vals["last_used"]; ok {
+		if ns, err := strconv.ParseInt(v, 10, 64); err == nil {
+			k.LastUsedAt = time.Unix(0, ns)
+		}
+	}
+	return k, nil
+}
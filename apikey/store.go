@@ -0,0 +1,264 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+var (
+	// ErrKeyNotFound KeyStore 中不存在该key
+	ErrKeyNotFound = errors.New("apikey: key not found")
+	// ErrInvalidScope scope 不符合 RFC 6749 scope-token 语法
+	ErrInvalidScope = errors.New("apikey: invalid scope")
+)
+
+// Key 已签发的 API Key，只保存哈希，不保存明文
+type Key struct {
+	ID         string    `json:"id"`
+	Hash       string    `json:"hash,omitempty"` // sha256$<hex>、argon2id(PHC) 或 bcrypt，见 HashKey
+	UserID     uint64    `json:"user_id,omitempty"`
+	Account    string    `json:"account,omitempty"`
+	Name       string    `json:"name,omitempty"` // 用途说明，如 "ci-deploy"
+	Roles      []string  `json:"roles,omitempty"`
+	Scopes     []string  `json:"scopes,omitempty"` // 授予的 scope，如 "read:reports"，"*" 表示全部，见 session.RequireScope
+	Tenant     string    `json:"tenant,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitzero"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"` // 零值永不过期
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	RevokedAt  time.Time `json:"revoked_at,omitzero"`
+	ReplacedBy string    `json:"replaced_by,omitempty"` // 轮换后的新key ID
+}
+
+// Expired 检查key在 now 时是否已过期
+func (k *Key) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// Revoked 是否已吊销
+func (k *Key) Revoked() bool { return !k.RevokedAt.IsZero() }
+
+// Active 在 now 时是否可用
+func (k *Key) Active(now time.Time) bool { return !k.Revoked() && !k.Expired(now) }
+
+// KeyStore API Key 存储
+type KeyStore interface {
+	// Get 按ID获取key，不存在时返回 ErrKeyNotFound
+	Get(ctx context.Context, id string) (*Key, error)
+	// Put 新增或覆盖key
+	Put(ctx context.Context, key *Key) error
+	// Delete 删除key，不存在时不报错
+	Delete(ctx context.Context, id string) error
+	// List 返回全部key，按ID排序
+	List(ctx context.Context) ([]*Key, error)
+	// Touch 更新最近使用时间
+	Touch(ctx context.Context, id string, t time.Time) error
+}
+
+// Issue 生成新key并写入 store，返回只展示一次的明文key
+//
+// k.ID、k.Hash 由 Issue 填充，k.CreatedAt 为零值时取当前时间。
+func Issue(ctx context.Context, store KeyStore, k *Key, alg Algorithm) (string, error) {
+	return issue(ctx, store, k, "", alg, time.Now())
+}
+
+func issue(ctx context.Context, store KeyStore, k *Key, prefix string, alg Algorithm, now time.Time) (string, error) {
+	if k.Tenant != "" && !session.ValidTenant(k.Tenant) {
+		return "", session.ErrInvalidTenant
+	}
+	for _, scope := range k.Scopes {
+		if !validScope(scope) {
+			return "", fmt.Errorf("%w %q", ErrInvalidScope, scope)
+		}
+	}
+	id, key, hash, err := newKey(prefix, alg)
+	if err != nil {
+		return "", err
+	}
+	k.ID, k.Hash = id, hash
+	if k.CreatedAt.IsZero() {
+		k.CreatedAt = now
+	}
+	if err := store.Put(ctx, k); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+type keyCtxKey struct{}
+
+// FromContext 获取当前请求通过校验的 Key，仅 KeyStore 中的key可用，Hash 已清空
+func FromContext(ctx context.Context) (*Key, bool) {
+	k, ok := ctx.Value(keyCtxKey{}).(*Key)
+	return k, ok && k != nil
+}
+
+// validScope 检查 scope 是否符合 RFC 6749 scope-token 语法
+func validScope(scope string) bool {
+	return scope != "" && !strings.ContainsFunc(scope, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '\\' || r >= 0x7f
+	})
+}
+
+// cloneKey 深拷贝，避免调用方修改 store 内部数据
+func cloneKey(k *Key) *Key {
+	c := *k
+	c.Roles = slices.Clone(k.Roles)
+	c.Scopes = slices.Clone(k.Scopes)
+	return &c
+}
+
+var _ KeyStore = (*MemKeyStore)(nil)
+
+// MemKeyStore 内存存储，用于测试或单实例部署
+type MemKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+func NewMemKeyStore() *MemKeyStore {
+	return &MemKeyStore{keys: make(map[string]*Key)}
+}
+
+func (s *MemKeyStore) Get(_ context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return cloneKey(k), nil
+}
+
+func (s *MemKeyStore) Put(_ context.Context, k *Key) error {
+	if k.ID == "" {
+		return errors.New("apikey: key ID is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = cloneKey(k)
+	return nil
+}
+
+func (s *MemKeyStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, id)
+	return nil
+}
+
+func (s *MemKeyStore) List(_ context.Context) ([]*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, cloneKey(k))
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+func (s *MemKeyStore) Touch(_ context.Context, id string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	k.LastUsedAt = t
+	return nil
+}
+
+func sortKeys(keys []*Key) {
+	slices.SortFunc(keys, func(a, b *Key) int { return strings.Compare(a.ID, b.ID) })
+}
+
+// touchInterval 最近使用时间的最小更新间隔，避免每个请求都写入 store
+const touchInterval = time.Minute
+
+// storeVerifier 从 KeyStore 校验key，缓存解析后的哈希
+type storeVerifier struct {
+	store KeyStore
+	cache sync.Map // id -> *hashedKey
+}
+
+// verify 校验通过时返回key；key ID 不在 store 中时 reason 为空，由静态配置继续校验
+func (v *storeVerifier) verify(ctx context.Context, current string) (k *Key, reason string) {
+	id := KeyID(current)
+	if id == "" {
+		return nil, ""
+	}
+	k, err := v.store.Get(ctx, id)
+	if errors.Is(err, ErrKeyNotFound) {
+		v.cache.Delete(id)
+		return nil, ""
+	}
+	if err != nil {
+		zap.L().Error("apikey: store get failed", zap.String("id", id), zap.Error(err))
+		return nil, "unavailable"
+	}
+	h, err := v.hashed(k)
+	if err != nil {
+		zap.L().Error("apikey: invalid stored hash", zap.String("id", id), zap.Error(err))
+		return nil, "invalid"
+	}
+	if !h.match(current) {
+		return nil, "invalid"
+	}
+	now := time.Now()
+	if k.Revoked() {
+		return nil, "revoked"
+	}
+	if k.Expired(now) {
+		return nil, "expired"
+	}
+	if tenant := session.TenantFromContext(ctx); tenant != "" && k.Tenant != tenant {
+		return nil, "tenant_mismatch"
+	}
+	if now.Sub(k.LastUsedAt) >= touchInterval {
+		if err := v.store.Touch(ctx, id, now); err != nil {
+			zap.L().Warn("apikey: touch failed", zap.String("id", id), zap.Error(err))
+		}
+		k.LastUsedAt = now
+	}
+	return k, ""
+}
+
+// hashed 返回缓存的哈希，store 中的哈希变化（轮换）后重新解析
+func (v *storeVerifier) hashed(k *Key) (*hashedKey, error) {
+	if cached, ok := v.cache.Load(k.ID); ok && cached.(*hashedKey).raw == k.Hash {
+		return cached.(*hashedKey), nil
+	}
+	h, err := parseHash(k.ID + ":" + k.Hash)
+	if err != nil {
+		return nil, err
+	}
+	v.cache.Store(k.ID, h)
+	return h, nil
+}
+
+// withKey 返回携带 Key 与 Principal 的请求
+func withKey(r *http.Request, k *Key) *http.Request {
+	k = cloneKey(k)
+	k.Hash = ""
+	ctx := context.WithValue(r.Context(), keyCtxKey{}, k)
+	ctx = principal.NewContext(ctx, &principal.Principal{
+		ID:      k.UserID,
+		Account: k.Account,
+		Roles:   session.RolesFor(k.Roles, k.Tenant),
+		Scopes:  append([]string{}, k.Scopes...),
+		Tenant:  k.Tenant,
+		Method:  principal.MethodAPIKey,
+	})
+	return r.WithContext(ctx)
+}
@@ -0,0 +1,183 @@
+package apikey_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mulan-ext/auth/apikey"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestMw_PopulatesPrincipalFromStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	store := apikey.NewMemKeyStore()
+	key, err := apikey.Issue(ctx, store, &apikey.Key{
+		UserID: 42, Account: "ci-bot", Name: "deploy", Roles: []string{"deployer"}, Scopes: []string{"write:deploy"},
+	}, apikey.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := apikey.Issue(ctx, store, &apikey.Key{UserID: 43, ExpiresAt: time.Now().Add(-time.Second)}, apikey.SHA256)
+
+	r := gin.New()
+	r.Use(apikey.Mw(&apikey.Config{Name: "X-API-Key"}, apikey.WithStore(store)))
+	r.GET("/deploy", session.AuthMW(), session.RoleMW("deployer"), session.RequireScope("write:deploy"), func(c *gin.Context) {
+		if !session.HasScope(c, "write:deploy") || session.HasScope(c, "read:reports") ||
+			len(c.GetStringSlice(session.CtxKeyScopes)) != 1 {
+			t.Errorf("unexpected scopes: %v", c.GetStringSlice(session.CtxKeyScopes))
+		}
+		k, _ := apikey.FromContext(c.Request.Context())
+		p, _ := principal.FromContext(c.Request.Context())
+		if c.GetUint64(session.CtxKeyID) != 42 || c.GetString(session.CtxKeyAccount) != "ci-bot" ||
+			k.Name != "deploy" || k.Hash != "" || p.ID != 42 || p.Method != principal.MethodAPIKey {
+			t.Errorf("unexpected context: key %+v, principal %+v", k, p)
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/admin", session.RoleMW(session.RoleAdmin), func(c *gin.Context) {})
+	r.GET("/reports", session.RequireScope("read:reports"), func(c *gin.Context) {})
+
+	cases := []struct {
+		path, key string
+		want      int
+	}{
+		{"/deploy", key, http.StatusOK},
+		{"/admin", key, http.StatusForbidden},
+		{"/reports", key, http.StatusForbidden},
+		{"/deploy", expired, http.StatusUnauthorized},
+		{"/deploy", apikey.KeyID(key) + ".forged", http.StatusUnauthorized},
+		{"/deploy", "", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if w := performRequest(r, http.MethodGet, tc.path, map[string]string{"X-API-Key": tc.key}, nil); w.Code != tc.want {
+			t.Fatalf("%s with %q: expected status %d, got %d", tc.path, tc.key, tc.want, w.Code)
+		}
+	}
+	if k, _ := store.Get(ctx, apikey.KeyID(key)); k.LastUsedAt.IsZero() {
+		t.Fatal("last used time not recorded")
+	}
+
+	// 轮换后旧key失效
+	rotated, _ := store.Get(ctx, apikey.KeyID(key))
+	rotated.Hash, _ = apikey.HashKey("other", apikey.SHA256)
+	_ = store.Put(ctx, rotated)
+	if w := performRequest(r, http.MethodGet, "/deploy", map[string]string{"X-API-Key": key}, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("rotated key accepted: %d", w.Code)
+	}
+}
+
+func TestIssueRejectsInvalidScope(t *testing.T) {
+	if _, err := apikey.Issue(context.Background(), apikey.NewMemKeyStore(), &apikey.Key{Scopes: []string{"read reports"}}, apikey.SHA256); err == nil {
+		t.Fatal("expected error for scope containing whitespace")
+	}
+}
+
+func TestMiddleware_RoleMiddlewareWithStore(t *testing.T) {
+	store := apikey.NewMemKeyStore()
+	key, _ := apikey.Issue(context.Background(), store, &apikey.Key{UserID: 1, Roles: []string{"reader"}, Tenant: "acme"}, apikey.SHA256)
+	handler := apikey.Middleware(&apikey.Config{}, apikey.WithStore(store))(
+		session.RoleMiddleware("reader")(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+	for _, tc := range []struct {
+		tenant string
+		want   int
+	}{{"acme", http.StatusOK}, {"", http.StatusOK}, {"other", http.StatusUnauthorized}} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		if tc.tenant != "" {
+			r = r.WithContext(session.WithTenant(r.Context(), tc.tenant))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Fatalf("tenant %q: expected status %d, got %d", tc.tenant, tc.want, w.Code)
+		}
+	}
+}
+
+func TestFileKeyStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys", "apikeys.json")
+	store, err := apikey.NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := apikey.Issue(ctx, store, &apikey.Key{UserID: 7, Roles: []string{"admin"}}, apikey.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Touch(ctx, apikey.KeyID(key), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if buf, _ := os.ReadFile(path); len(buf) == 0 || string(buf) == "[]" {
+		t.Fatalf("nothing written: %s", buf)
+	}
+
+	reopened, err := apikey.NewFileKeyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := reopened.List(ctx)
+	if len(keys) != 1 || keys[0].UserID != 7 || keys[0].LastUsedAt.IsZero() || keys[0].CreatedAt.IsZero() {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+	if err := reopened.Delete(ctx, keys[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get(ctx, keys[0].ID); err != apikey.ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestRedisKeyStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	store, err := apikey.NewRedisKeyStore(client, "test:apikey:"+time.Now().Format("150405.000000")+":")
+	if err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+	ctx := context.Background()
+	key, err := apikey.Issue(ctx, store, &apikey.Key{UserID: 9, Name: "redis"}, apikey.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := apikey.KeyID(key)
+	defer store.Delete(ctx, id)
+	now := time.Now()
+	if err := store.Touch(ctx, id, now); err != nil {
+		t.Fatal(err)
+	}
+	k, err := store.Get(ctx, id)
+	if err != nil || k.UserID != 9 || !k.LastUsedAt.Equal(time.Unix(0, now.UnixNano())) {
+		t.Fatalf("unexpected key %+v: %v", k, err)
+	}
+	if keys, _ := store.List(ctx); len(keys) != 1 {
+		t.Fatalf("unexpected list: %+v", keys)
+	}
+	if err := store.Touch(ctx, "missing", now); err != apikey.ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	// 删除后 Touch 不能重新创建key
+	if err := store.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Touch(ctx, id, now); err != apikey.ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound after delete, got %v", err)
+	}
+	if _, err := store.Get(ctx, id); err != apikey.ErrKeyNotFound {
+		t.Fatalf("deleted key recreated by Touch: %v", err)
+	}
+}
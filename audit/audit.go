@@ -0,0 +1,142 @@
+// Package audit 记录登录、登出、session 轮换、认证失败与授权拒绝等安全审计事件
+//
+// 各认证组件通过 Emit 写出事件，默认不输出；调用 SetDefault 配置 Sink 后生效。
+// 事件只记录 token、API Key 的摘要（TokenID），不记录原始值。
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/principal"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	LoginSucceeded   Type = "login.succeeded"    // 登录成功并建立session
+	LoginFailed      Type = "login.failed"       // 登录回调校验失败，如 state、nonce、ID Token 无效
+	ProviderError    Type = "login.provider"     // OAuth2/OIDC Provider 返回错误或不可用
+	Logout           Type = "logout"             // 退出登录，session 被销毁
+	SessionRotated   Type = "session.rotated"    // session token 轮换
+	Unauthenticated  Type = "auth.required"      // 需要认证的请求未认证
+	APIKeyRejected   Type = "apikey.rejected"    // API Key 缺失或无效
+	APIKeyIssued     Type = "apikey.issued"      // 签发 API Key
+	APIKeyRotated    Type = "apikey.rotated"     // 轮换 API Key，旧key在重叠期后失效
+	APIKeyRevoked    Type = "apikey.revoked"     // 吊销 API Key
+	AccessDenied     Type = "access.denied"      // 角色等授权检查未通过
+	RateLimited      Type = "ratelimit.exceeded" // 请求超出限流额度
+	ImpersonateStart Type = "impersonate.start"  // 开始代为操作
+	ImpersonateStop  Type = "impersonate.stop"   // 结束代为操作
+)
+
+// Event 审计事件
+//
+// 请求关联字段由 Mw 写入 context，Emit 时自动填充；主体字段未设置时取自 context 中的 Principal。
+type Event struct {
+	Type      Type           `json:"type"`
+	Time      time.Time      `json:"time"`
+	Method    string         `json:"method,omitempty"` // 认证方式，见 principal.Method*
+	UserID    uint64         `json:"user_id,omitempty"`
+	Account   string         `json:"account,omitempty"`
+	Tenant    string         `json:"tenant,omitempty"`
+	Session   string         `json:"session,omitempty"` // session token 摘要，见 TokenID
+	Provider  string         `json:"provider,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	RemoteIP  string         `json:"remote_ip,omitempty"`
+	UserAgent string         `json:"user_agent,omitempty"`
+	HTTP      string         `json:"http,omitempty"` // 请求方法与路径，如 "POST /login"
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// TokenID 返回 token 的摘要，用于在审计日志中关联 session 而不泄露 token
+func TokenID(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sink 事件输出
+type Sink interface {
+	Write(e *Event) error
+}
+
+// SinkFunc 函数形式的 Sink
+type SinkFunc func(e *Event) error
+
+func (f SinkFunc) Write(e *Event) error { return f(e) }
+
+// Logger 将事件分发到多个 Sink
+type Logger struct {
+	sinks []Sink
+	now   func() time.Time
+}
+
+// NewLogger 返回输出到 sinks 的 Logger
+func NewLogger(sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks, now: time.Now}
+}
+
+// Emit 填充时间、请求关联与主体字段后写出事件，Sink 错误记录到 zap
+func (l *Logger) Emit(ctx context.Context, e Event) {
+	if l == nil || len(l.sinks) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	if ctx != nil {
+		fill(ctx, &e)
+	}
+	for _, sink := range l.sinks {
+		if err := sink.Write(&e); err != nil {
+			zap.L().Error("audit sink failed", zap.String("type", string(e.Type)), zap.Error(err))
+		}
+	}
+}
+
+func fill(ctx context.Context, e *Event) {
+	if req, ok := RequestFromContext(ctx); ok {
+		if e.RequestID == "" {
+			e.RequestID = req.ID
+		}
+		if e.RemoteIP == "" {
+			e.RemoteIP = req.RemoteIP
+		}
+		if e.UserAgent == "" {
+			e.UserAgent = req.UserAgent
+		}
+		if e.HTTP == "" {
+			e.HTTP = req.Method + " " + req.Path
+		}
+	}
+	if p, ok := principal.FromContext(ctx); ok && e.UserID == 0 && e.Account == "" {
+		e.UserID, e.Account = p.ID, p.Account
+		if e.Method == "" {
+			e.Method = p.Method
+		}
+		if e.Tenant == "" {
+			e.Tenant = p.Tenant
+		}
+	}
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+// SetDefault 设置全局 Logger，nil 时关闭审计
+func SetDefault(l *Logger) { defaultLogger.Store(l) }
+
+// L 返回全局 Logger，未设置时为 nil（Emit 为空操作）
+func L() *Logger { return defaultLogger.Load() }
+
+// Emit 通过全局 Logger 写出事件
+func Emit(ctx context.Context, e Event) { L().Emit(ctx, e) }
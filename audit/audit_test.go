@@ -0,0 +1,145 @@
+package audit_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+)
+
+func TestLoggerFillsCorrelation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ch := make(chan audit.Event, 1)
+	logger := audit.NewLogger(audit.NewChanSink(ch))
+
+	router := gin.New()
+	router.Use(audit.Mw())
+	router.POST("/orders", func(c *gin.Context) {
+		ctx := principal.NewContext(c.Request.Context(), &principal.Principal{
+			ID: 7, Account: "alice", Tenant: "acme", Method: principal.MethodSession,
+		})
+		logger.Emit(ctx, audit.Event{Type: audit.AccessDenied, Reason: "insufficient_role"})
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	r.Header.Set("X-Request-ID", "req-1")
+	r.Header.Set("User-Agent", "audit-test")
+	router.ServeHTTP(w, r)
+
+	e := <-ch
+	if e.RequestID != "req-1" || w.Header().Get("X-Request-ID") != "req-1" {
+		t.Fatalf("request id: %q, header %q", e.RequestID, w.Header().Get("X-Request-ID"))
+	}
+	if e.UserID != 7 || e.Account != "alice" || e.Tenant != "acme" || e.Method != principal.MethodSession {
+		t.Fatalf("principal fields: %+v", e)
+	}
+	if e.HTTP != "POST /orders" || e.UserAgent != "audit-test" || e.RemoteIP == "" || e.Time.IsZero() {
+		t.Fatalf("request fields: %+v", e)
+	}
+}
+
+func TestMiddlewareRejectsUnsafeRequestID(t *testing.T) {
+	var got audit.Request
+	handler := audit.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = audit.RequestFromContext(r.Context())
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "forged\nline")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if got.ID == "" || got.ID == "forged\nline" {
+		t.Fatalf("unsafe request id accepted: %q", got.ID)
+	}
+}
+
+func TestChanSinkDrops(t *testing.T) {
+	sink := audit.NewChanSink(make(chan audit.Event, 1))
+	logger := audit.NewLogger(sink)
+	logger.Emit(context.Background(), audit.Event{Type: audit.Logout})
+	logger.Emit(context.Background(), audit.Event{Type: audit.Logout})
+	if sink.Dropped() != 1 {
+		t.Fatalf("dropped = %d", sink.Dropped())
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	sink, err := audit.NewFileSink(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	logger := audit.NewLogger(sink)
+	for range 10 {
+		logger.Emit(context.Background(), audit.Event{Type: audit.LoginSucceeded, UserID: 1, Session: audit.TokenID("token")})
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var e audit.Event
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Type != audit.LoginSucceeded {
+				t.Fatalf("bad line in %s: %s", name, scanner.Text())
+			}
+		}
+		file.Close()
+		if info, _ := os.Stat(name); info.Size() > 200 {
+			t.Fatalf("%s exceeds max size: %d", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("too many backups kept: %v", err)
+	}
+}
+
+func TestFileSinkRotateFailureKeepsWriting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := audit.NewFileSink(path, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	// path.1 为非空目录，重命名失败
+	if err := os.MkdirAll(filepath.Join(path+".1", "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	event := &audit.Event{Type: audit.LoginSucceeded, UserID: 1}
+	if err := sink.Write(event); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(event); err == nil {
+		t.Fatal("rotate failure not reported")
+	}
+	if buf, _ := os.ReadFile(path); len(bytes.Split(bytes.TrimSpace(buf), []byte("\n"))) != 2 {
+		t.Fatalf("event dropped after rotate failure: %q", buf)
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(event); err != nil {
+		t.Fatalf("sink not recovered: %v", err)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("rotation did not resume: %v", err)
+	}
+}
+
+func TestTokenID(t *testing.T) {
+	id := audit.TokenID("0123456789abcdef0123456789abcdef01234567")
+	if len(id) != 16 || id == audit.TokenID("other") || audit.TokenID("") != "" {
+		t.Fatalf("unexpected token id %q", id)
+	}
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileSink 以 JSON Lines 写入文件，超过 maxSize 时轮换为 path.1、path.2 …
+type FileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// NewFileSink 打开或创建 path 追加写入，maxSize 为单个文件最大字节数（0 不轮换），maxBackups 为保留的历史文件数
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Write(e *Event) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	var rotateErr error
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(buf)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			rotateErr = fmt.Errorf("audit: rotate %s: %w", s.path, err)
+			// 轮换失败时继续写入当前文件，无法重新打开时才丢弃
+			if s.file == nil {
+				return rotateErr
+			}
+		}
+	}
+	n, err := s.file.Write(buf)
+	s.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+// Close 关闭文件
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// rotate 依次将 path.N-1 重命名为 path.N，当前文件重命名为 path.1 后重新打开
+//
+// 任一步骤失败时仍重新打开 path 追加写入，返回轮换错误。
+func (s *FileSink) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+	if err == nil {
+		err = s.shift()
+	}
+	if openErr := s.open(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+// shift 移动历史文件，maxBackups 为 0 时直接删除当前文件
+func (s *FileSink) shift() error {
+	if s.maxBackups <= 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	_ = os.Remove(backupName(s.path, s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupName(s.path, i), backupName(s.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(s.path, backupName(s.path, 1))
+}
+
+func backupName(path string, i int) string { return fmt.Sprintf("%s.%d", path, i) }
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRequestIDHeader 默认的请求ID头
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// Request 请求关联信息
+type Request struct {
+	ID        string
+	RemoteIP  string
+	UserAgent string
+	Method    string
+	Path      string
+}
+
+type ctxKey struct{}
+
+// WithRequest 返回携带请求关联信息的 context
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, ctxKey{}, req)
+}
+
+// RequestFromContext 获取 context 中的请求关联信息
+func RequestFromContext(ctx context.Context) (Request, bool) {
+	req, ok := ctx.Value(ctxKey{}).(Request)
+	return req, ok
+}
+
+// Mw 请求关联中间件 - 读取或生成请求ID并写入响应头，需挂在认证中间件之前
+//
+// header 为请求ID头，默认 X-Request-ID。gin 中 RemoteIP 取 c.ClientIP()，受 TrustedProxies 控制。
+func Mw(header ...string) gin.HandlerFunc {
+	name := requestIDHeader(header)
+	return func(c *gin.Context) {
+		req := newRequest(c.Request, name)
+		req.RemoteIP = c.ClientIP()
+		c.Header(name, req.ID)
+		c.Request = c.Request.WithContext(WithRequest(c.Request.Context(), req))
+		c.Next()
+	}
+}
+
+// Middleware net/http 版本的 Mw，RemoteIP 取 r.RemoteAddr
+func Middleware(header ...string) func(http.Handler) http.Handler {
+	name := requestIDHeader(header)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := newRequest(r, name)
+			w.Header().Set(name, req.ID)
+			next.ServeHTTP(w, r.WithContext(WithRequest(r.Context(), req)))
+		})
+	}
+}
+
+func requestIDHeader(header []string) string {
+	if len(header) > 0 && header[0] != "" {
+		return header[0]
+	}
+	return DefaultRequestIDHeader
+}
+
+func newRequest(r *http.Request, header string) Request {
+	id := strings.TrimSpace(r.Header.Get(header))
+	// 客户端传入的请求ID只接受可打印字符，避免日志注入
+	if len(id) > 128 || strings.ContainsFunc(id, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		id = ""
+	}
+	if id == "" {
+		id = strings.ToLower(rand.Text())
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return Request{
+		ID:        id,
+		RemoteIP:  host,
+		UserAgent: r.UserAgent(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+	}
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// ZapSink 以 Info 级别写入 zap，logger 为 nil 时使用 zap.L()
+func ZapSink(logger *zap.Logger) Sink {
+	return SinkFunc(func(e *Event) error {
+		l := logger
+		if l == nil {
+			l = zap.L()
+		}
+		fields := []zap.Field{
+			zap.String("type", string(e.Type)),
+			zap.Time("time", e.Time),
+		}
+		add := func(key, val string) {
+			if val != "" {
+				fields = append(fields, zap.String(key, val))
+			}
+		}
+		if e.UserID != 0 {
+			fields = append(fields, zap.Uint64("user_id", e.UserID))
+		}
+		add("method", e.Method)
+		add("account", e.Account)
+		add("tenant", e.Tenant)
+		add("session", e.Session)
+		add("provider", e.Provider)
+		add("reason", e.Reason)
+		add("request_id", e.RequestID)
+		add("remote_ip", e.RemoteIP)
+		add("user_agent", e.UserAgent)
+		add("http", e.HTTP)
+		if len(e.Fields) > 0 {
+			fields = append(fields, zap.Any("fields", e.Fields))
+		}
+		l.Info("audit", fields...)
+		return nil
+	})
+}
+
+// ChanSink 将事件副本非阻塞地发送到 channel，channel 已满时丢弃
+type ChanSink struct {
+	ch      chan<- Event
+	dropped atomic.Uint64
+}
+
+var _ Sink = (*ChanSink)(nil)
+
+// NewChanSink 返回发送到 ch 的 Sink，用于异步转发到消息队列或测试
+func NewChanSink(ch chan<- Event) *ChanSink { return &ChanSink{ch: ch} }
+
+func (s *ChanSink) Write(e *Event) error {
+	select {
+	case s.ch <- *e:
+	default:
+		s.dropped.Add(1)
+	}
+	return nil
+}
+
+// Dropped 因 channel 已满丢弃的事件数量
+func (s *ChanSink) Dropped() uint64 { return s.dropped.Load() }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+
+	"github.com/mulan-ext/auth/apikey"
+)
+
+const apikeyUsage = `Usage: authctl apikey <command> [flags]
+
+Commands:
+  generate [--alg sha256|argon2id|bcrypt]   print a new key and its apikey.hashes entry
+  hash KEY [--alg ...] [--id ID]            print the apikey.hashes entry of an existing key
+
+The key is printed only once; store the entry in config (--apikey.hashes).
+`
+
+func runAPIKey(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stdout, apikeyUsage)
+		return nil
+	}
+	cmd, args := args[0], args[1:]
+	fs := pflag.NewFlagSet("apikey", pflag.ContinueOnError)
+	fs.SortFlags = false
+	alg := fs.String("alg", string(apikey.SHA256), "hash algorithm: sha256, argon2id or bcrypt")
+	var id string
+	if cmd == "hash" {
+		fs.StringVar(&id, "id", "", "key ID, defaults to the part before the first '.'")
+	}
+	fs.SetOutput(stdout)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch cmd {
+	case "generate":
+		if fs.NArg() != 0 {
+			return fmt.Errorf("apikey generate: unexpected arguments %v", fs.Args())
+		}
+		key, entry, err := apikey.GenerateKey(apikey.Algorithm(*alg))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "key:   %s\nentry: %s\n", key, entry)
+		return nil
+	case "hash":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("apikey hash: want exactly one KEY")
+		}
+		key := fs.Arg(0)
+		hash, err := apikey.HashKey(key, apikey.Algorithm(*alg))
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			id = apikey.KeyID(key)
+		}
+		if id != "" {
+			hash = id + ":" + hash
+		}
+		fmt.Fprintln(stdout, hash)
+		return nil
+	default:
+		return fmt.Errorf("unknown apikey command %q", cmd)
+	}
+}
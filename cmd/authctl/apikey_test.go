@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mulan-ext/auth/apikey"
+)
+
+func TestAPIKeyGenerate(t *testing.T) {
+	out := runCLI(t, "", "apikey", "generate")
+	var key, entry string
+	for line := range strings.SplitSeq(out, "\n") {
+		if v, ok := strings.CutPrefix(line, "key:"); ok {
+			key = strings.TrimSpace(v)
+		}
+		if v, ok := strings.CutPrefix(line, "entry:"); ok {
+			entry = strings.TrimSpace(v)
+		}
+	}
+	if key == "" || entry == "" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if got := runCLI(t, "", "apikey", "hash", key); strings.TrimSpace(got) != entry {
+		t.Fatalf("hash output %q, want %q", got, entry)
+	}
+	handler := apikey.Middleware(&apikey.Config{Hashes: []string{entry}})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("apikey", key)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("generated key rejected: %d", w.Code)
+	}
+}
@@ -0,0 +1,40 @@
+// Command authctl 运维工具：检查、迁移和清理认证数据
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `Usage: authctl <command> [flags]
+
+Commands:
+  apikey     generate and hash API keys
+  session    inspect, migrate and purge sessions
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "authctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	switch args[0] {
+	case "apikey":
+		return runAPIKey(args[1:], stdout)
+	case "session":
+		return runSession(args[1:], stdin, stdout)
+	case "-h", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/mulan-ext/auth/flagenv"
+	"github.com/mulan-ext/auth/session"
+)
+
+const sessionUsage = `Usage: authctl session <command> [flags]
+
+Commands:
+  export [--out FILE]            write sessions as JSON lines
+  import [--in FILE]             read JSON lines into the store
+  migrate --to.session.*=...     copy sessions to another driver, preserving expiry
+  purge                          delete expired sessions
+  count                          count live sessions
+  show TOKEN | --user ID         print a session, or every live session of a user
+  revoke TOKEN... | --user ID    delete sessions by token or user ID
+
+show and revoke accept --tenant NAME for tenant-bound sessions; with --user
+only sessions of that tenant match (none given: sessions without a tenant).
+
+The store is configured with the same --session.* flags as session.Init,
+or the matching environment variables (SESSION_DRIVER, TO_SESSION_DRIVER, ...).
+Sessions without expiry receive the target store's default TTL on import.
+`
+
+// record 导出的单条Session（JSON lines 格式）
+type record struct {
+	Token  string               `json:"token"`
+	Tenant string               `json:"tenant,omitempty"`
+	Data   *session.DefaultData `json:"data"`
+	Expire time.Time            `json:"expire,omitzero"`
+}
+
+func runSession(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stdout, sessionUsage)
+		return nil
+	}
+	cmd, args := args[0], args[1:]
+	// 仅组件参数读取环境变量，命令自身参数不受影响
+	envFlags := session.FlagSet()
+	fs := pflag.NewFlagSet("session", pflag.ContinueOnError)
+	fs.SortFlags = false
+	var (
+		in, out, tenant string
+		user            uint64
+	)
+	switch cmd {
+	case "export":
+		fs.StringVar(&out, "out", "-", "output file, - for stdout")
+	case "import":
+		fs.StringVar(&in, "in", "-", "input file, - for stdin")
+	case "migrate":
+		envFlags.AddFlagSet(targetFlagSet())
+	case "show":
+		fs.Uint64Var(&user, "user", 0, "print every live session of this user ID")
+		fs.StringVar(&tenant, "tenant", "", "tenant of the session or user")
+	case "revoke":
+		fs.Uint64Var(&user, "user", 0, "revoke every session of this user ID")
+		fs.StringVar(&tenant, "tenant", "", "tenant of the sessions or user")
+	case "purge", "count":
+	default:
+		return fmt.Errorf("unknown session command %q", cmd)
+	}
+	fs.AddFlagSet(envFlags)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := flagenv.Apply(envFlags, ""); err != nil {
+		return err
+	}
+
+	if tenant != "" && !session.ValidTenant(tenant) {
+		return session.ErrInvalidTenant
+	}
+	ctx := context.Background()
+	store, err := openStore(fs, "")
+	if err != nil {
+		return err
+	}
+	switch cmd {
+	case "export":
+		w, closeFn, err := createOutput(out, stdout)
+		if err != nil {
+			return err
+		}
+		n, err := exportSessions(ctx, store, w)
+		if cerr := closeFn(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if out != "-" {
+			fmt.Fprintf(stdout, "exported %d sessions\n", n)
+		}
+		return nil
+	case "import":
+		r := stdin
+		if in != "-" {
+			f, err := os.Open(in)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		n, err := importSessions(ctx, store, r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "imported %d sessions\n", n)
+	case "migrate":
+		target, err := openStore(fs, targetPrefix)
+		if err != nil {
+			return fmt.Errorf("target: %w", err)
+		}
+		n, err := migrateSessions(ctx, store, target)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "migrated %d sessions\n", n)
+	case "purge":
+		n, err := session.PurgeExpired(ctx, store)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "purged %d sessions\n", n)
+	case "count":
+		n, err := session.Count(ctx, store)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, n)
+	case "show":
+		if user != 0 {
+			if fs.NArg() != 0 {
+				return errors.New("show accepts a token or --user, not both")
+			}
+			return showUserSessions(ctx, store, tenant, user, stdout)
+		}
+		if fs.NArg() != 1 {
+			return errors.New("show requires exactly one token or --user")
+		}
+		return showSession(ctx, store, tenant, fs.Arg(0), stdout)
+	case "revoke":
+		if user == 0 && fs.NArg() == 0 {
+			return errors.New("revoke requires a token or --user")
+		}
+		n := 0
+		tctx := session.WithTenant(ctx, tenant)
+		for _, token := range fs.Args() {
+			// 只统计确实存在的session
+			if _, err := store.Get(tctx, token); err != nil {
+				if errors.Is(err, session.ErrTokenNotFound) || errors.Is(err, session.ErrTokenExpired) {
+					continue
+				}
+				return err
+			}
+			if err := store.Clear(tctx, token); err != nil {
+				return err
+			}
+			n++
+		}
+		if user != 0 {
+			deleted, err := session.DeleteWhere(ctx, store, func(e session.Entry) bool { return e.Data.ID() == user && e.Tenant == tenant })
+			if err != nil {
+				return err
+			}
+			n += deleted
+		}
+		fmt.Fprintf(stdout, "revoked %d sessions\n", n)
+	}
+	return nil
+}
+
+const targetPrefix = "to."
+
+// targetFlagSet 迁移目标Store的参数，与 session.FlagSet 相同但带 "to." 前缀
+func targetFlagSet() *pflag.FlagSet {
+	target := pflag.NewFlagSet("target", pflag.ContinueOnError)
+	session.FlagSet().VisitAll(func(f *pflag.Flag) {
+		target.AddFlag(&pflag.Flag{
+			Name:     targetPrefix + f.Name,
+			Usage:    "target " + f.Usage,
+			Value:    f.Value,
+			DefValue: f.DefValue,
+		})
+	})
+	return target
+}
+
+func openStore(fs *pflag.FlagSet, prefix string) (session.Store, error) {
+	cfg := &session.Config{}
+	if err := flagenv.Bind(fs, prefix+"session", cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Driver {
+	case "", session.DriverMemory, session.DriverSharded:
+		return nil, fmt.Errorf("driver %q is process-local and cannot be inspected", cfg.Driver)
+	}
+	return session.NewStore(cfg)
+}
+
+func createOutput(name string, stdout io.Writer) (io.Writer, func() error, error) {
+	if name == "-" {
+		return stdout, func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// exportSessions 将未过期的Session以 JSON lines 写出
+func exportSessions(ctx context.Context, store session.Store, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	now := time.Now()
+	n := 0
+	err := session.Walk(ctx, store, session.DefaultScanCount, func(e session.Entry) error {
+		if e.Expired(now) {
+			return nil
+		}
+		n++
+		return enc.Encode(newRecord(e))
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// importSessions 读取 JSON lines 写入Store，跳过已过期的记录
+func importSessions(ctx context.Context, store session.Store, r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	now := time.Now()
+	n := 0
+	for {
+		var rec record
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		if rec.Data == nil || rec.Token == "" {
+			return n, fmt.Errorf("record %d: token and data are required", n+1)
+		}
+		rec.Data.SetToken(rec.Token)
+		if rec.Tenant != "" && rec.Data.Get(session.ItemTenant) == nil {
+			rec.Data.SetValues(session.ItemTenant, rec.Tenant)
+		}
+		if !rec.Expire.IsZero() && !rec.Expire.After(now) {
+			continue
+		}
+		if err := store.Save(ctx, rec.Data, lifetime(rec.Expire, now)...); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// migrateSessions 复制未过期的Session到目标Store，保留剩余有效期
+func migrateSessions(ctx context.Context, src, dst session.Store) (int, error) {
+	now := time.Now()
+	n := 0
+	err := session.Walk(ctx, src, session.DefaultScanCount, func(e session.Entry) error {
+		if e.Expired(now) {
+			return nil
+		}
+		if err := dst.Save(ctx, newRecord(e).Data, lifetime(e.Expire, now)...); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func showSession(ctx context.Context, store session.Store, tenant, token string, stdout io.Writer) error {
+	data, err := store.Get(session.WithTenant(ctx, tenant), token)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newRecord(session.Entry{Token: token, Tenant: tenant, Data: data}))
+}
+
+// showUserSessions 输出租户内用户全部未过期的Session，与 revoke --user 使用相同的筛选
+func showUserSessions(ctx context.Context, store session.Store, tenant string, user uint64, stdout io.Writer) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	now := time.Now()
+	return session.Walk(ctx, store, session.DefaultScanCount, func(e session.Entry) error {
+		if e.Expired(now) || e.Data.ID() != user || e.Tenant != tenant {
+			return nil
+		}
+		return enc.Encode(newRecord(e))
+	})
+}
+
+func newRecord(e session.Entry) record {
+	data := &session.DefaultData{}
+	data.SetToken(e.Token)
+	data.SetID(e.Data.ID())
+	data.SetAccount(e.Data.Account())
+	data.SetState(e.Data.State())
+	data.SetRoles(e.Data.Roles())
+	for k, v := range e.Data.Items() {
+		data.SetValues(k, v)
+	}
+	if e.Tenant != "" {
+		data.SetValues(session.ItemTenant, e.Tenant)
+	}
+	return record{Token: e.Token, Tenant: e.Tenant, Data: data, Expire: e.Expire}
+}
+
+func lifetime(expire, now time.Time) []time.Duration {
+	if expire.IsZero() {
+		return nil
+	}
+	return []time.Duration{expire.Sub(now)}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mulan-ext/auth/session"
+)
+
+func seedFsStore(t *testing.T, dir string) (*session.FsStore, []string) {
+	t.Helper()
+	store, err := session.NewFsStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	for i := range 4 {
+		data := &session.DefaultData{}
+		data.SetID(uint64(i%2 + 1))
+		data.SetAccount("user")
+		data.SetValues("locale", "zh-CN")
+		tokens = append(tokens, data.New())
+		if err := store.Save(context.Background(), data, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired := &session.DefaultData{}
+	expired.New()
+	if err := store.Save(context.Background(), expired, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	return store, tokens
+}
+
+func runCLI(t *testing.T, stdin string, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(args, strings.NewReader(stdin), &out); err != nil {
+		t.Fatalf("authctl %v: %v", args, err)
+	}
+	return out.String()
+}
+
+func TestSessionExportImportRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	_, tokens := seedFsStore(t, src)
+
+	exported := runCLI(t, "", "session", "export", "--session.driver=fs", "--session.dir="+src)
+	if lines := strings.Count(exported, "\n"); lines != len(tokens) {
+		t.Fatalf("expected %d exported sessions, got %d:\n%s", len(tokens), lines, exported)
+	}
+
+	dst := t.TempDir()
+	out := runCLI(t, exported, "session", "import", "--session.driver=fs", "--session.dir="+dst)
+	if !strings.Contains(out, "imported 4 sessions") {
+		t.Fatalf("unexpected import output: %q", out)
+	}
+	out = runCLI(t, "", "session", "show", tokens[0], "--session.driver=fs", "--session.dir="+dst)
+	if !strings.Contains(out, `"locale": "zh-CN"`) {
+		t.Fatalf("imported session lost items: %s", out)
+	}
+}
+
+func TestSessionMigratePreservesExpiry(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	seedFsStore(t, src)
+
+	out := runCLI(t, "", "session", "migrate",
+		"--session.driver=fs", "--session.dir="+src,
+		"--to.session.driver=fs", "--to.session.dir="+dst)
+	if !strings.Contains(out, "migrated 4 sessions") {
+		t.Fatalf("unexpected migrate output: %q", out)
+	}
+
+	target, err := session.NewFsStore(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.Walk(context.Background(), target, 0, func(e session.Entry) error {
+		if remaining := time.Until(e.Expire); remaining > time.Hour || remaining < 50*time.Minute {
+			t.Errorf("expiry not preserved for %s: %v", e.Token, remaining)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionPurgeAndRevoke(t *testing.T) {
+	dir := t.TempDir()
+	store, tokens := seedFsStore(t, dir)
+	flags := []string{"--session.driver=fs", "--session.dir=" + dir}
+
+	if out := runCLI(t, "", append([]string{"session", "purge"}, flags...)...); !strings.Contains(out, "purged 1 sessions") {
+		t.Fatalf("unexpected purge output: %q", out)
+	}
+	if out := runCLI(t, "", append([]string{"session", "revoke", "--user=1"}, flags...)...); !strings.Contains(out, "revoked 2 sessions") {
+		t.Fatalf("unexpected revoke output: %q", out)
+	}
+	runCLI(t, "", append([]string{"session", "revoke", tokens[1]}, flags...)...)
+	if out := runCLI(t, "", append([]string{"session", "count"}, flags...)...); strings.TrimSpace(out) != "1" {
+		t.Fatalf("expected 1 remaining session, got %q", out)
+	}
+	if _, err := store.Get(context.Background(), tokens[3]); err != nil {
+		t.Fatalf("unrelated session was removed: %v", err)
+	}
+}
+
+func TestSessionShowByUser(t *testing.T) {
+	dir := t.TempDir()
+	_, tokens := seedFsStore(t, dir)
+	flags := []string{"--session.driver=fs", "--session.dir=" + dir}
+
+	out := runCLI(t, "", append([]string{"session", "show", "--user=2"}, flags...)...)
+	if strings.Count(out, `"expire"`) != 2 || !strings.Contains(out, tokens[1]) || !strings.Contains(out, tokens[3]) {
+		t.Fatalf("expected the two sessions of user 2, got:\n%s", out)
+	}
+	if strings.Contains(out, tokens[0]) {
+		t.Fatalf("session of another user listed:\n%s", out)
+	}
+	if err := run(append([]string{"session", "show", tokens[0], "--user=2"}, flags...), nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error when combining a token with --user")
+	}
+}
+
+func TestSessionRejectsMemoryDriver(t *testing.T) {
+	if err := run([]string{"session", "count"}, nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected memory driver to be rejected")
+	}
+}
+
+var registerTestDriver sync.Once
+
+// testDriverStore 以已注册驱动的方式提供进程内Store，用于跨驱动迁移
+var testDriverStore = session.NewMemStore()
+
+func TestSessionMigrateTenantSessions(t *testing.T) {
+	registerTestDriver.Do(func() {
+		session.RegisterDriver("authctl-test", func(*session.Config) (session.Store, error) { return testDriverStore, nil })
+	})
+	ctx := context.Background()
+	src, back := t.TempDir(), t.TempDir()
+	store, _ := seedFsStore(t, src)
+	data := &session.DefaultData{}
+	data.SetID(5)
+	data.SetValues(session.ItemTenant, "acme")
+	token := data.New()
+	if err := store.Save(ctx, data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	acme := session.WithTenant(ctx, "acme")
+
+	out := runCLI(t, "", "session", "migrate",
+		"--session.driver=fs", "--session.dir="+src, "--to.session.driver=authctl-test")
+	if !strings.Contains(out, "migrated 5 sessions") {
+		t.Fatalf("unexpected migrate output: %q", out)
+	}
+	if got, err := testDriverStore.Get(acme, token); err != nil || got.ID() != 5 {
+		t.Fatalf("tenant session lost in fs -> memory migration: %v", err)
+	}
+	runCLI(t, "", "session", "migrate",
+		"--session.driver=authctl-test", "--to.session.driver=fs", "--to.session.dir="+back)
+	target, err := session.NewFsStore(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := target.Get(acme, token); err != nil || got.ID() != 5 {
+		t.Fatalf("tenant session lost in memory -> fs migration: %v", err)
+	}
+
+	flags := []string{"--session.driver=fs", "--session.dir=" + src}
+	exported := runCLI(t, "", append([]string{"session", "export"}, flags...)...)
+	if !strings.Contains(exported, `"token":"`+token+`","tenant":"acme"`) {
+		t.Fatalf("export lost token or tenant:\n%s", exported)
+	}
+	if out := runCLI(t, "", append([]string{"session", "show", token, "--tenant=acme"}, flags...)...); !strings.Contains(out, `"id": 5`) {
+		t.Fatalf("show --tenant: %s", out)
+	}
+	if out := runCLI(t, "", append([]string{"session", "revoke", token}, flags...)...); !strings.Contains(out, "revoked 0 sessions") {
+		t.Fatalf("revoke without tenant counted a missing session: %q", out)
+	}
+	if out := runCLI(t, "", append([]string{"session", "revoke", "--user=5", "--tenant=acme"}, flags...)...); !strings.Contains(out, "revoked 1 sessions") {
+		t.Fatalf("revoke --user --tenant: %q", out)
+	}
+	if _, err := store.Get(acme, token); err == nil {
+		t.Fatal("tenant session not revoked")
+	}
+}
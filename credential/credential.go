@@ -0,0 +1,168 @@
+// Package credential 从请求中提取凭证
+//
+// session 与 apikey 中间件各自配置有序的 Chain，凭证来源（Header、Authorization 方案、Cookie、
+// 查询参数、表单字段或自定义函数）互不混用；同一请求的多个来源携带不同凭证时返回 ErrConflict。
+package credential
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrConflict 多个来源携带了不同的凭证
+var ErrConflict = errors.New("credential: conflicting credentials")
+
+// Extractor 一个凭证来源
+type Extractor interface {
+	// Extract 返回去除首尾空白的凭证，未携带时返回空
+	Extract(r *http.Request) string
+	// Source 来源描述，格式同 Parse，用于日志
+	Source() string
+}
+
+type extractor struct {
+	source  string
+	extract func(r *http.Request) string
+}
+
+func (e extractor) Extract(r *http.Request) string { return strings.TrimSpace(e.extract(r)) }
+func (e extractor) Source() string                 { return e.source }
+
+// Func 自定义来源，source 用于日志
+func Func(source string, fn func(r *http.Request) string) Extractor {
+	return extractor{source: source, extract: fn}
+}
+
+// Header 从请求头 name 提取
+func Header(name string) Extractor {
+	return Func("header:"+name, func(r *http.Request) string { return r.Header.Get(name) })
+}
+
+// Scheme 从 "Authorization: <scheme> <credential>" 提取，scheme 不区分大小写，其他方案不匹配
+func Scheme(scheme string) Extractor {
+	return Func("scheme:"+scheme, func(r *http.Request) string {
+		got, value, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+		if !ok || !strings.EqualFold(got, scheme) {
+			return ""
+		}
+		return value
+	})
+}
+
+// Bearer 即 Scheme("Bearer")
+func Bearer() Extractor { return Scheme("Bearer") }
+
+// Cookie 从 Cookie name 提取，值按 URL 编码解码
+func Cookie(name string) Extractor {
+	return Func("cookie:"+name, func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		value, err := url.QueryUnescape(cookie.Value)
+		if err != nil {
+			return ""
+		}
+		return value
+	})
+}
+
+// Query 从查询参数 name 提取；URL 会出现在访问日志与 Referer 中，只应用于无法设置请求头的场景
+func Query(name string) Extractor {
+	return Func("query:"+name, func(r *http.Request) string { return r.URL.Query().Get(name) })
+}
+
+// Form 从表单字段 name 提取，只读取 POST/PUT/PATCH 的 urlencoded 或 multipart 请求体
+func Form(name string) Extractor {
+	return Func("form:"+name, func(r *http.Request) string {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			return ""
+		}
+		ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if ct != "application/x-www-form-urlencoded" && ct != "multipart/form-data" {
+			return ""
+		}
+		return r.PostFormValue(name)
+	})
+}
+
+// Parse 解析来源描述："header:<name>"、"bearer"、"scheme:<scheme>"、"cookie:<name>"、"query:<name>"、"form:<name>"
+func Parse(spec string) (Extractor, error) {
+	kind, name, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	name = strings.TrimSpace(name)
+	if kind == "bearer" && name == "" {
+		return Bearer(), nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("credential: invalid source %q", spec)
+	}
+	switch kind {
+	case "header":
+		return Header(name), nil
+	case "scheme":
+		return Scheme(name), nil
+	case "cookie":
+		return Cookie(name), nil
+	case "query":
+		return Query(name), nil
+	case "form":
+		return Form(name), nil
+	default:
+		return nil, fmt.Errorf("credential: invalid source %q", spec)
+	}
+}
+
+// Chain 有序的凭证来源
+type Chain []Extractor
+
+// ParseChain 按顺序解析来源描述，见 Parse
+func ParseChain(specs []string) (Chain, error) {
+	chain := make(Chain, 0, len(specs))
+	for _, spec := range specs {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		e, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, e)
+	}
+	return chain, nil
+}
+
+// Extract 依次检查全部来源，返回第一个凭证及其来源；其他来源携带不同凭证时返回 ErrConflict
+func (c Chain) Extract(r *http.Request) (value, source string, err error) {
+	return c.ExtractIf(r, nil)
+}
+
+// ExtractIf 同 Extract，但只考虑 accept 返回 true 的值，格式不符的值（如其他类型的凭证）被忽略
+func (c Chain) ExtractIf(r *http.Request, accept func(string) bool) (value, source string, err error) {
+	for _, e := range c {
+		v := e.Extract(r)
+		if v == "" || accept != nil && !accept(v) {
+			continue
+		}
+		if value == "" {
+			value, source = v, e.Source()
+		} else if v != value {
+			return "", "", fmt.Errorf("%w (%s, %s)", ErrConflict, source, e.Source())
+		}
+	}
+	return value, source, nil
+}
+
+// Sources 返回各来源描述
+func (c Chain) Sources() []string {
+	sources := make([]string, len(c))
+	for i, e := range c {
+		sources[i] = e.Source()
+	}
+	return sources
+}
@@ -0,0 +1,92 @@
+package credential_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mulan-ext/auth/credential"
+)
+
+func TestExtractors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/?access_token=q1", strings.NewReader(url.Values{"token": {"f1"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Token", " h1 ")
+	req.Header.Set("Authorization", "bearer b1")
+	req.AddCookie(&http.Cookie{Name: "token", Value: url.QueryEscape("c 1")})
+
+	cases := []struct {
+		e    credential.Extractor
+		want string
+	}{
+		{credential.Header("X-Token"), "h1"},
+		{credential.Header("X-Missing"), ""},
+		{credential.Bearer(), "b1"},
+		{credential.Scheme("ApiKey"), ""},
+		{credential.Cookie("token"), "c 1"},
+		{credential.Query("access_token"), "q1"},
+		{credential.Form("token"), "f1"},
+		{credential.Func("custom", func(r *http.Request) string { return r.URL.Path }), "/"},
+	}
+	for _, tc := range cases {
+		if got := tc.e.Extract(req); got != tc.want {
+			t.Errorf("%s: got %q want %q", tc.e.Source(), got, tc.want)
+		}
+	}
+
+	get := httptest.NewRequest(http.MethodGet, "/?token=q", nil)
+	if got := credential.Form("token").Extract(get); got != "" {
+		t.Errorf("form must not read GET requests, got %q", got)
+	}
+	basic := httptest.NewRequest(http.MethodGet, "/", nil)
+	basic.SetBasicAuth("user", "pass")
+	if got := credential.Bearer().Extract(basic); got != "" {
+		t.Errorf("bearer must not match Basic, got %q", got)
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := credential.Chain{credential.Header("X-Token"), credential.Bearer(), credential.Cookie("token")}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer t1")
+	req.AddCookie(&http.Cookie{Name: "token", Value: "t1"})
+	value, source, err := chain.Extract(req)
+	if err != nil || value != "t1" || source != "scheme:Bearer" {
+		t.Fatalf("got %q from %q, %v", value, source, err)
+	}
+
+	req.Header.Set("X-Token", "t2")
+	if _, _, err := chain.Extract(req); !errors.Is(err, credential.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	// 被 accept 忽略的值不参与冲突检测
+	value, source, err = chain.ExtractIf(req, func(v string) bool { return v != "t2" })
+	if err != nil || value != "t1" || source != "scheme:Bearer" {
+		t.Fatalf("got %q from %q, %v", value, source, err)
+	}
+
+	value, _, err = chain.Extract(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil || value != "" {
+		t.Fatalf("expected no credential, got %q, %v", value, err)
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	chain, err := credential.ParseChain([]string{"header:X-Token", "bearer", "scheme:ApiKey", "cookie:token", "query:t", "form:t", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"header:X-Token", "scheme:Bearer", "scheme:ApiKey", "cookie:token", "query:t", "form:t"}
+	if got := chain.Sources(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v want %v", got, want)
+	}
+	for _, spec := range []string{"header", "header:", "path:token", "bearer:x"} {
+		if _, err := credential.Parse(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package flagenv 将各认证组件的 FlagSet 绑定到环境变量并写入配置结构体
+//
+// 参数 session.rdb.pass 对应环境变量 SESSION_RDB_PASS；设置 SESSION_RDB_PASS_FILE
+// 时从该文件读取值（去除末尾换行），适用于以文件挂载的密钥。
+// 优先级：命令行参数 > 环境变量或 *_FILE > 默认值；二者互斥，同时设置时 Apply 返回错误。
+package flagenv
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// FileSuffix 密钥文件环境变量后缀
+const FileSuffix = "_FILE"
+
+// EnvName 返回参数对应的环境变量名，prefix 非空时作为前缀
+func EnvName(prefix, flag string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flag))
+	if prefix != "" {
+		name = strings.ToUpper(prefix) + "_" + name
+	}
+	return name
+}
+
+// Apply 为未在命令行显式设置的参数读取环境变量，应在 fs.Parse 之后调用
+func Apply(fs *pflag.FlagSet, prefix string) error {
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		var (
+			value string
+			ok    bool
+		)
+		if value, ok, err = lookup(EnvName(prefix, f.Name)); err != nil || !ok {
+			return
+		}
+		if err = fs.Set(f.Name, value); err != nil {
+			err = fmt.Errorf("flagenv: %s: %w", EnvName(prefix, f.Name), err)
+		}
+	})
+	return err
+}
+
+func lookup(env string) (string, bool, error) {
+	value, ok := os.LookupEnv(env)
+	file, fileOK := os.LookupEnv(env + FileSuffix)
+	switch {
+	case ok && fileOK:
+		return "", false, fmt.Errorf("flagenv: both %s and %s%s are set", env, env, FileSuffix)
+	case ok:
+		return value, true, nil
+	case fileOK:
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("flagenv: %s%s: %w", env, FileSuffix, err)
+		}
+		return strings.TrimRight(string(buf), "\r\n"), true, nil
+	}
+	return "", false, nil
+}
+
+// Bind 将名称以 prefix 开头的参数写入 cfg（结构体指针）
+//
+// 参数名去掉前缀后按 "." 分段、"-" 替换为 "_"，与字段的 json tag 对应，
+// 例如 oauth2.client-id 对应 Config.ClientID（json:"client_id"）。没有对应字段的参数会被忽略。
+func Bind(fs *pflag.FlagSet, prefix string, cfg any) error {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("flagenv: cfg must be a pointer to struct, got %T", cfg)
+	}
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil {
+			return
+		}
+		name, ok := strings.CutPrefix(f.Name, prefix+".")
+		if !ok {
+			return
+		}
+		field, ok := fieldByPath(root.Elem(), strings.Split(strings.ReplaceAll(name, "-", "_"), "."))
+		if !ok {
+			return
+		}
+		if err = setField(f, field); err != nil {
+			err = fmt.Errorf("flagenv: %s: %w", f.Name, err)
+		}
+	})
+	return err
+}
+
+func fieldByPath(v reflect.Value, path []string) (reflect.Value, bool) {
+	for _, part := range path {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		found := false
+		for i := range v.NumField() {
+			tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			if tag == part && v.Type().Field(i).IsExported() {
+				v, found = v.Field(i), true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
+
+func setField(f *pflag.Flag, field reflect.Value) error {
+	value := f.Value.String()
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Slice:
+		slice, ok := f.Value.(pflag.SliceValue)
+		if !ok || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("cannot assign %s flag to %s field", f.Value.Type(), field.Type())
+		}
+		field.Set(reflect.ValueOf(append([]string(nil), slice.GetSlice()...)).Convert(field.Type()))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package flagenv_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/mulan-ext/auth/apikey"
+	"github.com/mulan-ext/auth/flagenv"
+	"github.com/mulan-ext/auth/oidc"
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestEnvName(t *testing.T) {
+	if got := flagenv.EnvName("", "oidc.client-secret"); got != "OIDC_CLIENT_SECRET" {
+		t.Fatalf("unexpected env name %q", got)
+	}
+	if got := flagenv.EnvName("app", "session.rdb.pass"); got != "APP_SESSION_RDB_PASS" {
+		t.Fatalf("unexpected env name %q", got)
+	}
+}
+
+func TestApplyAndBind(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SESSION_DRIVER", "fs")
+	t.Setenv("SESSION_TTL", "3600")
+	t.Setenv("SESSION_RDB_PORT", "6380")
+	t.Setenv("OIDC_CLIENT_SECRET_FILE", secret)
+	t.Setenv("OIDC_SCOPES", "openid,email")
+	t.Setenv("APIKEY_NAME", "ignored-because-flag-wins")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.AddFlagSet(session.FlagSet())
+	fs.AddFlagSet(oidc.FlagSet())
+	fs.AddFlagSet(apikey.FlagSet())
+	if err := fs.Parse([]string{"--session.dir=/tmp/sessions", "--apikey.name=X-Key"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flagenv.Apply(fs, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var sess session.Config
+	if err := flagenv.Bind(fs, "session", &sess); err != nil {
+		t.Fatal(err)
+	}
+	if sess.Driver != "fs" || sess.TTL != 3600 || sess.Dir != "/tmp/sessions" || sess.RDB.Port != 6380 || sess.Name != "token" || !sess.HeaderOnly {
+		t.Fatalf("unexpected session config: %+v", sess)
+	}
+	if err := sess.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var oidcCfg oidc.Config
+	if err := flagenv.Bind(fs, "oidc", &oidcCfg); err != nil {
+		t.Fatal(err)
+	}
+	if oidcCfg.ClientSecret != "file-secret" {
+		t.Fatalf("secret file not applied: %q", oidcCfg.ClientSecret)
+	}
+	if !reflect.DeepEqual(oidcCfg.Scopes, []string{"openid", "email"}) {
+		t.Fatalf("unexpected scopes: %v", oidcCfg.Scopes)
+	}
+
+	var keyCfg apikey.Config
+	if err := flagenv.Bind(fs, "apikey", &keyCfg); err != nil {
+		t.Fatal(err)
+	}
+	if keyCfg.Name != "X-Key" {
+		t.Fatalf("command-line flag should win over environment: %q", keyCfg.Name)
+	}
+}
+
+func TestApplyRejectsAmbiguousSecret(t *testing.T) {
+	t.Setenv("OIDC_CLIENT_SECRET", "a")
+	t.Setenv("OIDC_CLIENT_SECRET_FILE", "/dev/null")
+	fs := oidc.FlagSet()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := flagenv.Apply(fs, ""); err == nil {
+		t.Fatal("expected error when both variable and _FILE are set")
+	}
+}
+
+func TestApplyRejectsInvalidValue(t *testing.T) {
+	t.Setenv("SESSION_TTL", "forever")
+	fs := session.FlagSet()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := flagenv.Apply(fs, ""); err == nil {
+		t.Fatal("expected parse error for invalid integer")
+	}
+}
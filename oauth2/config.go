@@ -34,6 +34,7 @@ type Config struct {
 	CookieSecure           bool              `json:"cookie_secure" yaml:"cookie_secure"`
 	StateTTL               int               `json:"state_ttl" yaml:"state_ttl"`
 	SuccessURL             string            `json:"success_url" yaml:"success_url"`
+	CarryOver              []string          `json:"carry_over" yaml:"carry_over"`
 	AllowInsecureEndpoints bool              `json:"allow_insecure_endpoints" yaml:"allow_insecure_endpoints"`
 }
 
@@ -54,6 +55,7 @@ func FlagSet() *pflag.FlagSet {
 	fs.Bool("oauth2.cookie-secure", false, "always mark OAuth2 state cookie Secure")
 	fs.Int("oauth2.state-ttl", int(DefaultStateTTL/time.Second), "OAuth2 state lifetime in seconds")
 	fs.String("oauth2.success-url", "/", "OAuth2 post-login redirect path")
+	fs.StringSlice("oauth2.carry-over", nil, "session item keys kept from the anonymous session at login")
 	fs.Bool("oauth2.allow-insecure-endpoints", false, "allow non-HTTPS OAuth2 endpoints")
 	return fs
 }
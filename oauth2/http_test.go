@@ -0,0 +1,73 @@
+package oauth2_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mulan-ext/auth/audit"
+	authoauth2 "github.com/mulan-ext/auth/oauth2"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestNetHTTPAuthorizationCodeFlow(t *testing.T) {
+	provider, _ := newOAuthProvider(t)
+	defer provider.Close()
+	_, client := newOAuthRouter(t, provider.URL)
+
+	mux := http.NewServeMux()
+	mux.Handle("/oauth2/login", client.LoginHTTP())
+	mux.Handle("/oauth2/callback", client.SessionCallbackHTTP())
+	mux.Handle("/me", session.AuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := principal.FromContext(r.Context())
+		_, _ = w.Write([]byte(p.Account))
+	})))
+	handler := session.Middleware("token", session.NewMemStore())(mux)
+
+	login := httptest.NewRecorder()
+	handler.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/oauth2/login?return_to=/home", nil))
+	if login.Code != http.StatusFound {
+		t.Fatalf("login status: got %d want %d", login.Code, http.StatusFound)
+	}
+	authURL, err := url.Parse(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback := httptest.NewRecorder()
+	callbackRequest := httptest.NewRequest(http.MethodGet, "/oauth2/callback?code=valid-code&state="+url.QueryEscape(authURL.Query().Get("state")), nil)
+	callbackRequest.AddCookie(responseCookie(t, login, authoauth2.DefaultCookieName))
+	handler.ServeHTTP(callback, callbackRequest)
+	if callback.Code != http.StatusFound || callback.Header().Get("Location") != "/home" {
+		t.Fatalf("callback: %d %q body=%s", callback.Code, callback.Header().Get("Location"), callback.Body.String())
+	}
+	token := callback.Result().Header.Get("X-Token")
+	if token == "" {
+		t.Fatal("callback did not create a session token")
+	}
+
+	me := httptest.NewRecorder()
+	meRequest := httptest.NewRequest(http.MethodGet, "/me", nil)
+	meRequest.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(me, meRequest)
+	if me.Code != http.StatusOK || me.Body.String() != "alice" {
+		t.Fatalf("authenticated request: %d %q", me.Code, me.Body.String())
+	}
+
+	events := make(chan audit.Event, 1)
+	audit.SetDefault(audit.NewLogger(audit.NewChanSink(events)))
+	defer audit.SetDefault(nil)
+	bad := httptest.NewRecorder()
+	handler.ServeHTTP(bad, httptest.NewRequest(http.MethodGet, "/oauth2/callback?code=x&state=y", nil))
+	if e := <-events; e.Type != audit.LoginFailed || e.Reason != "invalid_state" || e.Method != principal.MethodOAuth2 {
+		t.Fatalf("unexpected audit event: %+v", e)
+	}
+	if bad.Code != http.StatusBadRequest || bad.Header().Get("Content-Type") != problem.ContentType ||
+		!strings.Contains(bad.Body.String(), `"error":"invalid_state"`) {
+		t.Fatalf("invalid state: %d %s", bad.Code, bad.Body.String())
+	}
+}
@@ -18,6 +18,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	xoauth2 "golang.org/x/oauth2"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+	"github.com/mulan-ext/auth/session"
 )
 
 const (
@@ -68,11 +73,15 @@ type Identity struct {
 
 type CallbackFunc func(*gin.Context, *Identity)
 
+// HTTPCallbackFunc net/http 版本的 CallbackFunc
+type HTTPCallbackFunc func(http.ResponseWriter, *http.Request, *Identity)
+
 type Option func(*clientOptions)
 
 type clientOptions struct {
 	httpClient *http.Client
 	now        func() time.Time
+	carry      session.CarryOver
 }
 
 func WithHTTPClient(client *http.Client) Option {
@@ -84,6 +93,12 @@ func WithClock(now func() time.Time) Option {
 	return func(opts *clientOptions) { opts.now = now }
 }
 
+// WithCarryOver merges data from the pre-login session into the new one in
+// SessionCallback. It runs after Config.CarryOver keys have been copied.
+func WithCarryOver(policy session.CarryOver) Option {
+	return func(opts *clientOptions) { opts.carry = policy }
+}
+
 type Client struct {
 	config       Config
 	oauth2Config xoauth2.Config
@@ -91,6 +106,7 @@ type Client struct {
 	now          func() time.Time
 	stateTTL     time.Duration
 	cookieSecure bool
+	carry        []session.CarryOver
 }
 
 type statePayload struct {
@@ -134,6 +150,7 @@ func New(cfg *Config, options ...Option) (*Client, error) {
 	}
 	normalized.SuccessURL = safeReturnTo(normalized.SuccessURL)
 	normalized.Scopes = append([]string(nil), normalized.Scopes...)
+	normalized.CarryOver = append([]string(nil), normalized.CarryOver...)
 
 	redirect, _ := url.Parse(normalized.RedirectURL)
 	client := &Client{
@@ -142,6 +159,7 @@ func New(cfg *Config, options ...Option) (*Client, error) {
 		now:          opts.now,
 		stateTTL:     time.Duration(normalized.StateTTL) * time.Second,
 		cookieSecure: normalized.CookieSecure || redirect.Scheme == "https",
+		carry:        carryPolicies(normalized.CarryOver, opts.carry),
 		oauth2Config: xoauth2.Config{
 			ClientID:     normalized.ClientID,
 			ClientSecret: normalized.ClientSecret,
@@ -160,9 +178,27 @@ func New(cfg *Config, options ...Option) (*Client, error) {
 func (a *Client) Config() Config {
 	cfg := a.config
 	cfg.Scopes = append([]string(nil), a.config.Scopes...)
+	cfg.CarryOver = append([]string(nil), a.config.CarryOver...)
 	return cfg
 }
 
+// CarryOver returns the carry-over policies applied by SessionCallback, for
+// wrappers such as the oidc package that reuse this client's login flow.
+func (a *Client) CarryOver() []session.CarryOver {
+	return append([]session.CarryOver(nil), a.carry...)
+}
+
+func carryPolicies(keys []string, policy session.CarryOver) []session.CarryOver {
+	var policies []session.CarryOver
+	if len(keys) > 0 {
+		policies = append(policies, session.CarryKeys(keys...))
+	}
+	if policy != nil {
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
 func (a *Client) OAuth2Config() xoauth2.Config {
 	cfg := a.oauth2Config
 	cfg.Scopes = append([]string(nil), a.oauth2Config.Scopes...)
@@ -178,6 +214,11 @@ func RandomToken() (string, error) {
 }
 
 func (a *Client) AuthorizationURL(c *gin.Context, request *AuthorizationRequest) (string, error) {
+	return a.AuthorizationURLHTTP(c.Writer, request)
+}
+
+// AuthorizationURLHTTP 生成授权地址并把 state 写入 w 的 Cookie，供 net/http 使用
+func (a *Client) AuthorizationURLHTTP(w http.ResponseWriter, request *AuthorizationRequest) (string, error) {
 	state, err := RandomToken()
 	if err != nil {
 		return "", err
@@ -201,7 +242,7 @@ func (a *Client) AuthorizationURL(c *gin.Context, request *AuthorizationRequest)
 	if payload.ReturnTo == "" {
 		payload.ReturnTo = a.config.SuccessURL
 	}
-	if err := a.writeStateCookie(c, payload); err != nil {
+	if err := a.writeStateCookie(w, payload); err != nil {
 		return "", err
 	}
 	options = append(options,
@@ -212,41 +253,58 @@ func (a *Client) AuthorizationURL(c *gin.Context, request *AuthorizationRequest)
 }
 
 func (a *Client) LoginHandler(options ...xoauth2.AuthCodeOption) gin.HandlerFunc {
+	login := a.LoginHTTP(options...)
 	return func(c *gin.Context) {
-		authURL, err := a.AuthorizationURL(c, &AuthorizationRequest{
-			ReturnTo: c.Query("return_to"),
+		login(c.Writer, c.Request)
+		if c.Writer.Status() >= http.StatusBadRequest {
+			c.Abort()
+		}
+	}
+}
+
+// LoginHTTP net/http 版本的 LoginHandler
+func (a *Client) LoginHTTP(options ...xoauth2.AuthCodeOption) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authURL, err := a.AuthorizationURLHTTP(w, &AuthorizationRequest{
+			ReturnTo: r.URL.Query().Get("return_to"),
 			Options:  options,
 		})
 		if err != nil {
-			Abort(c, err)
+			WriteError(w, r, err)
 			return
 		}
-		c.Redirect(http.StatusFound, authURL)
+		http.Redirect(w, r, authURL, http.StatusFound)
 	}
 }
 
 func (a *Client) Exchange(c *gin.Context) (*Result, error) {
-	payload, err := a.readStateCookie(c)
-	a.clearStateCookie(c)
+	return a.ExchangeHTTP(c.Writer, c.Request)
+}
+
+// ExchangeHTTP 校验回调 state 并换取 Token，供 net/http 使用
+func (a *Client) ExchangeHTTP(w http.ResponseWriter, r *http.Request) (*Result, error) {
+	payload, err := a.readStateCookie(r)
+	a.clearStateCookie(w)
 	if err != nil {
 		return nil, err
 	}
-	state := c.Query("state")
+	query := r.URL.Query()
+	state := query.Get("state")
 	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(payload.State)) != 1 {
 		return nil, ErrInvalidState
 	}
-	if providerCode := strings.TrimSpace(c.Query("error")); providerCode != "" {
+	if providerCode := strings.TrimSpace(query.Get("error")); providerCode != "" {
 		return nil, &ProviderError{
 			Code:        providerCode,
-			Description: strings.TrimSpace(c.Query("error_description")),
-			URI:         strings.TrimSpace(c.Query("error_uri")),
+			Description: strings.TrimSpace(query.Get("error_description")),
+			URI:         strings.TrimSpace(query.Get("error_uri")),
 		}
 	}
-	code := strings.TrimSpace(c.Query("code"))
+	code := strings.TrimSpace(query.Get("code"))
 	if code == "" {
 		return nil, ErrMissingCode
 	}
-	token, err := a.oauth2Config.Exchange(a.context(c.Request.Context()), code, xoauth2.VerifierOption(payload.Verifier))
+	token, err := a.oauth2Config.Exchange(a.context(r.Context()), code, xoauth2.VerifierOption(payload.Verifier))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
 	}
@@ -254,14 +312,27 @@ func (a *Client) Exchange(c *gin.Context) (*Result, error) {
 }
 
 func (a *Client) Authenticate(c *gin.Context) (*Identity, error) {
-	result, err := a.Exchange(c)
+	return a.AuthenticateHTTP(c.Writer, c.Request)
+}
+
+// AuthenticateHTTP net/http 版本的 Authenticate
+func (a *Client) AuthenticateHTTP(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	identity, err := a.authenticate(w, r)
+	if err != nil {
+		emitFailure(r.Context(), a.config.AuthURL, err)
+	}
+	return identity, err
+}
+
+func (a *Client) authenticate(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	result, err := a.ExchangeHTTP(w, r)
 	if err != nil {
 		return nil, err
 	}
 	identity := &Identity{Result: result}
 	if a.config.UserInfoURL != "" {
 		identity.UserInfo = make(map[string]any)
-		if err := a.UserInfo(c.Request.Context(), result.Token, &identity.UserInfo); err != nil {
+		if err := a.UserInfo(r.Context(), result.Token, &identity.UserInfo); err != nil {
 			return nil, err
 		}
 	}
@@ -330,7 +401,23 @@ func (a *Client) CallbackHandler(next CallbackFunc) gin.HandlerFunc {
 			next(c, identity)
 			return
 		}
-		finish(c, identity.ReturnTo)
+		finish(c.Writer, c.Request, identity.ReturnTo)
+	}
+}
+
+// CallbackHTTP net/http 版本的 CallbackHandler
+func (a *Client) CallbackHTTP(next HTTPCallbackFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.AuthenticateHTTP(w, r)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if next != nil {
+			next(w, r, identity)
+			return
+		}
+		finish(w, r, identity.ReturnTo)
 	}
 }
 
@@ -344,6 +431,32 @@ func IdentityFromContext(c *gin.Context) (*Identity, bool) {
 }
 
 func Abort(c *gin.Context, err error) {
+	WriteError(c.Writer, c.Request, err)
+	c.Abort()
+}
+
+// WriteError 通过 problem.Write 写出错误响应，Abort 的 net/http 版本
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Write(w, r, Problem(err))
+}
+
+// emitFailure 记录登录回调失败的审计事件，Provider 错误与不可用记为 audit.ProviderError
+func emitFailure(ctx context.Context, provider string, err error) {
+	typ := audit.LoginFailed
+	if errors.Is(err, ErrProvider) || errors.Is(err, ErrExchange) || errors.Is(err, ErrUserInfo) {
+		typ = audit.ProviderError
+	}
+	audit.Emit(ctx, audit.Event{
+		Type:     typ,
+		Method:   principal.MethodOAuth2,
+		Provider: provider,
+		Reason:   Problem(err).Code,
+		Fields:   map[string]any{"error": err.Error()},
+	})
+}
+
+// Problem 返回错误对应的 problem，Code 为 invalid_state、token_exchange_failed 等
+func Problem(err error) *problem.Problem {
 	status := http.StatusBadRequest
 	code := "invalid_request"
 	switch {
@@ -363,15 +476,15 @@ func Abort(c *gin.Context, err error) {
 		status = http.StatusInternalServerError
 		code = "oauth2_failed"
 	}
-	c.AbortWithStatusJSON(status, gin.H{"error": code})
+	return problem.New(status, code)
 }
 
-func finish(c *gin.Context, returnTo string) {
+func finish(w http.ResponseWriter, r *http.Request, returnTo string) {
 	if returnTo != "" {
-		c.Redirect(http.StatusFound, returnTo)
+		http.Redirect(w, r, returnTo, http.StatusFound)
 		return
 	}
-	c.Status(http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (a *Client) context(ctx context.Context) context.Context {
@@ -388,7 +501,7 @@ func (a *Client) http() *http.Client {
 	return http.DefaultClient
 }
 
-func (a *Client) writeStateCookie(c *gin.Context, payload statePayload) error {
+func (a *Client) writeStateCookie(w http.ResponseWriter, payload statePayload) error {
 	buf, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("oauth2: encode state: %w", err)
@@ -397,7 +510,7 @@ func (a *Client) writeStateCookie(c *gin.Context, payload statePayload) error {
 	mac := hmac.New(sha256.New, []byte(a.config.CookieSecret))
 	_, _ = mac.Write([]byte(body))
 	value := body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
-	http.SetCookie(c.Writer, &http.Cookie{
+	http.SetCookie(w, &http.Cookie{
 		Name:     a.config.CookieName,
 		Value:    value,
 		Path:     "/",
@@ -410,9 +523,13 @@ func (a *Client) writeStateCookie(c *gin.Context, payload statePayload) error {
 	return nil
 }
 
-func (a *Client) readStateCookie(c *gin.Context) (statePayload, error) {
-	cookie, err := c.Cookie(a.config.CookieName)
-	if err != nil || len(cookie) > 4096 {
+func (a *Client) readStateCookie(r *http.Request) (statePayload, error) {
+	raw, err := r.Cookie(a.config.CookieName)
+	if err != nil || len(raw.Value) > 4096 {
+		return statePayload{}, ErrInvalidState
+	}
+	cookie, err := url.QueryUnescape(raw.Value)
+	if err != nil {
 		return statePayload{}, ErrInvalidState
 	}
 	body, signature, ok := strings.Cut(cookie, ".")
@@ -442,8 +559,8 @@ func (a *Client) readStateCookie(c *gin.Context) (statePayload, error) {
 	return payload, nil
 }
 
-func (a *Client) clearStateCookie(c *gin.Context) {
-	http.SetCookie(c.Writer, &http.Cookie{
+func (a *Client) clearStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
 		Name:     a.config.CookieName,
 		Value:    "",
 		Path:     "/",
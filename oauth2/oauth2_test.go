@@ -264,3 +264,69 @@ func responseCookie(t *testing.T, recorder *httptest.ResponseRecorder, name stri
 	t.Fatalf("response cookie %q not found", name)
 	return nil
 }
+
+func TestSessionCallbackCarriesAnonymousItems(t *testing.T) {
+	provider, _ := newOAuthProvider(t)
+	defer provider.Close()
+	client, err := authoauth2.New(&authoauth2.Config{
+		ClientID:     "client-id",
+		RedirectURL:  "http://localhost/oauth2/callback",
+		AuthURL:      provider.URL + "/authorize",
+		TokenURL:     provider.URL + "/token",
+		UserInfoURL:  provider.URL + "/userinfo",
+		CookieSecret: testCookieSecret,
+		SuccessURL:   "/",
+		CarryOver:    []string{"cart"},
+	}, authoauth2.WithCarryOver(func(old, current session.Data) {
+		current.Set("previous_locale", old.Get("locale"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(session.Mw("token", session.NewMemStore()))
+	router.GET("/oauth2/login", client.LoginHandler())
+	router.GET("/oauth2/callback", client.SessionCallback())
+	router.GET("/guest", func(c *gin.Context) {
+		sess := session.Default(c)
+		sess.Set("cart", "sku-1")
+		sess.Set("locale", "zh-CN")
+		_ = sess.Save()
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/items", func(c *gin.Context) {
+		sess := session.Default(c)
+		c.JSON(http.StatusOK, gin.H{"cart": sess.Get("cart"), "locale": sess.Get("locale"), "previous_locale": sess.Get("previous_locale")})
+	})
+
+	guest := httptest.NewRecorder()
+	router.ServeHTTP(guest, httptest.NewRequest(http.MethodGet, "/guest", nil))
+	guestToken := guest.Header().Get("X-Token")
+
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/oauth2/login", nil))
+	authURL, _ := url.Parse(login.Header().Get("Location"))
+
+	callback := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/oauth2/callback?code=code&state="+url.QueryEscape(authURL.Query().Get("state")), nil)
+	request.Header.Set("X-Token", guestToken)
+	request.AddCookie(responseCookie(t, login, authoauth2.DefaultCookieName))
+	router.ServeHTTP(callback, request)
+	token := callback.Header().Get("X-Token")
+	if callback.Code != http.StatusFound || token == "" || token == guestToken {
+		t.Fatalf("callback: status=%d token=%q body=%s", callback.Code, token, callback.Body.String())
+	}
+
+	items := httptest.NewRecorder()
+	itemsRequest := httptest.NewRequest(http.MethodGet, "/items", nil)
+	itemsRequest.Header.Set("X-Token", token)
+	router.ServeHTTP(items, itemsRequest)
+	var body map[string]any
+	if err := json.Unmarshal(items.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["cart"] != "sku-1" || body["locale"] != nil || body["previous_locale"] != "zh-CN" {
+		t.Fatalf("unexpected carried items: %#v", body)
+	}
+}
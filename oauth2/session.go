@@ -3,42 +3,73 @@ package oauth2
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"math"
+	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/mulan-ext/auth/principal"
 	"github.com/mulan-ext/auth/session"
 )
 
 type SessionMapper func(*gin.Context, *Identity, *session.Session) error
 
+// HTTPSessionMapper net/http 版本的 SessionMapper
+type HTTPSessionMapper func(*http.Request, *Identity, *session.Session) error
+
 func (a *Client) SessionCallback(mapper ...SessionMapper) gin.HandlerFunc {
 	selected := DefaultSessionMapper
 	if len(mapper) > 0 && mapper[0] != nil {
 		selected = mapper[0]
 	}
 	return a.CallbackHandler(func(c *gin.Context, identity *Identity) {
-		sess := session.Default(c)
-		sess.Data().Clear()
-		if err := sess.Clear(); err != nil {
-			Abort(c, fmt.Errorf("oauth2: rotate session: %w", err))
-			return
-		}
-		if err := selected(c, identity, sess); err != nil {
+		sess, err := login(c.Request, func(sess *session.Session) error {
+			sess.SetAuthInfo(session.AuthInfo{Method: principal.MethodOAuth2})
+			return selected(c, identity, sess)
+		}, a.carry)
+		if err != nil {
 			Abort(c, err)
 			return
 		}
-		if err := sess.Save(); err != nil {
-			Abort(c, fmt.Errorf("oauth2: save session: %w", err))
+		session.Populate(c, sess.Data(), principal.MethodOAuth2)
+		finish(c.Writer, c.Request, identity.ReturnTo)
+	})
+}
+
+// SessionCallbackHTTP net/http 版本的 SessionCallback，需挂在 session.Middleware 之后
+func (a *Client) SessionCallbackHTTP(mapper ...HTTPSessionMapper) http.HandlerFunc {
+	selected := func(_ *http.Request, identity *Identity, sess *session.Session) error {
+		return DefaultSessionMapper(nil, identity, sess)
+	}
+	if len(mapper) > 0 && mapper[0] != nil {
+		selected = mapper[0]
+	}
+	return a.CallbackHTTP(func(w http.ResponseWriter, r *http.Request, identity *Identity) {
+		if _, err := login(r, func(sess *session.Session) error {
+			sess.SetAuthInfo(session.AuthInfo{Method: principal.MethodOAuth2})
+			return selected(r, identity, sess)
+		}, a.carry); err != nil {
+			WriteError(w, r, err)
 			return
 		}
-		finish(c, identity.ReturnTo)
+		finish(w, r, identity.ReturnTo)
 	})
 }
 
+// login 轮换当前请求的Session并写入登录信息，按配置带入匿名session数据
+func login(r *http.Request, apply func(*session.Session) error, carry []session.CarryOver) (*session.Session, error) {
+	sess, ok := session.FromContext(r.Context())
+	if !ok {
+		return nil, errors.New("oauth2: session middleware is required")
+	}
+	if err := sess.Login(apply, carry...); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
 func DefaultSessionMapper(_ *gin.Context, identity *Identity, sess *session.Session) error {
 	if identity == nil || len(identity.UserInfo) == 0 {
 		return errors.New("oauth2: user info is required for session authentication")
@@ -29,6 +29,7 @@ type Config struct {
 	SuccessURL          string   `json:"success_url" yaml:"success_url"`
 	FetchUserInfo       bool     `json:"fetch_userinfo" yaml:"fetch_userinfo"`
 	AllowInsecureIssuer bool     `json:"allow_insecure_issuer" yaml:"allow_insecure_issuer"`
+	CarryOver           []string `json:"carry_over" yaml:"carry_over"`
 }
 
 func (c *Config) FlagSet() *pflag.FlagSet { return FlagSet() }
@@ -47,6 +48,7 @@ func FlagSet() *pflag.FlagSet {
 	fs.String("oidc.success-url", "/", "OIDC post-login redirect path")
 	fs.Bool("oidc.fetch-userinfo", false, "fetch and validate OIDC UserInfo")
 	fs.Bool("oidc.allow-insecure-issuer", false, "allow a non-HTTPS issuer URL")
+	fs.StringSlice("oidc.carry-over", nil, "session item keys kept from the anonymous session at login")
 	return fs
 }
 
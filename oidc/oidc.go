@@ -12,7 +12,11 @@ import (
 
 	gooidc "github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gin-gonic/gin"
+	"github.com/mulan-ext/auth/audit"
 	authoauth2 "github.com/mulan-ext/auth/oauth2"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+	"github.com/mulan-ext/auth/session"
 	xoauth2 "golang.org/x/oauth2"
 )
 
@@ -39,22 +43,33 @@ type Identity struct {
 
 type CallbackFunc func(*gin.Context, *Identity)
 
+// HTTPCallbackFunc net/http 版本的 CallbackFunc
+type HTTPCallbackFunc func(http.ResponseWriter, *http.Request, *Identity)
+
 type Option func(*options)
 
 type options struct {
 	httpClient *http.Client
+	carry      session.CarryOver
 }
 
 func WithHTTPClient(client *http.Client) Option {
 	return func(opts *options) { opts.httpClient = client }
 }
 
+// WithCarryOver merges data from the pre-login session into the new one in
+// SessionCallback. It runs after Config.CarryOver keys have been copied.
+func WithCarryOver(policy session.CarryOver) Option {
+	return func(opts *options) { opts.carry = policy }
+}
+
 type Authenticator struct {
 	config     Config
 	provider   *gooidc.Provider
 	verifier   *gooidc.IDTokenVerifier
 	flow       *authoauth2.Client
 	httpClient *http.Client
+	carry      []session.CarryOver
 }
 
 func New(ctx context.Context, cfg *Config, options ...Option) (*Authenticator, error) {
@@ -96,8 +111,9 @@ func New(ctx context.Context, cfg *Config, options ...Option) (*Authenticator, e
 		StateTTL:               normalized.StateTTL,
 		SuccessURL:             normalized.SuccessURL,
 		AllowInsecureEndpoints: normalized.AllowInsecureIssuer,
+		CarryOver:              normalized.CarryOver,
 	}
-	flowOptions := []authoauth2.Option{authoauth2.WithHTTPClient(opts.httpClient)}
+	flowOptions := []authoauth2.Option{authoauth2.WithHTTPClient(opts.httpClient), authoauth2.WithCarryOver(opts.carry)}
 	flow, err := authoauth2.New(flowConfig, flowOptions...)
 	if err != nil {
 		return nil, err
@@ -108,6 +124,7 @@ func New(ctx context.Context, cfg *Config, options ...Option) (*Authenticator, e
 		verifier:   provider.Verifier(&gooidc.Config{ClientID: normalized.ClientID}),
 		flow:       flow,
 		httpClient: opts.httpClient,
+		carry:      flow.CarryOver(),
 	}, nil
 }
 
@@ -124,18 +141,24 @@ func optionsFrom(values []Option) options {
 func (a *Authenticator) Config() Config {
 	cfg := a.config
 	cfg.Scopes = append([]string(nil), a.config.Scopes...)
+	cfg.CarryOver = append([]string(nil), a.config.CarryOver...)
 	return cfg
 }
 
 func (a *Authenticator) OAuth2Config() xoauth2.Config { return a.flow.OAuth2Config() }
 
 func (a *Authenticator) AuthorizationURL(c *gin.Context, returnTo string, options ...xoauth2.AuthCodeOption) (string, error) {
+	return a.AuthorizationURLHTTP(c.Writer, returnTo, options...)
+}
+
+// AuthorizationURLHTTP 生成授权地址并把 state 写入 w 的 Cookie，供 net/http 使用
+func (a *Authenticator) AuthorizationURLHTTP(w http.ResponseWriter, returnTo string, options ...xoauth2.AuthCodeOption) (string, error) {
 	nonce, err := authoauth2.RandomToken()
 	if err != nil {
 		return "", err
 	}
 	options = append(options, xoauth2.SetAuthURLParam("nonce", nonce))
-	return a.flow.AuthorizationURL(c, &authoauth2.AuthorizationRequest{
+	return a.flow.AuthorizationURLHTTP(w, &authoauth2.AuthorizationRequest{
 		ReturnTo: returnTo,
 		Nonce:    nonce,
 		Options:  options,
@@ -143,18 +166,42 @@ func (a *Authenticator) AuthorizationURL(c *gin.Context, returnTo string, option
 }
 
 func (a *Authenticator) LoginHandler(options ...xoauth2.AuthCodeOption) gin.HandlerFunc {
+	login := a.LoginHTTP(options...)
 	return func(c *gin.Context) {
-		authURL, err := a.AuthorizationURL(c, c.Query("return_to"), options...)
+		login(c.Writer, c.Request)
+		if c.Writer.Status() >= http.StatusBadRequest {
+			c.Abort()
+		}
+	}
+}
+
+// LoginHTTP net/http 版本的 LoginHandler
+func (a *Authenticator) LoginHTTP(options ...xoauth2.AuthCodeOption) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authURL, err := a.AuthorizationURLHTTP(w, r.URL.Query().Get("return_to"), options...)
 		if err != nil {
-			abort(c, err)
+			WriteError(w, r, err)
 			return
 		}
-		c.Redirect(http.StatusFound, authURL)
+		http.Redirect(w, r, authURL, http.StatusFound)
 	}
 }
 
 func (a *Authenticator) Authenticate(c *gin.Context) (*Identity, error) {
-	result, err := a.flow.Exchange(c)
+	return a.AuthenticateHTTP(c.Writer, c.Request)
+}
+
+// AuthenticateHTTP net/http 版本的 Authenticate
+func (a *Authenticator) AuthenticateHTTP(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	identity, err := a.authenticate(w, r)
+	if err != nil {
+		emitFailure(r.Context(), a.config.IssuerURL, err)
+	}
+	return identity, err
+}
+
+func (a *Authenticator) authenticate(w http.ResponseWriter, r *http.Request) (*Identity, error) {
+	result, err := a.flow.ExchangeHTTP(w, r)
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +209,7 @@ func (a *Authenticator) Authenticate(c *gin.Context) (*Identity, error) {
 	if !ok || strings.TrimSpace(rawIDToken) == "" {
 		return nil, ErrMissingIDToken
 	}
-	ctx := c.Request.Context()
+	ctx := r.Context()
 	if a.httpClient != nil {
 		ctx = gooidc.ClientContext(ctx, a.httpClient)
 	}
@@ -238,7 +285,23 @@ func (a *Authenticator) CallbackHandler(next CallbackFunc) gin.HandlerFunc {
 			next(c, identity)
 			return
 		}
-		finish(c, identity.ReturnTo)
+		finish(c.Writer, c.Request, identity.ReturnTo)
+	}
+}
+
+// CallbackHTTP net/http 版本的 CallbackHandler
+func (a *Authenticator) CallbackHTTP(next HTTPCallbackFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.AuthenticateHTTP(w, r)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		if next != nil {
+			next(w, r, identity)
+			return
+		}
+		finish(w, r, identity.ReturnTo)
 	}
 }
 
@@ -252,12 +315,37 @@ func IdentityFromContext(c *gin.Context) (*Identity, bool) {
 }
 
 func abort(c *gin.Context, err error) {
+	WriteError(c.Writer, c.Request, err)
+	c.Abort()
+}
+
+// emitFailure 记录登录回调失败的审计事件，Provider 错误与不可用记为 audit.ProviderError
+func emitFailure(ctx context.Context, provider string, err error) {
+	typ := audit.LoginFailed
+	if errors.Is(err, authoauth2.ErrProvider) || errors.Is(err, authoauth2.ErrExchange) || errors.Is(err, ErrUserInfo) {
+		typ = audit.ProviderError
+	}
+	audit.Emit(ctx, audit.Event{
+		Type:     typ,
+		Method:   principal.MethodOIDC,
+		Provider: provider,
+		Reason:   Problem(err).Code,
+		Fields:   map[string]any{"error": err.Error()},
+	})
+}
+
+// WriteError 通过 problem.Write 写出错误响应
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Write(w, r, Problem(err))
+}
+
+// Problem 返回错误对应的 problem，OAuth2 流程错误与 oauth2.Problem 一致
+func Problem(err error) *problem.Problem {
 	if errors.Is(err, authoauth2.ErrInvalidState) ||
 		errors.Is(err, authoauth2.ErrMissingCode) ||
 		errors.Is(err, authoauth2.ErrProvider) ||
 		errors.Is(err, authoauth2.ErrExchange) {
-		authoauth2.Abort(c, err)
-		return
+		return authoauth2.Problem(err)
 	}
 	status := http.StatusBadRequest
 	code := "invalid_id_token"
@@ -275,13 +363,13 @@ func abort(c *gin.Context, err error) {
 		status = http.StatusInternalServerError
 		code = "oidc_failed"
 	}
-	c.AbortWithStatusJSON(status, gin.H{"error": code})
+	return problem.New(status, code)
 }
 
-func finish(c *gin.Context, returnTo string) {
+func finish(w http.ResponseWriter, r *http.Request, returnTo string) {
 	if returnTo != "" {
-		c.Redirect(http.StatusFound, returnTo)
+		http.Redirect(w, r, returnTo, http.StatusFound)
 		return
 	}
-	c.Status(http.StatusNoContent)
+	w.WriteHeader(http.StatusNoContent)
 }
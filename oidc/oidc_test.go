@@ -30,6 +30,7 @@ type fakeOIDCProvider struct {
 	form    url.Values
 	subject string
 	expired bool
+	authAge time.Duration
 }
 
 func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
@@ -38,7 +39,7 @@ func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
 	if err != nil {
 		t.Fatal(err)
 	}
-	provider := &fakeOIDCProvider{key: key, subject: "oidc-user"}
+	provider := &fakeOIDCProvider{key: key, subject: "oidc-user", authAge: 30 * time.Second}
 	provider.server = httptest.NewServer(http.HandlerFunc(provider.serveHTTP))
 	return provider
 }
@@ -63,6 +64,12 @@ func (p *fakeOIDCProvider) setExpired(expired bool) {
 	p.expired = expired
 }
 
+func (p *fakeOIDCProvider) setAuthAge(age time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.authAge = age
+}
+
 func (p *fakeOIDCProvider) verifier() string {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -124,6 +131,9 @@ func (p *fakeOIDCProvider) serveHTTP(w http.ResponseWriter, req *http.Request) {
 
 func (p *fakeOIDCProvider) signIDToken(nonce string, expired bool) (string, error) {
 	now := time.Now()
+	p.mu.Lock()
+	authAge := p.authAge
+	p.mu.Unlock()
 	expiresAt := now.Add(time.Hour)
 	if expired {
 		expiresAt = now.Add(-time.Minute)
@@ -138,6 +148,9 @@ func (p *fakeOIDCProvider) signIDToken(nonce string, expired bool) (string, erro
 		"preferred_username": "bob",
 		"email":              "bob@example.com",
 		"roles":              []string{"admin", "operator"},
+		"auth_time":          now.Add(-authAge).Unix(),
+		"acr":                "urn:mfa",
+		"amr":                []string{"pwd", "otp"},
 	})
 	if err != nil {
 		return "", err
@@ -324,3 +337,111 @@ func responseCookie(t *testing.T, recorder *httptest.ResponseRecorder, name stri
 	t.Fatalf("response cookie %q not found", name)
 	return nil
 }
+
+func TestRequireFreshAuthStepUp(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	defer provider.close()
+	router, authenticator := newOIDCRouter(t, provider)
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/fresh", session.RequireFreshAuthFunc(authenticator.StepUp, time.Minute, "urn:mfa"), ok)
+	router.GET("/strict", session.RequireFreshAuthFunc(authenticator.StepUp, 10*time.Second), ok)
+	router.POST("/strict", session.RequireFreshAuthFunc(authenticator.StepUp, 10*time.Second), ok)
+
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/oidc/login", nil))
+	authURL, _ := url.Parse(login.Header().Get("Location"))
+	provider.setNonce(authURL.Query().Get("nonce"))
+	callback := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/oidc/callback?code=valid-code&state="+url.QueryEscape(authURL.Query().Get("state")), nil)
+	request.AddCookie(responseCookie(t, login, authoidc.DefaultCookieName))
+	router.ServeHTTP(callback, request)
+	token := callback.Header().Get("X-Token")
+	if token == "" {
+		t.Fatalf("callback failed: %d %s", callback.Code, callback.Body.String())
+	}
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Token", token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// auth_time 为 30 秒前，acr 满足要求
+	if w := do(http.MethodGet, "/fresh"); w.Code != http.StatusNoContent {
+		t.Fatalf("fresh auth rejected: %d %s", w.Code, w.Body.String())
+	}
+
+	w := do(http.MethodGet, "/strict?tab=email")
+	if w.Code != http.StatusFound {
+		t.Fatalf("stale auth should redirect: %d", w.Code)
+	}
+	stepUpURL, _ := url.Parse(w.Header().Get("Location"))
+	query := stepUpURL.Query()
+	if query.Get("prompt") != "login" || query.Get("max_age") != "10" || query.Get("state") == "" {
+		t.Fatalf("unexpected step-up URL: %s", stepUpURL)
+	}
+
+	w = do(http.MethodPost, "/strict")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"error":"step_up_required"`) {
+		t.Fatalf("POST step-up: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestStepUpCallbackStopsRedirectLoop(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	defer provider.close()
+	router, authenticator := newOIDCRouter(t, provider)
+	router.GET("/strict", session.RequireFreshAuthFunc(authenticator.StepUp, 10*time.Second), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	callback := func(redirect *httptest.ResponseRecorder, token string) string {
+		t.Helper()
+		authURL, _ := url.Parse(redirect.Header().Get("Location"))
+		provider.setNonce(authURL.Query().Get("nonce"))
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/oidc/callback?code=valid-code&state="+url.QueryEscape(authURL.Query().Get("state")), nil)
+		req.AddCookie(responseCookie(t, redirect, authoidc.DefaultCookieName))
+		if token != "" {
+			req.Header.Set("X-Token", token)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusFound || w.Header().Get("X-Token") == "" {
+			t.Fatalf("callback failed: %d %s", w.Code, w.Body.String())
+		}
+		return w.Header().Get("X-Token")
+	}
+	strict := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/strict", nil)
+		req.Header.Set("X-Token", token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/oidc/login", nil))
+	token := callback(login, "")
+
+	// Provider 忽略 max_age，auth_time 仍为 30 秒前
+	w := strict(token)
+	if w.Code != http.StatusFound {
+		t.Fatalf("stale auth should redirect: %d", w.Code)
+	}
+	token = callback(w, token)
+	if w = strict(token); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"error":"step_up_required"`) {
+		t.Fatalf("unsatisfied step-up should not redirect again: %d %s", w.Code, w.Body.String())
+	}
+
+	// 标记只生效一次，再次访问重新发起
+	w = strict(token)
+	if w.Code != http.StatusFound {
+		t.Fatalf("step-up should be retried: %d", w.Code)
+	}
+	provider.setAuthAge(0)
+	token = callback(w, token)
+	if w = strict(token); w.Code != http.StatusNoContent {
+		t.Fatalf("satisfied step-up rejected: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -2,39 +2,85 @@ package oidc
 
 import (
 	"errors"
-	"fmt"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	authoauth2 "github.com/mulan-ext/auth/oauth2"
+	"github.com/mulan-ext/auth/principal"
 	"github.com/mulan-ext/auth/session"
+	"go.uber.org/zap"
 )
 
 type SessionMapper func(*gin.Context, *Identity, *session.Session) error
 
+// HTTPSessionMapper net/http 版本的 SessionMapper
+type HTTPSessionMapper func(*http.Request, *Identity, *session.Session) error
+
 func (a *Authenticator) SessionCallback(mapper ...SessionMapper) gin.HandlerFunc {
 	selected := DefaultSessionMapper
 	if len(mapper) > 0 && mapper[0] != nil {
 		selected = mapper[0]
 	}
 	return a.CallbackHandler(func(c *gin.Context, identity *Identity) {
-		sess := session.Default(c)
-		sess.Data().Clear()
-		if err := sess.Clear(); err != nil {
-			abort(c, fmt.Errorf("oidc: rotate session: %w", err))
-			return
-		}
-		if err := selected(c, identity, sess); err != nil {
+		sess, err := login(c.Request, identity, func(sess *session.Session) error {
+			return selected(c, identity, sess)
+		}, a.carry)
+		if err != nil {
 			abort(c, err)
 			return
 		}
-		if err := sess.Save(); err != nil {
-			abort(c, fmt.Errorf("oidc: save session: %w", err))
+		session.Populate(c, sess.Data(), principal.MethodOIDC)
+		finish(c.Writer, c.Request, identity.ReturnTo)
+	})
+}
+
+// SessionCallbackHTTP net/http 版本的 SessionCallback，需挂在 session.Middleware 之后
+func (a *Authenticator) SessionCallbackHTTP(mapper ...HTTPSessionMapper) http.HandlerFunc {
+	selected := func(_ *http.Request, identity *Identity, sess *session.Session) error {
+		return DefaultSessionMapper(nil, identity, sess)
+	}
+	if len(mapper) > 0 && mapper[0] != nil {
+		selected = mapper[0]
+	}
+	return a.CallbackHTTP(func(w http.ResponseWriter, r *http.Request, identity *Identity) {
+		if _, err := login(r, identity, func(sess *session.Session) error {
+			return selected(r, identity, sess)
+		}, a.carry); err != nil {
+			WriteError(w, r, err)
 			return
 		}
-		finish(c, identity.ReturnTo)
+		finish(w, r, identity.ReturnTo)
 	})
 }
 
+// login 轮换当前请求的Session并写入登录信息，按配置带入匿名session数据
+//
+// 由 StepUp 发起时校验 auth_time 与 acr 是否满足要求，不满足时标记 session，避免再次跳转。
+func login(r *http.Request, identity *Identity, apply func(*session.Session) error, carry []session.CarryOver) (*session.Session, error) {
+	sess, ok := session.FromContext(r.Context())
+	if !ok {
+		return nil, errors.New("oidc: session middleware is required")
+	}
+	pending, stepUp := sess.PendingStepUp()
+	err := sess.Login(func(sess *session.Session) error {
+		info := identity.AuthInfo()
+		sess.SetAuthInfo(info)
+		if stepUp && !info.Fresh(pending.MaxAge, pending.ACR...) {
+			zap.L().Warn("oidc: step-up requirement not satisfied",
+				zap.Duration("max_age", pending.MaxAge),
+				zap.Strings("acr_values", pending.ACR),
+				zap.Time("auth_time", info.Time),
+				zap.String("acr", info.ACR))
+			sess.FailStepUp()
+		}
+		return apply(sess)
+	}, carry...)
+	if err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
 func DefaultSessionMapper(_ *gin.Context, identity *Identity, sess *session.Session) error {
 	if identity == nil || len(identity.Claims) == 0 {
 		return errors.New("oidc: verified ID token claims are required")
@@ -0,0 +1,90 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	xoauth2 "golang.org/x/oauth2"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+// AuthInfo 从 ID Token 的 auth_time、acr、amr 声明提取认证信息
+//
+// 缺少 auth_time 时 Time 为零值，登录时由 session.SetAuthInfo 记为当前时间；
+// 由 StepUp 发起的登录缺少 auth_time 视为未满足 max_age。
+func (i *Identity) AuthInfo() session.AuthInfo {
+	info := session.AuthInfo{Method: principal.MethodOIDC}
+	if i == nil {
+		return info
+	}
+	if t, ok := authTime(i.Claims["auth_time"]); ok {
+		info.Time = t
+	}
+	info.ACR, _ = i.Claims["acr"].(string)
+	if amr, ok := i.Claims["amr"].([]any); ok {
+		for _, v := range amr {
+			if s, ok := v.(string); ok && s != "" {
+				info.AMR = append(info.AMR, s)
+			}
+		}
+	}
+	return info
+}
+
+// StepUp 实现 session.StepUpFunc：GET/HEAD 请求以 max_age、prompt=login、acr_values
+// 跳转到 Provider 重新登录，完成后回到原地址；其他请求返回 session.WriteStepUpError
+//
+// 要求记录在session中，SessionCallback 校验 auth_time 与 acr，Provider 未满足时
+// 下一次检查返回 session.WriteStepUpError，不会循环跳转。
+//
+//	r.POST("/account/email", session.RequireFreshAuthFunc(authn.StepUp, 5*time.Minute), handler)
+func (a *Authenticator) StepUp(w http.ResponseWriter, r *http.Request, req session.StepUp) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		session.WriteStepUpError(w, r, req)
+		return
+	}
+	options := []xoauth2.AuthCodeOption{
+		xoauth2.SetAuthURLParam("max_age", strconv.FormatInt(int64(req.MaxAge/time.Second), 10)),
+		xoauth2.SetAuthURLParam("prompt", "login"),
+	}
+	if len(req.ACR) > 0 {
+		options = append(options, xoauth2.SetAuthURLParam("acr_values", strings.Join(req.ACR, " ")))
+	}
+	if sess, ok := session.FromContext(r.Context()); ok && !sess.IsNil {
+		if err := sess.BeginStepUp(req); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+	}
+	authURL, err := a.AuthorizationURLHTTP(w, r.URL.RequestURI(), options...)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func authTime(v any) (time.Time, bool) {
+	var sec int64
+	switch v := v.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		sec = n
+	case float64:
+		sec = int64(v)
+	default:
+		return time.Time{}, false
+	}
+	if sec <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
@@ -0,0 +1,62 @@
+// Package principal 在 context.Context 中传递当前请求的认证主体
+//
+// session、apikey、oauth2、oidc 中间件认证成功后会把 Principal 写入请求的 context，
+// 服务层可通过 FromContext 获取调用方，无需依赖 gin。
+package principal
+
+import (
+	"context"
+	"slices"
+)
+
+// 认证方式
+const (
+	MethodSession  = "session"
+	MethodAPIKey   = "apikey"
+	MethodOAuth2   = "oauth2"
+	MethodOIDC     = "oidc"
+	MethodRemember = "remember" // 由记住登录Cookie恢复
+)
+
+// Principal 认证主体
+type Principal struct {
+	ID      uint64
+	Account string
+	Roles   []string
+	Scopes  []string // 凭证被授予的 scope，nil 表示不受限制（session 登录），API Key 总为非 nil，"*" 表示全部
+	State   uint16
+	Tenant  string     // 所属租户，单租户部署时为空
+	Method  string     // 认证方式，见 Method* 常量
+	Actor   *Principal // 代为操作（impersonation）时的真实操作者，否则为 nil
+}
+
+// HasRole 检查主体是否拥有指定角色
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// HasScope 检查凭证是否被授予指定 scope
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, "*")
+}
+
+// Impersonated 当前主体是否由他人代为操作
+func (p *Principal) Impersonated() bool {
+	return p != nil && p.Actor != nil
+}
+
+type ctxKey struct{}
+
+// NewContext 返回携带 Principal 的 context
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext 获取 context 中的 Principal
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,40 @@
+package principal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mulan-ext/auth/principal"
+)
+
+func TestContextRoundTrip(t *testing.T) {
+	if _, ok := principal.FromContext(context.Background()); ok {
+		t.Fatal("unexpected principal in empty context")
+	}
+	if _, ok := principal.FromContext(principal.NewContext(context.Background(), nil)); ok {
+		t.Fatal("nil principal reported as present")
+	}
+	ctx := principal.NewContext(context.Background(), &principal.Principal{
+		ID:     1,
+		Roles:  []string{"admin"},
+		Method: principal.MethodSession,
+	})
+	p, ok := principal.FromContext(ctx)
+	if !ok || p.ID != 1 || !p.HasRole("admin") || p.HasRole("user") {
+		t.Fatalf("unexpected principal: %+v", p)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	var anonymous *principal.Principal
+	if anonymous.HasScope("read") {
+		t.Fatal("nil principal has scope")
+	}
+	if !(&principal.Principal{}).HasScope("read") {
+		t.Fatal("unscoped principal should not be restricted")
+	}
+	p := &principal.Principal{Scopes: []string{"read"}}
+	if !p.HasScope("read") || p.HasScope("write") || (&principal.Principal{Scopes: []string{}}).HasScope("read") {
+		t.Fatalf("unexpected scope check: %+v", p)
+	}
+}
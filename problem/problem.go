@@ -0,0 +1,148 @@
+// Package problem 统一输出认证与授权错误响应
+//
+// 默认按 RFC 9457 输出 application/problem+json，并附带 WWW-Authenticate 质询；
+// 配置登录页后，浏览器请求的 401 会跳转到登录页。session、apikey、oauth2、oidc
+// 等中间件均通过 Write 输出错误，可用 SetDefault 或 Mw 替换渲染方式。
+package problem
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ContentType RFC 9457 响应类型
+const ContentType = "application/problem+json"
+
+// Problem RFC 9457 problem details
+//
+// Code 以扩展成员 "error" 输出，便于客户端按错误码处理，如 invalid_token、step_up_required。
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       string
+	Extensions map[string]any
+	Challenges []Challenge // 写入 WWW-Authenticate
+}
+
+// New 返回指定状态码与错误码的 Problem，Type 为 about:blank，Title 为状态码文本
+func New(status int, code string) *Problem {
+	return &Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Code: code}
+}
+
+// Unauthenticated 返回 401 错误与 Bearer 质询，请求携带 Authorization 时质询包含 error="invalid_token"
+func Unauthenticated(r *http.Request) *Problem {
+	p := New(http.StatusUnauthorized, "unauthenticated")
+	if r != nil && r.Header.Get("Authorization") != "" {
+		return p.WithChallenge(Bearer("error", "invalid_token"))
+	}
+	return p.WithChallenge(Bearer())
+}
+
+// ForStatus 返回状态码对应的通用错误，401 同 Unauthenticated，其余错误码为 forbidden、not_found、internal_error 等
+func ForStatus(r *http.Request, status int) *Problem {
+	switch status {
+	case http.StatusUnauthorized:
+		return Unauthenticated(r)
+	case http.StatusForbidden:
+		return New(status, "forbidden")
+	case http.StatusNotFound:
+		return New(status, "not_found")
+	case http.StatusTooManyRequests:
+		return New(status, "too_many_requests")
+	}
+	if status >= http.StatusInternalServerError {
+		return New(status, "internal_error")
+	}
+	return New(status, "invalid_request")
+}
+
+// WithDetail 设置 detail
+func (p *Problem) WithDetail(detail string) *Problem {
+	p.Detail = detail
+	return p
+}
+
+// With 设置扩展成员，与标准成员同名时忽略
+func (p *Problem) With(key string, val any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = val
+	return p
+}
+
+// WithChallenge 追加 WWW-Authenticate 质询
+func (p *Problem) WithChallenge(challenges ...Challenge) *Problem {
+	p.Challenges = append(p.Challenges, challenges...)
+	return p
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+6)
+	maps.Copy(m, p.Extensions)
+	for _, key := range []string{"type", "title", "status", "detail", "instance", "error"} {
+		delete(m, key)
+	}
+	set := func(key, val string) {
+		if val != "" {
+			m[key] = val
+		}
+	}
+	set("type", p.Type)
+	set("title", p.Title)
+	set("detail", p.Detail)
+	set("instance", p.Instance)
+	set("error", p.Code)
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	return json.Marshal(m)
+}
+
+// Challenge WWW-Authenticate 质询
+type Challenge struct {
+	Scheme string
+	Params []string // 键值交替，如 "error", "invalid_token"
+}
+
+// Bearer 返回 RFC 6750 Bearer 质询，params 为键值交替，如 Bearer("error", "insufficient_scope", "scope", "orders:read")
+func Bearer(params ...string) Challenge { return Challenge{Scheme: "Bearer", Params: params} }
+
+// APIKey 返回 ApiKey 质询
+func APIKey(params ...string) Challenge { return Challenge{Scheme: "ApiKey", Params: params} }
+
+// String 格式化质询，realm 非空时作为第一个参数
+func (c Challenge) String(realm string) string {
+	params := c.Params
+	if realm != "" && !slices.Contains(pairKeys(params), "realm") {
+		params = append([]string{"realm", realm}, params...)
+	}
+	var b strings.Builder
+	b.WriteString(c.Scheme)
+	for i := 0; i+1 < len(params); i += 2 {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(params[i])
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(params[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func pairKeys(params []string) []string {
+	keys := make([]string, 0, len(params)/2)
+	for i := 0; i+1 < len(params); i += 2 {
+		keys = append(keys, params[i])
+	}
+	return keys
+}
@@ -0,0 +1,126 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/problem"
+)
+
+func TestProblemJSON(t *testing.T) {
+	p := problem.New(http.StatusForbidden, "insufficient_scope").
+		WithDetail("orders:write required").
+		With("scope", "orders:write").
+		With("status", 200)
+	buf, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(buf, &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type":   "about:blank",
+		"title":  "Forbidden",
+		"status": float64(403),
+		"detail": "orders:write required",
+		"error":  "insufficient_scope",
+		"scope":  "orders:write",
+	}
+	if len(body) != len(want) {
+		t.Fatalf("unexpected body: %s", buf)
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Fatalf("%s = %v, want %v", k, body[k], v)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	cases := []struct {
+		challenge problem.Challenge
+		realm     string
+		want      string
+	}{
+		{problem.Bearer(), "", "Bearer"},
+		{problem.Bearer(), "api", `Bearer realm="api"`},
+		{problem.Bearer("error", "insufficient_scope", "scope", "a b"), "api", `Bearer realm="api", error="insufficient_scope", scope="a b"`},
+		{problem.APIKey("realm", "keys"), "api", `ApiKey realm="keys"`},
+		{problem.Bearer("error_description", `bad "token"`), "", `Bearer error_description="bad \"token\""`},
+	}
+	for _, tc := range cases {
+		if got := tc.challenge.String(tc.realm); got != tc.want {
+			t.Errorf("String() = %s, want %s", got, tc.want)
+		}
+	}
+}
+
+func TestResponder(t *testing.T) {
+	respond := problem.NewResponder(problem.WithRealm("example"), problem.WithLoginURL("/oidc/login?provider=corp"))
+
+	// API 请求输出 problem+json 与质询
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	r.Header.Set("Accept", "application/json")
+	respond(w, r, problem.Unauthenticated(r))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("api response: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != `Bearer realm="example"` {
+		t.Fatalf("challenge: %s", got)
+	}
+
+	// 浏览器请求跳转登录页
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/orders?page=2", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	respond(w, r, problem.Unauthenticated(r))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/oidc/login?provider=corp&return_to=%2Forders%3Fpage%3D2" {
+		t.Fatalf("browser response: %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	// 403 不跳转
+	w = httptest.NewRecorder()
+	respond(w, r, problem.New(http.StatusForbidden, "forbidden"))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("browser 403: %d", w.Code)
+	}
+}
+
+func TestWriteResponderOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	html := problem.NewResponder(problem.WithRender(func(w http.ResponseWriter, _ *http.Request, p *problem.Problem) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(p.Status)
+		_, _ = w.Write([]byte("<h1>" + p.Title + "</h1>"))
+	}))
+	router := gin.New()
+	deny := func(c *gin.Context) { problem.Abort(c, problem.New(http.StatusForbidden, "forbidden")) }
+	router.GET("/api", deny)
+	router.GET("/page", problem.Mw(html), deny)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if w.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("default responder: %s", w.Header().Get("Content-Type"))
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/page", nil))
+	if w.Code != http.StatusForbidden || w.Body.String() != "<h1>Forbidden</h1>" {
+		t.Fatalf("route responder: %d %s", w.Code, w.Body.String())
+	}
+
+	problem.SetDefault(html)
+	defer problem.SetDefault(nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+	if w.Body.String() != "<h1>Forbidden</h1>" {
+		t.Fatalf("SetDefault: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,144 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Responder 写出错误响应
+type Responder func(w http.ResponseWriter, r *http.Request, p *Problem)
+
+// Option Responder 配置
+type Option func(*responder)
+
+// WithRealm 设置 WWW-Authenticate 的 realm
+func WithRealm(realm string) Option {
+	return func(o *responder) { o.realm = realm }
+}
+
+// WithLoginURL 浏览器请求返回 401 时 302 跳转到登录页，原地址以 return_to 参数传递
+//
+// 可直接指向 oauth2、oidc 的登录路由，登录完成后回到原地址。
+func WithLoginURL(loginURL string) Option {
+	return func(o *responder) { o.loginURL = loginURL }
+}
+
+// WithRender 自定义响应体渲染，调用时 WWW-Authenticate 已写入，需自行写出状态码
+func WithRender(render Responder) Option {
+	return func(o *responder) { o.render = render }
+}
+
+type responder struct {
+	realm    string
+	loginURL string
+	render   Responder
+}
+
+// NewResponder 返回默认 Responder：写入 WWW-Authenticate，浏览器 401 跳转登录页，其余输出 problem+json
+func NewResponder(opts ...Option) Responder {
+	o := &responder{render: JSON}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o.respond
+}
+
+func (o *responder) respond(w http.ResponseWriter, r *http.Request, p *Problem) {
+	for _, c := range p.Challenges {
+		w.Header().Add("WWW-Authenticate", c.String(o.realm))
+	}
+	if o.loginURL != "" && p.Status == http.StatusUnauthorized && r != nil && Browser(r) {
+		http.Redirect(w, r, loginURL(o.loginURL, r.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	o.render(w, r, p)
+}
+
+func loginURL(base, returnTo string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	q := u.Query()
+	q.Set("return_to", returnTo)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// JSON 输出 application/problem+json 响应体
+func JSON(w http.ResponseWriter, _ *http.Request, p *Problem) {
+	buf, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(buf)
+}
+
+// Browser 判断是否为浏览器页面请求：GET/HEAD、Accept 包含 text/html 且不是 XMLHttpRequest
+func Browser(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+var defaultResponder atomic.Pointer[Responder]
+
+func init() { SetDefault(NewResponder()) }
+
+// SetDefault 替换全局默认 Responder，nil 时恢复为 NewResponder()
+func SetDefault(resp Responder) {
+	if resp == nil {
+		resp = NewResponder()
+	}
+	defaultResponder.Store(&resp)
+}
+
+type ctxKey struct{}
+
+// WithResponder 返回携带 Responder 的 context，优先于全局默认
+func WithResponder(ctx context.Context, resp Responder) context.Context {
+	return context.WithValue(ctx, ctxKey{}, resp)
+}
+
+// Mw 为路由组指定 Responder，如页面路由跳转登录页、API 路由输出 JSON
+func Mw(resp Responder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(WithResponder(c.Request.Context(), resp))
+		c.Next()
+	}
+}
+
+// Middleware net/http 版本的 Mw
+func Middleware(resp Responder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithResponder(r.Context(), resp)))
+		})
+	}
+}
+
+// Write 使用 context 中的 Responder 或全局默认 Responder 写出错误
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if r != nil {
+		if resp, ok := r.Context().Value(ctxKey{}).(Responder); ok && resp != nil {
+			resp(w, r, p)
+			return
+		}
+	}
+	(*defaultResponder.Load())(w, r, p)
+}
+
+// Abort 写出错误并终止 gin 处理链
+func Abort(c *gin.Context, p *Problem) {
+	Write(c.Writer, c.Request, p)
+	c.Abort()
+}
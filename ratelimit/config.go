@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/mulan-ext/rdb"
+)
+
+// Config 默认限额与存储配置，按角色或 API Key 的限额通过 Option 设置
+type Config struct {
+	// Driver 计数存储驱动，memory 或 rdb，多实例部署应使用 rdb
+	Driver    string     `json:"driver" yaml:"driver"`
+	RDB       rdb.Config `json:"rdb" yaml:"rdb"`
+	Algorithm Algorithm  `json:"algorithm" yaml:"algorithm"`
+	Rate      int        `json:"rate" yaml:"rate"`
+	Period    int        `json:"period" yaml:"period"` // 秒
+	Burst     int        `json:"burst" yaml:"burst"`
+}
+
+// 存储驱动名称
+const (
+	DriverMemory = "memory"
+	DriverRedis  = "rdb"
+)
+
+func (c *Config) FlagSet() *pflag.FlagSet { return FlagSet() }
+
+func FlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("ratelimit", pflag.ContinueOnError)
+	fs.String("ratelimit.driver", DriverMemory, "RateLimit store driver (memory, rdb)")
+	fs.String("ratelimit.algorithm", string(TokenBucket), "RateLimit algorithm (token_bucket, sliding_window)")
+	fs.Int("ratelimit.rate", 0, "RateLimit requests per period, 0 to disable")
+	fs.Int("ratelimit.period", 1, "RateLimit period in seconds")
+	fs.Int("ratelimit.burst", 0, "RateLimit token bucket burst, default equals rate")
+	// driver redis
+	fs.String("ratelimit.rdb.host", "127.0.0.1", "RateLimit store rdb host")
+	fs.String("ratelimit.rdb.pass", "", "RateLimit store rdb pass")
+	fs.Int("ratelimit.rdb.port", 6379, "RateLimit store rdb port")
+	fs.Int("ratelimit.rdb.db", 0, "RateLimit store rdb db")
+	fs.Bool("ratelimit.rdb.debug", false, "RateLimit store rdb debug")
+	return fs
+}
+
+func (c *Config) Validate() error {
+	if c == nil {
+		return errors.New("ratelimit: config is nil")
+	}
+	if c.Rate < 0 || c.Period < 0 || c.Burst < 0 {
+		return errors.New("ratelimit: rate, period and burst cannot be negative")
+	}
+	if c.Rate > 0 {
+		if err := c.limit().validate(); err != nil {
+			return err
+		}
+	}
+	switch c.Driver {
+	case "", DriverMemory:
+	case DriverRedis:
+		if strings.TrimSpace(c.RDB.Host) == "" {
+			return errors.New("ratelimit: rdb.host is required for the rdb driver")
+		}
+		if c.RDB.Port <= 0 || c.RDB.Port > 65535 {
+			return errors.New("ratelimit: rdb.port is invalid")
+		}
+		if c.RDB.DB < 0 {
+			return errors.New("ratelimit: rdb.db cannot be negative")
+		}
+	default:
+		return fmt.Errorf("ratelimit: unknown driver %q", c.Driver)
+	}
+	return nil
+}
+
+func (c *Config) limit() Limit {
+	return Limit{
+		Algorithm: c.Algorithm,
+		Rate:      c.Rate,
+		Period:    time.Duration(c.Period) * time.Second,
+		Burst:     c.Burst,
+	}.normalize()
+}
+
+// NewStore 根据配置创建计数存储
+func NewStore(cfg *Config) (Store, error) {
+	switch cfg.Driver {
+	case "", DriverMemory:
+		return NewMemoryStore(), nil
+	case DriverRedis:
+		client, err := rdb.New(&cfg.RDB)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStore(client)
+	default:
+		return nil, fmt.Errorf("ratelimit: unknown driver %q", cfg.Driver)
+	}
+}
+
+// New 根据配置创建限流器，Rate 大于 0 时作为已认证与匿名请求的默认限额，opts 可覆盖
+func New(cfg *Config, opts ...Option) (*Limiter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Rate > 0 {
+		opts = append([]Option{WithLimit(cfg.limit())}, opts...)
+	}
+	return NewLimiter(store, opts...)
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore 进程内计数，适用于单实例部署
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// bucket 令牌桶使用 tokens/last，滑动窗口使用 window/prev/curr
+type bucket struct {
+	tokens float64
+	last   time.Time
+	window int64
+	prev   int
+	curr   int
+	expire time.Time
+}
+
+// NewMemoryStore now 为可选的自定义时钟，用于测试
+func NewMemoryStore(now ...func() time.Time) *MemoryStore {
+	s := &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+	if len(now) > 0 && now[0] != nil {
+		s.now = now[0]
+	}
+	return s
+}
+
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	if limit.Algorithm == SlidingWindow {
+		return b.slidingWindow(now, limit), nil
+	}
+	return b.tokenBucket(now, limit), nil
+}
+
+func (s *MemoryStore) Refund(_ context.Context, key string, limit Limit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		return nil
+	}
+	if limit.Algorithm == SlidingWindow {
+		// 已进入下一窗口时无需退还
+		if b.window == s.now().UnixNano()/int64(limit.Period) && b.curr > 0 {
+			b.curr--
+		}
+		return nil
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	return nil
+}
+
+func (b *bucket) tokenBucket(now time.Time, l Limit) Result {
+	perNano := float64(l.Rate) / float64(l.Period)
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(l.Burst), b.tokens+float64(elapsed)*perNano)
+	}
+	b.last = now
+	res := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / perNano))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration(math.Ceil((float64(l.Burst) - b.tokens) / perNano))
+	b.expire = now.Add(res.Reset)
+	return res
+}
+
+func (b *bucket) slidingWindow(now time.Time, l Limit) Result {
+	period := int64(l.Period)
+	window := now.UnixNano() / period
+	switch window - b.window {
+	case 0:
+	case 1:
+		b.prev, b.curr = b.curr, 0
+	default:
+		b.prev, b.curr = 0, 0
+	}
+	b.window = window
+	elapsed := now.UnixNano() - window*period
+	rest := time.Duration(period - elapsed)
+	b.expire = now.Add(rest + l.Period)
+	estimate := float64(b.prev)*(1-float64(elapsed)/float64(period)) + float64(b.curr)
+	if estimate+1 > float64(l.Rate) {
+		return Result{RetryAfter: slidingRetry(b.prev, b.curr, l.Rate, elapsed, period), Reset: rest}
+	}
+	b.curr++
+	return Result{Allowed: true, Remaining: max(0, int(float64(l.Rate)-estimate-1)), Reset: rest}
+}
+
+// slidingRetry 估算前一窗口的权重衰减到可再放行一次所需的时间，最多等到当前窗口结束
+func slidingRetry(prev, curr, rate int, elapsed, period int64) time.Duration {
+	retry := period - elapsed
+	if prev > 0 {
+		if t := int64(float64(period)*float64(prev+curr+1-rate)/float64(prev)) - elapsed; t >= 0 && t < retry {
+			retry = t
+		}
+	}
+	return time.Duration(retry)
+}
+
+// sweep 每分钟清理一次已恢复满额的计数
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.After(b.expire) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Package ratelimit 按认证主体限流
+//
+// 默认以 API Key ID、session 用户ID 区分调用方，匿名请求按客户端IP。支持令牌桶与滑动窗口两种算法，
+// 可为单个 API Key 或角色配置不同的限额，多个限额（如每秒速率与每日配额）同时生效。
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/apikey"
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+// Algorithm 限流算法
+type Algorithm string
+
+const (
+	// TokenBucket 令牌桶：以 Rate/Period 的速度补充令牌，最多积累 Burst 个，允许短时突发
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow 滑动窗口：任意 Period 内最多 Rate 次，按前一窗口加权估算
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// Limit 一条限额
+type Limit struct {
+	Algorithm Algorithm     // 默认 TokenBucket
+	Rate      int           // 每个 Period 允许的请求数
+	Period    time.Duration // 默认 1 秒
+	Burst     int           // 令牌桶容量，默认等于 Rate
+}
+
+// PerSecond 返回每秒 n 次的令牌桶限额
+func PerSecond(n int) Limit { return Limit{Rate: n, Period: time.Second} }
+
+// PerMinute 返回每分钟 n 次的令牌桶限额
+func PerMinute(n int) Limit { return Limit{Rate: n, Period: time.Minute} }
+
+// PerDay 返回每天 n 次的滑动窗口配额
+func PerDay(n int) Limit { return Limit{Algorithm: SlidingWindow, Rate: n, Period: 24 * time.Hour} }
+
+func (l Limit) normalize() Limit {
+	if l.Algorithm == "" {
+		l.Algorithm = TokenBucket
+	}
+	if l.Period <= 0 {
+		l.Period = time.Second
+	}
+	if l.Burst <= 0 {
+		l.Burst = l.Rate
+	}
+	return l
+}
+
+func (l Limit) validate() error {
+	switch {
+	case l.Rate <= 0:
+		return fmt.Errorf("ratelimit: rate must be positive")
+	case l.Algorithm != TokenBucket && l.Algorithm != SlidingWindow:
+		return fmt.Errorf("ratelimit: unknown algorithm %q", l.Algorithm)
+	}
+	return nil
+}
+
+// id 区分同一调用方的不同限额，限额变化后使用新的计数
+func (l Limit) id() string {
+	return fmt.Sprintf("%c%d/%d/%d", l.Algorithm[0], l.Rate, l.Period.Milliseconds(), l.Burst)
+}
+
+// Result 一次限流检查的结果
+type Result struct {
+	Limit      Limit // 结果对应的限额，多个限额时为最接近耗尽的一条
+	Allowed    bool
+	Remaining  int           // 本次之后剩余的请求数
+	RetryAfter time.Duration // 被拒绝时需等待的时间
+	Reset      time.Duration // 额度完全恢复的时间
+}
+
+// Store 限流计数存储，Allow 需原子地检查并消耗一次额度
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	// Refund 退还一次 Allow 消耗的额度，后续限额拒绝请求时调用
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+// KeyFunc 返回请求的限流标识
+type KeyFunc func(r *http.Request) string
+
+// ByPrincipal 默认限流标识：KeyStore 中的 API Key 为 "key:<id>"，静态配置的 API Key 为 "static:<label>"（见 apikey.LabelFromContext），
+// 已登录用户为 "user:<id>"（多租户时带租户前缀），其余按客户端IP为 "ip:<addr>"
+func ByPrincipal(r *http.Request) string {
+	ctx := r.Context()
+	if k, ok := apikey.FromContext(ctx); ok {
+		return "key:" + k.ID
+	}
+	if label, ok := apikey.LabelFromContext(ctx); ok {
+		return "static:" + label
+	}
+	if p, ok := principal.FromContext(ctx); ok && p.ID != 0 {
+		if p.Tenant != "" {
+			return p.Tenant + ":user:" + strconv.FormatUint(p.ID, 10)
+		}
+		return "user:" + strconv.FormatUint(p.ID, 10)
+	}
+	return "ip:" + clientIP(r)
+}
+
+type ipCtxKey struct{}
+
+// clientIP 优先使用 gin 的 c.ClientIP() 或 audit.Mw 记录的客户端IP（受 TrustedProxies 控制）
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ipCtxKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	if req, ok := audit.RequestFromContext(r.Context()); ok && req.RemoteIP != "" {
+		return req.RemoteIP
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+type Option func(*Limiter)
+
+// WithLimit 已认证请求的默认限额
+func WithLimit(limits ...Limit) Option {
+	return func(l *Limiter) { l.limits = slices.Clone(limits) }
+}
+
+// WithAnonymousLimit 匿名请求的限额，默认与 WithLimit 相同
+func WithAnonymousLimit(limits ...Limit) Option {
+	return func(l *Limiter) { l.anonymous = slices.Clone(limits) }
+}
+
+// WithRoleLimit 拥有 role 的主体使用的限额，多个角色匹配时按注册顺序取第一个
+func WithRoleLimit(role string, limits ...Limit) Option {
+	return func(l *Limiter) { l.roles = append(l.roles, roleLimit{role: role, limits: slices.Clone(limits)}) }
+}
+
+// WithKeyLimit 指定 API Key ID 使用的限额，优先于角色限额
+func WithKeyLimit(id string, limits ...Limit) Option {
+	return func(l *Limiter) { l.keys[id] = slices.Clone(limits) }
+}
+
+// WithKeyFunc 自定义限流标识，默认 ByPrincipal
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(l *Limiter) {
+		if fn != nil {
+			l.keyFunc = fn
+		}
+	}
+}
+
+// WithFailClosed 存储不可用时拒绝请求（503），默认放行并记录错误
+func WithFailClosed() Option {
+	return func(l *Limiter) { l.failClosed = true }
+}
+
+type roleLimit struct {
+	role   string
+	limits []Limit
+}
+
+// Limiter 限流器
+type Limiter struct {
+	store      Store
+	keyFunc    KeyFunc
+	limits     []Limit
+	anonymous  []Limit
+	roles      []roleLimit
+	keys       map[string][]Limit
+	failClosed bool
+}
+
+// NewLimiter 返回使用 store 计数的限流器，未配置任何限额时返回错误
+func NewLimiter(store Store, opts ...Option) (*Limiter, error) {
+	l := &Limiter{store: store, keyFunc: ByPrincipal, keys: make(map[string][]Limit)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if store == nil {
+		return nil, fmt.Errorf("ratelimit: store is nil")
+	}
+	if l.anonymous == nil {
+		l.anonymous = l.limits
+	}
+	all := [][]Limit{l.limits, l.anonymous}
+	for _, rl := range l.roles {
+		all = append(all, rl.limits)
+	}
+	for _, limits := range l.keys {
+		all = append(all, limits)
+	}
+	configured := false
+	for _, limits := range all {
+		for i := range limits {
+			limits[i] = limits[i].normalize()
+			if err := limits[i].validate(); err != nil {
+				return nil, err
+			}
+			configured = true
+		}
+	}
+	if !configured {
+		return nil, fmt.Errorf("ratelimit: no limits configured")
+	}
+	return l, nil
+}
+
+// limitsFor 按 API Key、角色、已认证默认、匿名的顺序选择限额
+func (l *Limiter) limitsFor(r *http.Request) []Limit {
+	ctx := r.Context()
+	if k, ok := apikey.FromContext(ctx); ok {
+		if limits, ok := l.keys[k.ID]; ok {
+			return limits
+		}
+	}
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return l.anonymous
+	}
+	for _, rl := range l.roles {
+		if p.HasRole(rl.role) {
+			return rl.limits
+		}
+	}
+	return l.limits
+}
+
+// Allow 检查并消耗请求的全部限额，返回最接近耗尽的结果；任一限额被拒绝时 Allowed 为 false，
+// 且已消耗的其他限额被退还
+//
+// 请求没有适用的限额时返回 Allowed 且 Remaining 为 -1。
+func (l *Limiter) Allow(r *http.Request) (Result, error) {
+	res, _, err := l.allow(r)
+	return res, err
+}
+
+func (l *Limiter) allow(r *http.Request) (Result, []Limit, error) {
+	limits := l.limitsFor(r)
+	if len(limits) == 0 {
+		return Result{Allowed: true, Remaining: -1}, nil, nil
+	}
+	key := l.keyFunc(r)
+	var worst Result
+	for i, limit := range limits {
+		res, err := l.store.Allow(r.Context(), key+"|"+limit.id(), limit)
+		if err != nil {
+			l.refund(r.Context(), key, limits[:i])
+			return Result{}, limits, err
+		}
+		res.Limit = limit
+		if !res.Allowed {
+			// 被拒绝的请求不占用其他限额，后续限额不再检查
+			l.refund(r.Context(), key, limits[:i])
+			return res, limits, nil
+		}
+		if i == 0 || tighter(res, worst) {
+			worst = res
+		}
+	}
+	return worst, limits, nil
+}
+
+// refund 退还已消耗的限额，失败时只记录错误
+func (l *Limiter) refund(ctx context.Context, key string, limits []Limit) {
+	for _, limit := range limits {
+		if err := l.store.Refund(ctx, key+"|"+limit.id(), limit); err != nil {
+			zap.L().Warn("ratelimit: refund failed", zap.Error(err))
+		}
+	}
+}
+
+// tighter 比较剩余比例，被拒绝的结果优先
+func tighter(a, b Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	return float64(a.Remaining)/float64(a.Limit.Rate) < float64(b.Remaining)/float64(b.Limit.Rate)
+}
+
+// Mw 限流中间件，需挂在认证中间件之后，以便按认证主体区分
+func Mw(l *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ipCtxKey{}, c.ClientIP()))
+		if !l.check(c.Writer, c.Request) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Middleware net/http 版本的 Mw
+func Middleware(l *Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.check(w, r) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// check 写出 RateLimit-* 头，超限时写出 429 与 Retry-After 并返回 false
+func (l *Limiter) check(w http.ResponseWriter, r *http.Request) bool {
+	res, limits, err := l.allow(r)
+	if err != nil {
+		zap.L().Error("ratelimit: store failed", zap.Error(err))
+		if l.failClosed {
+			problem.Write(w, r, problem.ForStatus(r, http.StatusServiceUnavailable))
+			return false
+		}
+		return true
+	}
+	if len(limits) == 0 {
+		return true
+	}
+	setHeaders(w.Header(), res, limits)
+	if res.Allowed {
+		return true
+	}
+	retry := seconds(res.RetryAfter)
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	audit.Emit(r.Context(), audit.Event{
+		Type:   audit.RateLimited,
+		Reason: "too_many_requests",
+		Fields: map[string]any{"key": l.keyFunc(r), "retry_after": retry},
+	})
+	problem.Write(w, r, problem.ForStatus(r, http.StatusTooManyRequests).With("retry_after", retry))
+	return false
+}
+
+// setHeaders 按 IETF RateLimit 头草案写出 RateLimit-Limit/Remaining/Reset 与 RateLimit-Policy
+func setHeaders(h http.Header, res Result, limits []Limit) {
+	policies := make([]string, len(limits))
+	for i, l := range limits {
+		policies[i] = fmt.Sprintf("%d;w=%d", l.Rate, seconds(l.Period))
+	}
+	h.Set("RateLimit-Policy", strings.Join(policies, ", "))
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Rate))
+	h.Set("RateLimit-Remaining", strconv.Itoa(max(res.Remaining, 0)))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+}
+
+// seconds 向上取整为秒
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,355 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mulan-ext/auth/apikey"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/ratelimit"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	clk := &clock{t: time.Unix(1_700_000_000, 0)}
+	store := ratelimit.NewMemoryStore(clk.now)
+	ctx := context.Background()
+	limit := ratelimit.Limit{Algorithm: ratelimit.TokenBucket, Rate: 2, Period: time.Second, Burst: 3}
+	for i := range 3 {
+		res, err := store.Allow(ctx, "k", limit)
+		if err != nil || !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d: %+v, %v", i, res, err)
+		}
+	}
+	res, _ := store.Allow(ctx, "k", limit)
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected rejection with 500ms retry, got %+v", res)
+	}
+	clk.advance(500 * time.Millisecond)
+	if res, _ := store.Allow(ctx, "k", limit); !res.Allowed {
+		t.Fatalf("expected refilled token, got %+v", res)
+	}
+	if res, _ := store.Allow(ctx, "other", limit); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("keys must be counted separately, got %+v", res)
+	}
+}
+
+func TestMemoryStore_SlidingWindow(t *testing.T) {
+	clk := &clock{t: time.Unix(1_700_000_000, 0)}
+	store := ratelimit.NewMemoryStore(clk.now)
+	ctx := context.Background()
+	limit := ratelimit.Limit{Algorithm: ratelimit.SlidingWindow, Rate: 4, Period: time.Minute}
+	for range 4 {
+		if res, _ := store.Allow(ctx, "k", limit); !res.Allowed {
+			t.Fatalf("expected allowed, got %+v", res)
+		}
+	}
+	res, _ := store.Allow(ctx, "k", limit)
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Fatalf("expected rejection, got %+v", res)
+	}
+	// 下一窗口开始时仍按上一窗口加权计数，不会立即恢复全部额度
+	clk.advance(time.Minute)
+	allowed := 0
+	for range 4 {
+		if res, _ := store.Allow(ctx, "k", limit); res.Allowed {
+			allowed++
+		}
+	}
+	if allowed == 4 {
+		t.Fatal("sliding window must weight the previous window")
+	}
+	clk.advance(2 * time.Minute)
+	if res, _ := store.Allow(ctx, "k", limit); !res.Allowed || res.Remaining != 3 {
+		t.Fatalf("expected full quota after two windows, got %+v", res)
+	}
+}
+
+func TestNewLimiter_Validate(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	if _, err := ratelimit.NewLimiter(store); err == nil {
+		t.Error("expected error without limits")
+	}
+	if _, err := ratelimit.NewLimiter(store, ratelimit.WithLimit(ratelimit.Limit{Rate: 0})); err == nil {
+		t.Error("expected error for zero rate")
+	}
+	if _, err := ratelimit.NewLimiter(store, ratelimit.WithLimit(ratelimit.Limit{Algorithm: "leaky", Rate: 1})); err == nil {
+		t.Error("expected error for unknown algorithm")
+	}
+	if _, err := ratelimit.NewLimiter(nil, ratelimit.WithLimit(ratelimit.PerSecond(1))); err == nil {
+		t.Error("expected error for nil store")
+	}
+}
+
+// withPrincipal 模拟认证中间件
+func withPrincipal(p *principal.Principal) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p != nil {
+			c.Request = c.Request.WithContext(principal.NewContext(c.Request.Context(), p))
+		}
+	}
+}
+
+func newRouter(l *ratelimit.Limiter, p *principal.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(withPrincipal(p), ratelimit.Mw(l))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	return r
+}
+
+func get(r http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMw_TooManyRequests(t *testing.T) {
+	l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.WithLimit(ratelimit.PerSecond(2), ratelimit.PerDay(100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRouter(l, &principal.Principal{ID: 7, Method: principal.MethodSession})
+
+	w := get(r, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "2;w=1, 100;w=86400" {
+		t.Errorf("unexpected RateLimit-Policy %q", got)
+	}
+	if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+	get(r, "")
+	w = get(r, "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected headers: %v", w.Header())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("expected problem+json, got %q", ct)
+	}
+}
+
+func TestMw_RejectRefundsOtherLimits(t *testing.T) {
+	c := &clock{t: time.Now()}
+	// 每日配额在前，被每秒限额拒绝的请求不应占用配额
+	l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(c.now),
+		ratelimit.WithLimit(ratelimit.PerDay(3), ratelimit.PerSecond(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRouter(l, &principal.Principal{ID: 7, Method: principal.MethodSession})
+
+	want := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for i, code := range want {
+		if w := get(r, ""); w.Code != code {
+			t.Fatalf("request %d: expected %d, got %d", i, code, w.Code)
+		}
+	}
+	for i := range 2 {
+		c.advance(time.Second)
+		if w := get(r, ""); w.Code != http.StatusOK {
+			t.Fatalf("daily quota spent by rejected requests: request %d got %d", i, w.Code)
+		}
+	}
+	c.advance(time.Second)
+	if w := get(r, ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected daily quota exhausted, got %d", w.Code)
+	}
+}
+
+func TestMw_StaticKeysByLabel(t *testing.T) {
+	l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.WithLimit(ratelimit.PerMinute(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apikey.Mw(&apikey.Config{Name: "X-API-Key", Values: []string{"first-key", "second-key"}}), ratelimit.Mw(l))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	do := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	// 同一IP的两个静态key各自计数
+	if do("first-key") != http.StatusOK || do("second-key") != http.StatusOK {
+		t.Fatal("static keys from the same IP share a limit")
+	}
+	if code := do("first-key"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for the same key, got %d", code)
+	}
+}
+
+func TestMw_RoleAndKeyLimits(t *testing.T) {
+	store := apikey.NewMemKeyStore()
+	ctx := context.Background()
+	key, err := apikey.Issue(ctx, store, &apikey.Key{UserID: 1, Roles: []string{"partner"}}, apikey.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.WithLimit(ratelimit.PerMinute(1)),
+		ratelimit.WithRoleLimit("partner", ratelimit.PerMinute(3)),
+		ratelimit.WithKeyLimit(apikey.KeyID(key), ratelimit.PerMinute(5)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(r http.Handler, header map[string]string) int {
+		n := 0
+		for range 10 {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code == http.StatusOK {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := count(newRouter(l, &principal.Principal{ID: 2}), nil); n != 1 {
+		t.Errorf("default limit: expected 1 allowed, got %d", n)
+	}
+	if n := count(newRouter(l, &principal.Principal{ID: 3, Roles: []string{"partner"}}), nil); n != 3 {
+		t.Errorf("role limit: expected 3 allowed, got %d", n)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(apikey.Mw(&apikey.Config{Name: "X-API-Key"}, apikey.WithStore(store)), ratelimit.Mw(l))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	if n := count(r, map[string]string{"X-API-Key": key}); n != 5 {
+		t.Errorf("key limit: expected 5 allowed, got %d", n)
+	}
+}
+
+func TestMiddleware_AnonymousByIP(t *testing.T) {
+	l, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(),
+		ratelimit.WithLimit(ratelimit.PerMinute(100)),
+		ratelimit.WithAnonymousLimit(ratelimit.PerMinute(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ratelimit.Middleware(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if w := get(h, "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w := get(h, "192.0.2.1:5678"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for same IP, got %d", w.Code)
+	}
+	if w := get(h, "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for another IP, got %d", w.Code)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, context.DeadlineExceeded
+}
+
+func (failingStore) Refund(context.Context, string, ratelimit.Limit) error {
+	return context.DeadlineExceeded
+}
+
+func TestMw_StoreFailure(t *testing.T) {
+	open, _ := ratelimit.NewLimiter(failingStore{}, ratelimit.WithLimit(ratelimit.PerSecond(1)))
+	if w := get(newRouter(open, nil), ""); w.Code != http.StatusOK {
+		t.Errorf("fail open: expected 200, got %d", w.Code)
+	}
+	closed, _ := ratelimit.NewLimiter(failingStore{}, ratelimit.WithLimit(ratelimit.PerSecond(1)), ratelimit.WithFailClosed())
+	if w := get(newRouter(closed, nil), ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("fail closed: expected 503, got %d", w.Code)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cases := []struct {
+		cfg     ratelimit.Config
+		wantErr bool
+	}{
+		{ratelimit.Config{}, false},
+		{ratelimit.Config{Rate: 10, Period: 60}, false},
+		{ratelimit.Config{Rate: -1}, true},
+		{ratelimit.Config{Rate: 1, Algorithm: "leaky"}, true},
+		{ratelimit.Config{Driver: "etcd"}, true},
+		{ratelimit.Config{Driver: ratelimit.DriverRedis}, true},
+	}
+	for i, tc := range cases {
+		if err := tc.cfg.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("case %d: Validate() = %v, wantErr %v", i, err, tc.wantErr)
+		}
+	}
+	l, err := ratelimit.New(&ratelimit.Config{Rate: 1, Period: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := l.Allow(httptest.NewRequest(http.MethodGet, "/", nil)); !res.Allowed || res.Limit.Period != time.Minute {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	store, err := ratelimit.NewRedisStore(client, "test:ratelimit:"+strconv.FormatInt(time.Now().UnixNano(), 10)+":")
+	if err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+	ctx := context.Background()
+	for _, limit := range []ratelimit.Limit{
+		{Algorithm: ratelimit.TokenBucket, Rate: 3, Period: time.Minute, Burst: 3},
+		{Algorithm: ratelimit.SlidingWindow, Rate: 3, Period: time.Minute},
+	} {
+		for i := range 3 {
+			res, err := store.Allow(ctx, string(limit.Algorithm), limit)
+			if err != nil || !res.Allowed || res.Remaining != 2-i {
+				t.Fatalf("%s request %d: %+v, %v", limit.Algorithm, i, res, err)
+			}
+		}
+		res, err := store.Allow(ctx, string(limit.Algorithm), limit)
+		if err != nil || res.Allowed || res.RetryAfter <= 0 {
+			t.Fatalf("%s: expected rejection, got %+v, %v", limit.Algorithm, res, err)
+		}
+		if err := store.Refund(ctx, string(limit.Algorithm), limit); err != nil {
+			t.Fatal(err)
+		}
+		if res, err := store.Allow(ctx, string(limit.Algorithm), limit); err != nil || !res.Allowed {
+			t.Fatalf("%s: refund not applied: %+v, %v", limit.Algorithm, res, err)
+		}
+	}
+}
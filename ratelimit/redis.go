@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultKeyPrefix RedisStore 默认key前缀
+const DefaultKeyPrefix = "ginx:auth:ratelimit:"
+
+var _ Store = (*RedisStore)(nil)
+
+// RedisStore 使用 Lua 脚本原子地检查并计数，多实例共享额度，时间取 Redis 服务器时钟
+//
+// 限流标识包在 hash tag 中，滑动窗口的两个计数key位于同一 slot，可用于 Redis Cluster。
+type RedisStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+func NewRedisStore(client redis.UniversalClient, prefix ...string) (*RedisStore, error) {
+	s := &RedisStore{client: client, keyPrefix: DefaultKeyPrefix}
+	if len(prefix) > 0 && prefix[0] != "" {
+		s.keyPrefix = prefix[0]
+	}
+	return s, client.Ping(context.Background()).Err()
+}
+
+// tokenBucketScript 返回 {allowed, remaining, retry_us, reset_us}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+local per = rate / period
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * per)
+end
+local allowed, retry = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / per)
+end
+local reset = math.ceil((burst - tokens) / per)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(reset / 1000) + 1000)
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// slidingWindowScript 返回 {allowed, remaining, retry_us, reset_us}
+var slidingWindowScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local window = math.floor(now / period)
+local elapsed = now - window * period
+local curr_key = KEYS[1] .. ':' .. window
+local prev = tonumber(redis.call('GET', KEYS[1] .. ':' .. (window - 1)) or '0')
+local curr = tonumber(redis.call('GET', curr_key) or '0')
+local rest = period - elapsed
+local estimate = prev * (1 - elapsed / period) + curr
+if estimate + 1 > rate then
+  local retry = rest
+  if prev > 0 then
+    local t2 = period * (prev + curr + 1 - rate) / prev - elapsed
+    if t2 >= 0 and t2 < retry then
+      retry = t2
+    end
+  end
+  return {0, 0, math.ceil(retry), rest}
+end
+redis.call('INCR', curr_key)
+redis.call('PEXPIRE', curr_key, math.ceil(period * 2 / 1000))
+return {1, math.max(0, math.floor(rate - estimate - 1)), 0, rest}
+`)
+
+// refundTokenScript 退还一个令牌，不超过 burst
+var refundTokenScript = redis.NewScript(`
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens then
+  redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[1]), tokens + 1)))
+end
+return 0
+`)
+
+// refundWindowScript 当前窗口的计数减一，已进入下一窗口时无需退还
+var refundWindowScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local curr_key = KEYS[1] .. ':' .. math.floor(now / tonumber(ARGV[1]))
+if tonumber(redis.call('GET', curr_key) or '0') > 0 then
+  redis.call('DECR', curr_key)
+end
+return 0
+`)
+
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	keys := []string{s.keyPrefix + "{" + key + "}"}
+	period := limit.Period.Microseconds()
+	var (
+		vals []int64
+		err  error
+	)
+	if limit.Algorithm == SlidingWindow {
+		vals, err = slidingWindowScript.Run(ctx, s.client, keys, limit.Rate, period).Int64Slice()
+	} else {
+		vals, err = tokenBucketScript.Run(ctx, s.client, keys, limit.Rate, period, limit.Burst).Int64Slice()
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Microsecond,
+		Reset:      time.Duration(vals[3]) * time.Microsecond,
+	}, nil
+}
+
+func (s *RedisStore) Refund(ctx context.Context, key string, limit Limit) error {
+	keys := []string{s.keyPrefix + "{" + key + "}"}
+	if limit.Algorithm == SlidingWindow {
+		return refundWindowScript.Run(ctx, s.client, keys, limit.Period.Microseconds()).Err()
+	}
+	return refundTokenScript.Run(ctx, s.client, keys, limit.Burst).Err()
+}
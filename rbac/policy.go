@@ -0,0 +1,158 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Policy 角色与权限定义
+//
+//	roles:
+//	  viewer:
+//	    permissions: ["orders:read"]
+//	  editor:
+//	    inherits: [viewer]
+//	    permissions: ["orders:write"]
+//	  admin:
+//	    permissions: ["*"]
+type Policy struct {
+	Roles map[string]Role `json:"roles" yaml:"roles"`
+}
+
+// Role 角色授予的权限及继承的角色
+type Role struct {
+	Permissions []string `json:"permissions" yaml:"permissions"`
+	Inherits    []string `json:"inherits" yaml:"inherits"`
+}
+
+// Provider 提供策略，用于从数据库等外部来源加载，配合 Authorizer.Reload 刷新
+type Provider interface {
+	Policy(ctx context.Context) (*Policy, error)
+}
+
+// ProviderFunc 函数形式的 Provider
+type ProviderFunc func(ctx context.Context) (*Policy, error)
+
+func (f ProviderFunc) Policy(ctx context.Context) (*Policy, error) { return f(ctx) }
+
+// FileProvider 从 JSON 或 YAML 文件加载策略，按扩展名选择格式
+type FileProvider string
+
+func (p FileProvider) Policy(context.Context) (*Policy, error) {
+	buf, err := os.ReadFile(string(p))
+	if err != nil {
+		return nil, fmt.Errorf("rbac: read policy: %w", err)
+	}
+	policy := &Policy{}
+	switch ext := strings.ToLower(filepath.Ext(string(p))); ext {
+	case ".json":
+		err = json.Unmarshal(buf, policy)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(buf, policy)
+	default:
+		return nil, fmt.Errorf("rbac: unsupported policy file extension %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rbac: decode policy: %w", err)
+	}
+	return policy, nil
+}
+
+// compiled 展开继承后的策略，角色 -> 权限列表
+type compiled map[string][]string
+
+// compile 校验权限格式并展开角色继承，继承未定义的角色或存在环时报错
+func compile(policy *Policy) (compiled, error) {
+	if policy == nil {
+		return nil, errors.New("rbac: policy is nil")
+	}
+	for name, role := range policy.Roles {
+		if strings.TrimSpace(name) == "" {
+			return nil, errors.New("rbac: role name is empty")
+		}
+		for _, perm := range role.Permissions {
+			if err := validPermission(perm); err != nil {
+				return nil, fmt.Errorf("rbac: role %s: %w", name, err)
+			}
+		}
+		for _, parent := range role.Inherits {
+			if _, ok := policy.Roles[parent]; !ok {
+				return nil, fmt.Errorf("rbac: role %s inherits undefined role %s", name, parent)
+			}
+		}
+	}
+
+	result := make(compiled, len(policy.Roles))
+	var expand func(name string, path []string) ([]string, error)
+	expand = func(name string, path []string) ([]string, error) {
+		if perms, ok := result[name]; ok {
+			return perms, nil
+		}
+		if slices.Contains(path, name) {
+			return nil, fmt.Errorf("rbac: role inheritance cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		role := policy.Roles[name]
+		perms := slices.Clone(role.Permissions)
+		for _, parent := range role.Inherits {
+			inherited, err := expand(parent, append(path, name))
+			if err != nil {
+				return nil, err
+			}
+			perms = append(perms, inherited...)
+		}
+		slices.Sort(perms)
+		perms = slices.Compact(perms)
+		result[name] = perms
+		return perms, nil
+	}
+	for name := range policy.Roles {
+		if _, err := expand(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func validPermission(perm string) error {
+	if perm == "" {
+		return errors.New("permission is empty")
+	}
+	for seg := range strings.SplitSeq(perm, ":") {
+		if seg == "" || strings.ContainsFunc(seg, func(r rune) bool { return r <= ' ' }) {
+			return fmt.Errorf("invalid permission %q", perm)
+		}
+		if strings.Contains(seg, "*") && seg != "*" {
+			return fmt.Errorf("invalid wildcard in permission %q", perm)
+		}
+	}
+	return nil
+}
+
+// Match 判断授予的权限 granted 是否覆盖 perm
+//
+// 权限以 ":" 分段，"*" 匹配单个分段，位于末尾时匹配其后的全部分段：
+// "orders:*" 覆盖 "orders:write" 与 "orders:items:read"，"*" 覆盖一切。
+func Match(granted, perm string) bool {
+	for {
+		g, gRest, gMore := strings.Cut(granted, ":")
+		p, pRest, pMore := strings.Cut(perm, ":")
+		if g == "*" && !gMore {
+			return p != ""
+		}
+		if g != "*" && g != p {
+			return false
+		}
+		if !gMore || !pMore {
+			return gMore == pMore
+		}
+		granted, perm = gRest, pRest
+	}
+}
@@ -0,0 +1,192 @@
+// Package rbac 基于权限的访问控制：角色授予权限、角色继承、通配符权限
+//
+// Authorizer 的中间件将自身写入请求，之后由 PermissionMW 或 Can 按当前 Principal
+// 的角色判定权限。同一请求内的判定结果会被缓存，策略热更新不影响进行中的请求。
+package rbac
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+// CtxKey gin.Context 中保存当前请求授权器的key
+const CtxKey = "github.com/mulan-ext/auth/rbac"
+
+// Authorizer 按策略判定角色权限，可安全并发使用
+type Authorizer struct {
+	provider Provider
+	policy   atomic.Pointer[compiled]
+}
+
+// New 以固定策略创建 Authorizer
+func New(policy *Policy) (*Authorizer, error) {
+	return FromProvider(context.Background(), ProviderFunc(func(context.Context) (*Policy, error) {
+		return policy, nil
+	}))
+}
+
+// Load 从 JSON 或 YAML 文件加载策略，调用 Reload 重新读取文件
+func Load(path string) (*Authorizer, error) {
+	return FromProvider(context.Background(), FileProvider(path))
+}
+
+// FromProvider 从 Provider 加载策略
+func FromProvider(ctx context.Context, provider Provider) (*Authorizer, error) {
+	if provider == nil {
+		return nil, errors.New("rbac: provider is nil")
+	}
+	a := &Authorizer{provider: provider}
+	if err := a.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload 重新从 Provider 加载策略，失败时保留原策略
+func (a *Authorizer) Reload(ctx context.Context) error {
+	policy, err := a.provider.Policy(ctx)
+	if err != nil {
+		return err
+	}
+	c, err := compile(policy)
+	if err != nil {
+		return err
+	}
+	a.policy.Store(&c)
+	return nil
+}
+
+// Permissions 返回角色展开继承后的全部权限
+func (a *Authorizer) Permissions(role string) []string {
+	return slices.Clone((*a.policy.Load())[role])
+}
+
+// Allowed 判断角色集合是否拥有权限
+func (a *Authorizer) Allowed(roles []string, perm string) bool {
+	return allowed(*a.policy.Load(), roles, perm)
+}
+
+func allowed(policy compiled, roles []string, perm string) bool {
+	for _, role := range roles {
+		for _, granted := range policy[role] {
+			if Match(granted, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decider 单个请求的授权上下文，固定请求开始时的策略并缓存判定结果
+type decider struct {
+	policy compiled
+	mu     sync.Mutex
+	who    *principal.Principal
+	cache  map[string]bool
+}
+
+func (d *decider) can(p *principal.Principal, perm string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// 请求中途登录会更换 Principal，此时缓存失效
+	if d.who != p {
+		d.who, d.cache = p, make(map[string]bool)
+	}
+	if ok, cached := d.cache[perm]; cached {
+		return ok
+	}
+	ok := allowed(d.policy, p.Roles, perm)
+	d.cache[perm] = ok
+	return ok
+}
+
+type ctxKey struct{}
+
+// NewContext 返回携带授权器的 context，用于 gin 与 net/http 之外的场景
+func (a *Authorizer) NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &decider{policy: *a.policy.Load()})
+}
+
+// Mw 将授权器写入请求，挂在认证中间件之前或之后均可
+func (a *Authorizer) Mw() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(a.NewContext(c.Request.Context()))
+		c.Set(CtxKey, a)
+		c.Next()
+	}
+}
+
+// Middleware net/http 版本的 Mw
+func (a *Authorizer) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(a.NewContext(r.Context())))
+		})
+	}
+}
+
+// CanContext 判断 context 中的 Principal 是否拥有权限，未认证或未挂载授权器时返回 false
+func CanContext(ctx context.Context, perm string) bool {
+	d, ok := ctx.Value(ctxKey{}).(*decider)
+	if !ok {
+		return false
+	}
+	p, ok := principal.FromContext(ctx)
+	return ok && d.can(p, perm)
+}
+
+// Can 判断当前用户是否拥有权限
+func Can(c *gin.Context, perm string) bool {
+	return CanContext(c.Request.Context(), perm)
+}
+
+// PermissionMW 权限中间件 - 要求用户拥有全部指定权限，未认证返回 401
+func PermissionMW(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if status := check(c.Request.Context(), perms); status != http.StatusOK {
+			problem.Abort(c, problem.ForStatus(c.Request, status))
+			return
+		}
+		c.Next()
+	}
+}
+
+// PermissionMiddleware net/http 版本的 PermissionMW
+func PermissionMiddleware(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status := check(r.Context(), perms); status != http.StatusOK {
+				problem.Write(w, r, problem.ForStatus(r, status))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check 返回权限判定对应的状态码，未挂载授权器视为配置错误
+func check(ctx context.Context, perms []string) int {
+	d, ok := ctx.Value(ctxKey{}).(*decider)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return http.StatusUnauthorized
+	}
+	for _, perm := range perms {
+		if !d.can(p, perm) {
+			return http.StatusForbidden
+		}
+	}
+	return http.StatusOK
+}
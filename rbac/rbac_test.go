@@ -0,0 +1,140 @@
+package rbac_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/rbac"
+)
+
+const testPolicy = `
+roles:
+  viewer:
+    permissions: ["orders:read", "reports:read"]
+  editor:
+    inherits: [viewer]
+    permissions: ["orders:write"]
+  manager:
+    inherits: [editor]
+    permissions: ["orders:*"]
+  admin:
+    permissions: ["*"]
+`
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		granted, perm string
+		want          bool
+	}{
+		{"orders:read", "orders:read", true},
+		{"orders:read", "orders:write", false},
+		{"orders:*", "orders:write", true},
+		{"orders:*", "orders:items:read", true},
+		{"orders:*", "orders", false},
+		{"orders:*:read", "orders:items:read", true},
+		{"orders:*:read", "orders:items:write", false},
+		{"orders", "orders:read", false},
+		{"*", "anything:at:all", true},
+	}
+	for _, tc := range cases {
+		if got := rbac.Match(tc.granted, tc.perm); got != tc.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tc.granted, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestLoadExpandsInheritance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(testPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authz, err := rbac.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := authz.Permissions("editor"); !slices.Equal(got, []string{"orders:read", "orders:write", "reports:read"}) {
+		t.Fatalf("editor permissions: %v", got)
+	}
+	if !authz.Allowed([]string{"manager"}, "orders:refund") || authz.Allowed([]string{"editor"}, "orders:refund") {
+		t.Fatal("wildcard permission not applied")
+	}
+	if authz.Allowed([]string{"unknown"}, "orders:read") {
+		t.Fatal("unknown role granted permission")
+	}
+
+	// Reload 失败时保留原策略
+	if err := os.WriteFile(path, []byte("roles:\n  a:\n    inherits: [b]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := authz.Reload(context.Background()); err == nil || !strings.Contains(err.Error(), "undefined role") {
+		t.Fatalf("expected undefined role error, got %v", err)
+	}
+	if !authz.Allowed([]string{"viewer"}, "orders:read") {
+		t.Fatal("failed reload replaced policy")
+	}
+}
+
+func TestPolicyValidation(t *testing.T) {
+	_, err := rbac.New(&rbac.Policy{Roles: map[string]rbac.Role{
+		"a": {Inherits: []string{"b"}},
+		"b": {Inherits: []string{"a"}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	_, err = rbac.New(&rbac.Policy{Roles: map[string]rbac.Role{"a": {Permissions: []string{"orders:wr*"}}}})
+	if err == nil {
+		t.Fatal("partial wildcard accepted")
+	}
+}
+
+func TestPermissionMW(t *testing.T) {
+	authz, err := rbac.New(&rbac.Policy{Roles: map[string]rbac.Role{
+		"viewer": {Permissions: []string{"orders:read"}},
+		"editor": {Inherits: []string{"viewer"}, Permissions: []string{"orders:write"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if role := c.GetHeader("X-Role"); role != "" {
+			c.Request = c.Request.WithContext(principal.NewContext(c.Request.Context(), &principal.Principal{ID: 1, Roles: []string{role}}))
+		}
+	}, authz.Mw())
+	router.POST("/orders", rbac.PermissionMW("orders:write"), func(c *gin.Context) {
+		c.String(http.StatusOK, "%v", rbac.Can(c, "orders:read"))
+	})
+
+	for role, want := range map[string]int{"": http.StatusUnauthorized, "viewer": http.StatusForbidden, "editor": http.StatusOK} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set("X-Role", role)
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("role %q: got %d want %d", role, w.Code, want)
+		}
+		if want == http.StatusOK && w.Body.String() != "true" {
+			t.Errorf("inherited permission not granted: %s", w.Body.String())
+		}
+	}
+}
+
+func TestPermissionMiddlewareRequiresAuthorizer(t *testing.T) {
+	handler := rbac.PermissionMiddleware("orders:read")(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("missing authorizer: got %d", w.Code)
+	}
+}
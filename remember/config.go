@@ -0,0 +1,76 @@
+package remember
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	// DefaultName 默认Cookie名称
+	DefaultName = "remember"
+	// DefaultMaxAge 默认记住时长: 30天
+	DefaultMaxAge = 30 * 24 * 60 * 60
+	// DefaultSessionTTL 恢复出的session有效期: 2小时
+	DefaultSessionTTL = 2 * 60 * 60
+	// DefaultGrace 旧validator的容忍窗口，用于并发请求携带同一Cookie的情况
+	DefaultGrace = 30
+)
+
+type Config struct {
+	Name       string `json:"name" yaml:"name"`
+	MaxAge     int    `json:"max_age" yaml:"max_age"`         // 记住时长（秒），每次轮换后重新计算
+	SessionTTL int    `json:"session_ttl" yaml:"session_ttl"` // 恢复出的session有效期（秒）
+	Grace      int    `json:"grace" yaml:"grace"`             // 轮换后旧validator仍可用的秒数，0 使用默认值，负数关闭
+	Secure     bool   `json:"secure" yaml:"secure"`
+}
+
+func (c *Config) FlagSet() *pflag.FlagSet { return FlagSet() }
+
+func FlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("remember", pflag.ContinueOnError)
+	fs.String("remember.name", DefaultName, "remember-me cookie name")
+	fs.Int("remember.max-age", DefaultMaxAge, "remember-me lifetime in seconds")
+	fs.Int("remember.session-ttl", DefaultSessionTTL, "lifetime in seconds of sessions restored from remember-me")
+	fs.Int("remember.grace", DefaultGrace, "seconds a rotated validator stays accepted (negative disables)")
+	fs.Bool("remember.secure", false, "mark remember-me cookie Secure")
+	return fs
+}
+
+func (c *Config) Validate() error {
+	if c == nil {
+		return errors.New("remember: config is nil")
+	}
+	if c.Name != "" {
+		if err := (&http.Cookie{Name: c.Name, Value: "remember"}).Valid(); err != nil {
+			return fmt.Errorf("remember: invalid name: %w", err)
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.New("remember: max_age cannot be negative")
+	}
+	if c.SessionTTL < 0 {
+		return errors.New("remember: session_ttl cannot be negative")
+	}
+	return nil
+}
+
+// withDefaults 返回填充默认值后的副本
+func (c *Config) withDefaults() Config {
+	conf := *c
+	if conf.Name == "" {
+		conf.Name = DefaultName
+	}
+	if conf.MaxAge == 0 {
+		conf.MaxAge = DefaultMaxAge
+	}
+	if conf.SessionTTL == 0 {
+		conf.SessionTTL = DefaultSessionTTL
+	}
+	if conf.Grace == 0 {
+		conf.Grace = DefaultGrace
+	}
+	return conf
+}
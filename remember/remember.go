@@ -0,0 +1,313 @@
+// Package remember 实现"记住我"持久登录
+//
+// 登录成功后通过 Issue 下发独立的持久Cookie（series.validator），服务端只保存validator的哈希。
+// 主session过期时中间件用该Cookie重新创建短期session，并在每次使用后轮换validator；
+// 若已轮换的旧validator在容忍窗口之外再次出现，视为Cookie被盗用，整个series立即作废。
+package remember
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+var (
+	// ErrInvalid Cookie格式无效
+	ErrInvalid = errors.New("remember: invalid cookie")
+	// ErrTheft 旧validator被重放，series已作废
+	ErrTheft = errors.New("remember: validator reuse detected, series revoked")
+	// ErrLocked 恢复的用户已被锁定，series已作废
+	ErrLocked = errors.New("remember: account locked, series revoked")
+)
+
+// Loader 将series恢复到新session，可在此重新加载用户信息或拒绝已禁用的用户
+//
+// 返回错误时series被删除。默认使用签发时保存的ID、账号、角色与状态；状态变更时应通过
+// session.UpdateUserState 的 hook 调用 Store.DeleteUser，或在 Loader 中重新读取状态。
+// 恢复后的状态含 session.StateLocked 时拒绝恢复。
+type Loader func(ctx context.Context, rec *Record, sess *session.Session) error
+
+type Option func(*Manager)
+
+// WithLoader 自定义session恢复逻辑
+func WithLoader(loader Loader) Option {
+	return func(m *Manager) {
+		if loader != nil {
+			m.loader = loader
+		}
+	}
+}
+
+// WithClock 自定义时钟，用于测试
+func WithClock(now func() time.Time) Option {
+	return func(m *Manager) {
+		if now != nil {
+			m.now = now
+		}
+	}
+}
+
+type Manager struct {
+	conf   Config
+	store  Store
+	loader Loader
+	now    func() time.Time
+}
+
+func New(cfg *Config, store Store, opts ...Option) (*Manager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("remember: store is nil")
+	}
+	m := &Manager{conf: cfg.withDefaults(), store: store, loader: defaultLoader, now: time.Now}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Issue 为已登录用户签发记住登录Cookie，通常在登录成功且用户勾选"记住我"后调用
+func (m *Manager) Issue(ctx context.Context, w http.ResponseWriter, data session.Data) error {
+	now := m.now()
+	validator := rand.Text()
+	rec := &Record{
+		Series:    rand.Text(),
+		Hash:      hash(validator),
+		RotatedAt: now,
+		Expire:    now.Add(time.Duration(m.conf.MaxAge) * time.Second),
+		ID:        data.ID(),
+		Account:   data.Account(),
+		Roles:     data.Roles(),
+		State:     data.State(),
+		Tenant:    session.TenantFromContext(ctx),
+	}
+	if err := m.store.Save(ctx, rec); err != nil {
+		return fmt.Errorf("remember: save series: %w", err)
+	}
+	m.setCookie(w, rec.Series+"."+validator, m.conf.MaxAge)
+	return nil
+}
+
+// Restore 当前session无效且请求携带记住登录Cookie时，校验并轮换validator，重新创建短期session
+//
+// 返回是否恢复了登录。Cookie无效、series不存在或检测到盗用时会过期Cookie。
+func (m *Manager) Restore(w http.ResponseWriter, r *http.Request, sess *session.Session) (bool, error) {
+	if !sess.IsNil {
+		return false, nil
+	}
+	series, validator, ok := m.parse(r)
+	if !ok {
+		return false, nil
+	}
+	if series == "" {
+		m.setCookie(w, "", -1)
+		return false, ErrInvalid
+	}
+	ctx := r.Context()
+	rec, err := m.store.Get(ctx, series)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			m.setCookie(w, "", -1)
+		}
+		return false, err
+	}
+	// 其他租户签发的Cookie不可用，但不视为盗用
+	if rec.Tenant != session.TenantFromContext(ctx) {
+		m.setCookie(w, "", -1)
+		return false, ErrInvalid
+	}
+
+	now := m.now()
+	presented := hash(validator)
+	switch {
+	case equal(presented, rec.Hash):
+		next := rand.Text()
+		rotated := *rec
+		rotated.Previous, rotated.Hash = rec.Hash, hash(next)
+		rotated.RotatedAt = now
+		rotated.Expire = now.Add(time.Duration(m.conf.MaxAge) * time.Second)
+		// 以读取时的 hash 比较后写入，并发请求只有一个能完成轮换
+		switch err := m.store.Rotate(ctx, &rotated, rec.Hash); {
+		case err == nil:
+			m.setCookie(w, series+"."+next, m.conf.MaxAge)
+		case errors.Is(err, ErrRotated):
+			// 读取时validator仍有效，新Cookie已由先完成轮换的响应下发
+		case errors.Is(err, ErrNotFound):
+			m.setCookie(w, "", -1)
+			return false, err
+		default:
+			return false, fmt.Errorf("remember: rotate series: %w", err)
+		}
+	case m.conf.Grace > 0 && rec.Previous != "" && equal(presented, rec.Previous) &&
+		now.Before(rec.RotatedAt.Add(time.Duration(m.conf.Grace)*time.Second)):
+		// 并发请求携带了轮换前的Cookie，新Cookie已由先到的响应下发
+	default:
+		if err := m.store.Delete(ctx, series); err != nil {
+			return false, err
+		}
+		m.setCookie(w, "", -1)
+		return false, ErrTheft
+	}
+
+	sess.Data().Clear()
+	if err := sess.Clear(); err != nil {
+		return false, fmt.Errorf("remember: rotate session: %w", err)
+	}
+	err = m.loader(ctx, rec, sess)
+	if err == nil && sess.Data().State()&session.StateLocked != 0 {
+		sess.Data().Clear()
+		err = ErrLocked
+	}
+	if err != nil {
+		_ = m.store.Delete(ctx, series)
+		m.setCookie(w, "", -1)
+		return false, err
+	}
+	// 记住登录恢复的session没有认证时间，RequireFreshAuth 会要求重新认证
+	sess.Data().Delete(session.ItemAuthTime)
+	sess.SetValues(session.ItemAuthMethod, principal.MethodRemember)
+	sess.SetMaxAge(m.conf.SessionTTL)
+	if err := sess.Save(time.Duration(m.conf.SessionTTL) * time.Second); err != nil {
+		return false, fmt.Errorf("remember: save session: %w", err)
+	}
+	data := sess.Data()
+	audit.Emit(ctx, audit.Event{
+		Type:    audit.LoginSucceeded,
+		Method:  principal.MethodRemember,
+		UserID:  data.ID(),
+		Account: data.Account(),
+		Tenant:  rec.Tenant,
+		Session: audit.TokenID(sess.Token()),
+	})
+	return true, nil
+}
+
+// Forget 删除当前请求的series并过期Cookie，退出登录时调用
+func (m *Manager) Forget(w http.ResponseWriter, r *http.Request) error {
+	series, _, ok := m.parse(r)
+	if !ok {
+		return nil
+	}
+	m.setCookie(w, "", -1)
+	if series == "" {
+		return nil
+	}
+	return m.store.Delete(r.Context(), series)
+}
+
+// Mw 挂在 session.Mw 之后，主session无效时用Cookie恢复登录
+func (m *Manager) Mw() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sess, ok := session.FromGin(c); ok && m.restore(c.Writer, c.Request, sess) {
+			session.Populate(c, sess.Data(), principal.MethodRemember)
+		}
+		c.Next()
+	}
+}
+
+// Middleware net/http 版本的 Mw，需挂在 session.Middleware 之后
+func (m *Manager) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sess, ok := session.FromContext(r.Context()); ok && m.restore(w, r, sess) {
+				r = session.WithPrincipal(r, sess.Data(), principal.MethodRemember)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ForgetMW 在退出登录处理器之前删除记住登录，失败时返回 500
+func (m *Manager) ForgetMW() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := m.Forget(c.Writer, c.Request); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ForgetMiddleware net/http 版本的 ForgetMW
+func (m *Manager) ForgetMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := m.Forget(w, r); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// restore 调用 Restore 并记录异常，中间件中恢复失败按未登录处理
+func (m *Manager) restore(w http.ResponseWriter, r *http.Request, sess *session.Session) bool {
+	restored, err := m.Restore(w, r, sess)
+	switch {
+	case err == nil, errors.Is(err, ErrNotFound), errors.Is(err, ErrInvalid), errors.Is(err, ErrLocked):
+	case errors.Is(err, ErrTheft):
+		zap.L().Warn("remember-me validator reuse detected", zap.String("remote", r.RemoteAddr))
+	default:
+		zap.L().Error("remember-me restore failed", zap.Error(err))
+	}
+	return restored
+}
+
+// parse 读取Cookie，ok 表示请求携带了Cookie，格式无效时 series 为空
+func (m *Manager) parse(r *http.Request) (series, validator string, ok bool) {
+	cookie, err := r.Cookie(m.conf.Name)
+	if err != nil || cookie.Value == "" {
+		return "", "", false
+	}
+	series, validator, found := strings.Cut(cookie.Value, ".")
+	if !found || series == "" || validator == "" {
+		return "", "", true
+	}
+	return series, validator, true
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.conf.Name,
+		Value:    value,
+		MaxAge:   maxAge,
+		Path:     "/",
+		Secure:   m.conf.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func defaultLoader(_ context.Context, rec *Record, sess *session.Session) error {
+	sess.SetID(rec.ID)
+	sess.SetAccount(rec.Account)
+	sess.SetRoles(rec.Roles)
+	sess.SetState(rec.State)
+	return nil
+}
+
+func hash(validator string) string {
+	sum := sha256.Sum256([]byte(validator))
+	return hex.EncodeToString(sum[:])
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
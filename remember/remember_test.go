@@ -0,0 +1,313 @@
+package remember_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/remember"
+	"github.com/mulan-ext/auth/session"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newRouter(t *testing.T, store remember.Store, now *clock) *gin.Engine {
+	t.Helper()
+	rm, err := remember.New(&remember.Config{Grace: 10}, store, remember.WithClock(now.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(session.Mw("token", session.NewMemStore()), rm.Mw())
+	router.POST("/login", func(c *gin.Context) {
+		sess := session.Default(c)
+		sess.SetID(7)
+		sess.SetAccount("alice")
+		sess.SetRoles([]string{"admin"})
+		if err := sess.Save(); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if err := rm.Issue(c.Request.Context(), c.Writer, sess.Data()); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/me", session.AuthMW(), func(c *gin.Context) {
+		p, _ := principal.FromContext(c.Request.Context())
+		c.String(http.StatusOK, p.Account+":"+p.Method)
+	})
+	router.POST("/logout", rm.ForgetMW(), session.LogoutHandler())
+	return router
+}
+
+func rememberCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	// 浏览器以最后一个同名 Set-Cookie 为准
+	var found *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == remember.DefaultName {
+			found = cookie
+		}
+	}
+	return found
+}
+
+func me(router *gin.Engine, cookie *http.Cookie) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRestoreRotatesAndDetectsTheft(t *testing.T) {
+	now := &clock{now: time.Now()}
+	router := newRouter(t, remember.NewMemStore(), now)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	first := rememberCookie(t, w)
+	if first == nil || !first.HttpOnly || first.MaxAge != remember.DefaultMaxAge {
+		t.Fatalf("unexpected remember cookie: %+v", first)
+	}
+
+	// 主session缺失时恢复登录并轮换validator
+	w = me(router, first)
+	if w.Code != http.StatusOK || w.Body.String() != "alice:"+principal.MethodRemember {
+		t.Fatalf("restore: %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Token") == "" {
+		t.Fatal("restore did not create a session")
+	}
+	second := rememberCookie(t, w)
+	if second == nil || second.Value == first.Value {
+		t.Fatalf("validator was not rotated: %+v", second)
+	}
+
+	// 容忍窗口内的并发请求仍可使用旧Cookie，且不再轮换
+	now.now = now.now.Add(5 * time.Second)
+	w = me(router, first)
+	if w.Code != http.StatusOK || rememberCookie(t, w) != nil {
+		t.Fatalf("grace request: %d cookie=%+v", w.Code, rememberCookie(t, w))
+	}
+
+	// 窗口外重放旧validator：整个series作废
+	now.now = now.now.Add(time.Minute)
+	w = me(router, first)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("stale validator accepted: %d", w.Code)
+	}
+	if cookie := rememberCookie(t, w); cookie == nil || cookie.MaxAge >= 0 {
+		t.Fatalf("theft did not expire cookie: %+v", cookie)
+	}
+	if w = me(router, second); w.Code != http.StatusUnauthorized {
+		t.Fatalf("series not revoked after theft: %d", w.Code)
+	}
+}
+
+func TestConcurrentRestoreRotatesOnce(t *testing.T) {
+	ch := make(chan audit.Event, 16)
+	audit.SetDefault(audit.NewLogger(audit.NewChanSink(ch)))
+	defer audit.SetDefault(nil)
+	router := newRouter(t, remember.NewMemStore(), &clock{now: time.Now()})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	first := rememberCookie(t, w)
+
+	const n = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	rotated := 0
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := me(router, first)
+			if w.Code != http.StatusOK {
+				t.Errorf("concurrent restore: %d", w.Code)
+			}
+			if rememberCookie(t, w) != nil {
+				mu.Lock()
+				rotated++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if rotated != 1 {
+		t.Fatalf("series rotated %d times, want 1", rotated)
+	}
+
+	logins := 0
+	for len(ch) > 0 {
+		if e := <-ch; e.Type == audit.LoginSucceeded {
+			if e.Method != principal.MethodRemember || e.UserID != 7 || e.Session == "" {
+				t.Fatalf("unexpected login event %+v", e)
+			}
+			logins++
+		}
+	}
+	if logins != n {
+		t.Fatalf("got %d login events, want %d", logins, n)
+	}
+}
+
+func TestForgetRevokesSeries(t *testing.T) {
+	router := newRouter(t, remember.NewMemStore(), &clock{now: time.Now()})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	cookie := rememberCookie(t, w)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	if expired := rememberCookie(t, w); w.Code != http.StatusNoContent || expired == nil || expired.MaxAge >= 0 {
+		t.Fatalf("logout: %d cookie=%+v", w.Code, expired)
+	}
+	if w = me(router, cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("forgotten series still valid: %d", w.Code)
+	}
+}
+
+func TestNetHTTPMiddleware(t *testing.T) {
+	store := remember.NewMemStore()
+	rm, err := remember.New(&remember.Config{}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := (&session.DefaultData{}).SetID(3).SetAccount("bob")
+	issued := httptest.NewRecorder()
+	if err := rm.Issue(context.Background(), issued, data); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := session.Middleware("token", session.NewMemStore())(rm.Middleware()(
+		session.AuthMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, _ := principal.FromContext(r.Context())
+			_, _ = w.Write([]byte(p.Account))
+		}))))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(issued.Result().Cookies()[0])
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "bob" || w.Result().Header.Get("X-Token") == "" {
+		t.Fatalf("restore over net/http: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	store, err := remember.NewRedisStore(client)
+	if err != nil {
+		t.Skip("Redis not available, skipping test")
+	}
+
+	ctx := context.Background()
+	rec := &remember.Record{Series: "test-series", Hash: "h", ID: 99, Expire: time.Now().Add(time.Minute)}
+	if err := store.Save(ctx, rec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, rec.Series)
+	if err != nil || got.ID != 99 || got.Hash != "h" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	next := *got
+	next.Previous, next.Hash = got.Hash, "h2"
+	if err := store.Rotate(ctx, &next, "h"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Rotate(ctx, &next, "h"); err != remember.ErrRotated {
+		t.Fatalf("stale rotate = %v, want ErrRotated", err)
+	}
+	if err := store.DeleteUser(ctx, "", 99); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, rec.Series); err != remember.ErrNotFound {
+		t.Fatalf("series survived DeleteUser: %v", err)
+	}
+}
+
+func TestDeleteUserPerTenant(t *testing.T) {
+	ctx := context.Background()
+	store := remember.NewMemStore()
+	for _, tenant := range []string{"", "acme", "globex"} {
+		if err := store.Save(ctx, &remember.Record{Series: "s-" + tenant, ID: 7, Tenant: tenant}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteUser(ctx, "acme", 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "s-acme"); err != remember.ErrNotFound {
+		t.Fatalf("series of acme survived: %v", err)
+	}
+	for _, series := range []string{"s-", "s-globex"} {
+		if _, err := store.Get(ctx, series); err != nil {
+			t.Fatalf("series %s of another tenant deleted: %v", series, err)
+		}
+	}
+}
+
+func TestLockedUserCannotRestore(t *testing.T) {
+	now := &clock{now: time.Now()}
+	store := remember.NewMemStore()
+	router := newRouter(t, store, now)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	cookie := rememberCookie(t, w)
+
+	lock := func(s uint16) uint16 { return s | session.StateLocked }
+	if _, err := session.UpdateUserState(context.Background(), session.NewMemStore(), "", 7, lock, store.DeleteUser); err != nil {
+		t.Fatal(err)
+	}
+	if w := me(router, cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("locked user restored by remember cookie: %d", w.Code)
+	}
+
+	// Loader 重新读取到锁定状态时同样拒绝
+	rm, err := remember.New(&remember.Config{}, store, remember.WithLoader(
+		func(_ context.Context, rec *remember.Record, sess *session.Session) error {
+			sess.SetID(rec.ID)
+			sess.SetState(session.StateLocked)
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	locked := gin.New()
+	locked.Use(session.Mw("token", session.NewMemStore()), rm.Mw())
+	locked.POST("/login", func(c *gin.Context) {
+		sess := session.Default(c)
+		sess.SetID(8)
+		_ = rm.Issue(c.Request.Context(), c.Writer, sess.Data())
+	})
+	locked.GET("/me", session.AuthMW(), func(c *gin.Context) {})
+	w = httptest.NewRecorder()
+	locked.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	if w := me(locked, rememberCookie(t, w)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("loader returned a locked user: %d", w.Code)
+	}
+}
@@ -0,0 +1,148 @@
+package remember
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultKeyPrefix 默认key前缀
+const DefaultKeyPrefix = "ginx:auth:remember:"
+
+var _ Store = (*RedisStore)(nil)
+
+// rotateScript 比较series当前的 hash 后写入新记录，KEYS[1] 为series，KEYS[2] 为用户索引，
+// ARGV 为 当前hash、新记录、过期毫秒数（0 表示不过期），返回 0 不存在、-1 已被轮换、1 成功
+var rotateScript = redis.NewScript(`
+local cur = redis.call("GET", KEYS[1])
+if not cur then return 0 end
+if cjson.decode(cur).hash ~= ARGV[1] then return -1 end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+redis.call("SADD", KEYS[2], cjson.decode(ARGV[2]).series)
+if ttl > 0 then redis.call("PEXPIRE", KEYS[2], ttl) end
+return 1
+`)
+
+// RedisStore Redis存储，series 以JSON保存并随 Expire 过期，用户索引用于 DeleteUser
+type RedisStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+func NewRedisStore(client redis.UniversalClient) (*RedisStore, error) {
+	s := &RedisStore{client: client, keyPrefix: DefaultKeyPrefix}
+	return s, client.Ping(context.Background()).Err()
+}
+
+func (s *RedisStore) Get(ctx context.Context, series string) (*Record, error) {
+	buf, err := s.client.Get(ctx, s.seriesKey(series)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &Record{}
+	if err := json.Unmarshal(buf, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, rec *Record) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(rec.Expire)
+	if rec.Expire.IsZero() {
+		ttl = 0
+	} else if ttl <= 0 {
+		return s.Delete(ctx, rec.Series)
+	}
+	userKey := s.userKey(rec.Tenant, rec.ID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.seriesKey(rec.Series), buf, ttl)
+		pipe.SAdd(ctx, userKey, rec.Series)
+		if ttl > 0 {
+			// 最近保存的series过期最晚，索引与其同寿命
+			pipe.Expire(ctx, userKey, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) Rotate(ctx context.Context, rec *Record, current string) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if !rec.Expire.IsZero() {
+		if ttl = time.Until(rec.Expire); ttl < time.Millisecond {
+			return s.Delete(ctx, rec.Series)
+		}
+	}
+	keys := []string{s.seriesKey(rec.Series), s.userKey(rec.Tenant, rec.ID)}
+	n, err := rotateScript.Run(ctx, s.client, keys, current, buf, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	switch n {
+	case 0:
+		return ErrNotFound
+	case -1:
+		return ErrRotated
+	}
+	return nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, series string) error {
+	rec, err := s.Get(ctx, series)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.seriesKey(series))
+		pipe.SRem(ctx, s.userKey(rec.Tenant, rec.ID), series)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) DeleteUser(ctx context.Context, tenant string, id uint64) error {
+	userKey := s.userKey(tenant, id)
+	members, err := s.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(members)+1)
+	for _, series := range members {
+		keys = append(keys, s.seriesKey(series))
+	}
+	keys = append(keys, userKey)
+	return s.client.Del(ctx, keys...).Err()
+}
+
+func (s *RedisStore) seriesKey(series string) string { return s.keyPrefix + "series:" + series }
+
+// userKey 用户索引，租户用户为 keyPrefix + "user:tenant:" + id
+func (s *RedisStore) userKey(tenant string, id uint64) string {
+	if tenant != "" {
+		return s.keyPrefix + "user:" + tenant + ":" + strconv.FormatUint(id, 10)
+	}
+	return s.keyPrefix + "user:" + strconv.FormatUint(id, 10)
+}
@@ -0,0 +1,110 @@
+package remember
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound series不存在或已过期
+	ErrNotFound = errors.New("remember: series not found")
+	// ErrRotated series已被并发请求轮换，Rotate 未写入
+	ErrRotated = errors.New("remember: series already rotated")
+)
+
+// Record 一个记住登录的series
+//
+// Hash 为当前validator的SHA-256，Previous 为上一次轮换前的值，
+// 在 RotatedAt 之后的容忍窗口内仍被接受。
+type Record struct {
+	Series    string    `json:"series"`
+	Hash      string    `json:"hash"`
+	Previous  string    `json:"previous,omitempty"`
+	RotatedAt time.Time `json:"rotated_at"`
+	Expire    time.Time `json:"expire"`
+	ID        uint64    `json:"id"`
+	Account   string    `json:"account"`
+	Roles     []string  `json:"roles,omitempty"`
+	State     uint16    `json:"state,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"`
+}
+
+// Store 记住登录的持久化存储
+type Store interface {
+	Get(ctx context.Context, series string) (*Record, error)
+	Save(ctx context.Context, rec *Record) error
+	// Rotate 仅当存储的 Hash 仍为 current 时原子地写入 rec，否则返回 ErrRotated
+	Rotate(ctx context.Context, rec *Record, current string) error
+	Delete(ctx context.Context, series string) error
+	// DeleteUser 删除租户内用户的全部series，用于修改密码、封禁等场景，单租户应用 tenant 为 ""
+	DeleteUser(ctx context.Context, tenant string, id uint64) error
+}
+
+var _ Store = (*MemStore)(nil)
+
+// MemStore 内存存储，适用于单实例与测试
+type MemStore struct {
+	mu   sync.RWMutex
+	data map[string]Record
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{data: make(map[string]Record)}
+}
+
+func (s *MemStore) Get(_ context.Context, series string) (*Record, error) {
+	s.mu.RLock()
+	rec, ok := s.data[series]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !rec.Expire.IsZero() && time.Now().After(rec.Expire) {
+		s.mu.Lock()
+		delete(s.data, series)
+		s.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (s *MemStore) Save(_ context.Context, rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[rec.Series] = *rec
+	return nil
+}
+
+func (s *MemStore) Rotate(_ context.Context, rec *Record, current string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.data[rec.Series]
+	if !ok || !stored.Expire.IsZero() && time.Now().After(stored.Expire) {
+		return ErrNotFound
+	}
+	if stored.Hash != current {
+		return ErrRotated
+	}
+	s.data[rec.Series] = *rec
+	return nil
+}
+
+func (s *MemStore) Delete(_ context.Context, series string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, series)
+	return nil
+}
+
+func (s *MemStore) DeleteUser(_ context.Context, tenant string, id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for series, rec := range s.data {
+		if rec.ID == id && rec.Tenant == tenant {
+			delete(s.data, series)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package session_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestAuditEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ch := make(chan audit.Event, 16)
+	audit.SetDefault(audit.NewLogger(audit.NewChanSink(ch)))
+	defer audit.SetDefault(nil)
+
+	router := gin.New()
+	router.Use(audit.Mw(), session.Mw("token", session.NewMemStore()))
+	router.POST("/login", func(c *gin.Context) {
+		_ = session.Default(c).Login(func(sess *session.Session) error {
+			sess.SetID(1)
+			sess.SetAccount("alice")
+			sess.SetAuthInfo(session.AuthInfo{Method: "password"})
+			return nil
+		})
+	})
+	router.GET("/me", session.AuthMW(), func(c *gin.Context) {})
+	router.GET("/admin", session.RoleMW(session.RoleAdmin), func(c *gin.Context) {})
+	router.POST("/logout", session.LogoutHandler())
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("X-Token", token)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	token := do(http.MethodPost, "/login", "").Header().Get("X-Token")
+	do(http.MethodGet, "/me", "")
+	do(http.MethodGet, "/admin", token)
+	do(http.MethodPost, "/logout", token)
+
+	want := []audit.Type{audit.SessionRotated, audit.LoginSucceeded, audit.Unauthenticated, audit.AccessDenied, audit.Logout}
+	for i, typ := range want {
+		e := <-ch
+		if e.Type != typ {
+			t.Fatalf("event %d = %s, want %s", i, e.Type, typ)
+		}
+		if e.RequestID == "" {
+			t.Fatalf("event %s missing request id", e.Type)
+		}
+		buf, _ := json.Marshal(e)
+		if strings.Contains(string(buf), token) {
+			t.Fatalf("raw token logged: %s", buf)
+		}
+		switch e.Type {
+		case audit.LoginSucceeded, audit.Logout:
+			if e.UserID != 1 || e.Account != "alice" || e.Session != audit.TokenID(token) {
+				t.Fatalf("unexpected %s event: %+v", e.Type, e)
+			}
+			if e.Type == audit.LoginSucceeded && e.Method != "password" {
+				t.Fatalf("login method: %q", e.Method)
+			}
+		case audit.AccessDenied:
+			if e.UserID != 1 || e.Reason != "insufficient_role" {
+				t.Fatalf("unexpected denial: %+v", e)
+			}
+		}
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+}
@@ -1,18 +1,36 @@
 package session
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
 	"github.com/spf13/pflag"
 
+	"github.com/mulan-ext/auth/credential"
 	"github.com/mulan-ext/rdb"
 )
 
 type Config struct {
-	Name       string     `json:"name" yaml:"name"`
-	TTL        int        `json:"ttl" yaml:"ttl"`
-	Driver     string     `json:"driver" yaml:"driver"`
-	RDB        rdb.Config `json:"rdb" yaml:"rdb"`
-	Dir        string     `json:"dir" yaml:"dir"`
-	HeaderOnly bool       `json:"header_only" yaml:"header_only"`
+	Name       string         `json:"name" yaml:"name"`
+	TTL        int            `json:"ttl" yaml:"ttl"`
+	Driver     string         `json:"driver" yaml:"driver"`
+	RDB        rdb.Config     `json:"rdb" yaml:"rdb"`
+	Dir        string         `json:"dir" yaml:"dir"`
+	Sharded    ShardedConfig  `json:"sharded" yaml:"sharded"`
+	Options    map[string]any `json:"options" yaml:"options"` // 第三方驱动配置，见 DecodeOptions
+	HeaderOnly bool           `json:"header_only" yaml:"header_only"`
+	// Sources token来源，按顺序检查，格式见 credential.Parse，为空时使用 DefaultExtractors
+	Sources []string `json:"sources" yaml:"sources"`
+	// Extractors 代码中配置的token来源，可包含 credential.Func，优先于 Sources
+	Extractors credential.Chain `json:"-" yaml:"-"`
+}
+
+// ShardedConfig 分片内存驱动配置
+type ShardedConfig struct {
+	Shards int `json:"shards" yaml:"shards"` // 分片数，必须是2的幂
 }
 
 func (c *Config) FlagSet() *pflag.FlagSet { return FlagSet() }
@@ -21,8 +39,9 @@ func FlagSet() *pflag.FlagSet {
 	fs := pflag.NewFlagSet("session", pflag.ContinueOnError)
 	fs.String("session.name", "token", "Session Token Name")
 	fs.Int("session.ttl", 0, "session ttl")
-	fs.String("session.driver", "memory", "session driver")
+	fs.String("session.driver", DriverMemory, "session driver (memory, sharded, fs, rdb or a registered driver)")
 	fs.Bool("session.header-only", true, "accept and return session tokens through headers only")
+	fs.StringSlice("session.sources", []string{}, "session token sources in order (header:<name>, bearer, scheme:<scheme>, cookie:<name>, query:<name>, form:<name>)")
 	// driver redis
 	fs.String("session.rdb.host", "127.0.0.1", "session rdb host")
 	fs.String("session.rdb.pass", "", "session rdb pass")
@@ -31,5 +50,61 @@ func FlagSet() *pflag.FlagSet {
 	fs.Bool("session.rdb.debug", false, "session rdb debug")
 	// driver fs
 	fs.String("session.dir", "", "session fs dir")
+	// driver sharded
+	fs.Int("session.sharded.shards", 16, "session sharded memory shard count (power of two)")
 	return fs
 }
+
+func (c *Config) Validate() error {
+	if c == nil {
+		return errors.New("session: config is nil")
+	}
+	if c.Name != "" {
+		if err := (&http.Cookie{Name: c.Name, Value: "token"}).Valid(); err != nil {
+			return fmt.Errorf("session: invalid name: %w", err)
+		}
+	}
+	if _, err := c.chain(); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+	if c.TTL < 0 {
+		return errors.New("session: ttl cannot be negative")
+	}
+	switch c.Driver {
+	case "", DriverMemory:
+	case DriverSharded:
+		if n := c.Sharded.Shards; n < 0 || n&(n-1) != 0 {
+			return errors.New("session: sharded.shards must be a power of two")
+		}
+	case DriverFs:
+		if strings.TrimSpace(c.Dir) == "" {
+			return errors.New("session: dir is required for the fs driver")
+		}
+	case DriverRedis:
+		if strings.TrimSpace(c.RDB.Host) == "" {
+			return errors.New("session: rdb.host is required for the rdb driver")
+		}
+		if c.RDB.Port <= 0 || c.RDB.Port > 65535 {
+			return errors.New("session: rdb.port is invalid")
+		}
+		if c.RDB.DB < 0 {
+			return errors.New("session: rdb.db cannot be negative")
+		}
+	default:
+		if !slices.Contains(Drivers(), c.Driver) {
+			return fmt.Errorf("%w %q", ErrUnknownDriver, c.Driver)
+		}
+	}
+	return nil
+}
+
+// chain 返回配置的token来源，未配置时返回 nil
+func (c *Config) chain() (credential.Chain, error) {
+	if len(c.Extractors) > 0 {
+		return c.Extractors, nil
+	}
+	if len(c.Sources) == 0 {
+		return nil, nil
+	}
+	return credential.ParseChain(c.Sources)
+}
@@ -0,0 +1,48 @@
+package session_test
+
+import (
+	"testing"
+
+	"github.com/mulan-ext/auth/session"
+	"github.com/mulan-ext/rdb"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := []*session.Config{
+		{},
+		{Driver: session.DriverMemory, TTL: 60},
+		{Driver: session.DriverSharded, Sharded: session.ShardedConfig{Shards: 32}},
+		{Driver: session.DriverFs, Dir: "/tmp/sessions"},
+		{Driver: session.DriverRedis, RDB: rdb.Config{Host: "127.0.0.1", Port: 6379}},
+	}
+	for _, cfg := range valid {
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("config %+v: unexpected error %v", cfg, err)
+		}
+	}
+
+	invalid := map[string]*session.Config{
+		"nil":              nil,
+		"negative ttl":     {TTL: -1},
+		"bad name":         {Name: "bad name"},
+		"fs without dir":   {Driver: session.DriverFs},
+		"bad shards":       {Driver: session.DriverSharded, Sharded: session.ShardedConfig{Shards: 3}},
+		"rdb without host": {Driver: session.DriverRedis, RDB: rdb.Config{Port: 6379}},
+		"rdb bad port":     {Driver: session.DriverRedis, RDB: rdb.Config{Host: "redis"}},
+		"unknown driver":   {Driver: "mysql"},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestInitValidatesConfig(t *testing.T) {
+	if _, err := session.Init(&session.Config{Driver: session.DriverFs}); err == nil {
+		t.Fatal("Init accepted fs driver without dir")
+	}
+	if _, err := session.Init(&session.Config{TTL: -1}); err == nil {
+		t.Fatal("Init accepted negative ttl")
+	}
+}
@@ -0,0 +1,99 @@
+package session_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+// serviceWhoAmI 模拟只接收 context.Context 的服务层
+func serviceWhoAmI(ctx context.Context) string {
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return "anonymous"
+	}
+	sess, ok := session.FromContext(ctx)
+	if !ok || sess.ID() != p.ID {
+		return "mismatch"
+	}
+	return p.Account + ":" + p.Method + ":" + strings.Join(p.Roles, ",")
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(session.Mw("token", session.NewMemStore()))
+	r.GET("/login", handlerLogin)
+	r.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, serviceWhoAmI(c.Request.Context()))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/whoami", nil))
+	if body := w.Body.String(); body != "anonymous" {
+		t.Fatalf("unexpected anonymous principal: %q", body)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	token := w.Body.String()
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if body := w.Body.String(); body != "test:session:admin" {
+		t.Fatalf("unexpected principal: %q", body)
+	}
+}
+
+func TestFromGinDoesNotPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if sess, ok := session.FromGin(c); ok || sess != nil {
+		t.Fatal("expected no session without middleware")
+	}
+	c.Set(session.DefaultKey, "not a session")
+	if _, ok := session.FromGin(c); ok {
+		t.Fatal("expected type mismatch to be reported")
+	}
+}
+
+func TestItemsDoNotOverrideContextKeys(t *testing.T) {
+	store := session.NewMemStore()
+	data := (&session.DefaultData{}).SetID(1).SetAccount("alice").SetRoles([]string{"user"})
+	data.SetValues(session.CtxKeyRoles, []string{session.RoleAdmin})
+	data.SetValues(session.CtxKeyIsAdmin, true)
+	data.SetValues(session.DefaultKey, "not a session")
+	data.SetValues("theme", "dark")
+	token := data.New()
+	if err := store.Save(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(session.Mw("token", store))
+	r.GET("/admin", session.RoleMW(session.RoleAdmin), func(c *gin.Context) {})
+	r.GET("/me", func(c *gin.Context) {
+		if _, ok := session.FromGin(c); !ok || c.GetBool(session.CtxKeyIsAdmin) || c.GetString("theme") != "dark" {
+			t.Errorf("session item overrode context keys: admin=%v theme=%q", c.GetBool(session.CtxKeyIsAdmin), c.GetString("theme"))
+		}
+	})
+
+	for path, want := range map[string]int{"/admin": http.StatusForbidden, "/me": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Token", token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: got %d want %d", path, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/mulan-ext/rdb"
+)
+
+// 内置驱动名称
+const (
+	DriverMemory  = "memory"
+	DriverSharded = "sharded"
+	DriverFs      = "fs"
+	DriverRedis   = "rdb"
+)
+
+// ErrUnknownDriver 未注册的驱动
+var ErrUnknownDriver = errors.New("session: unknown driver")
+
+// DriverFactory 根据配置创建Store
+type DriverFactory func(cfg *Config) (Store, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]DriverFactory)
+)
+
+func init() {
+	RegisterDriver(DriverMemory, func(cfg *Config) (Store, error) {
+		return NewMemStore(cfg.TTL), nil
+	})
+	RegisterDriver(DriverSharded, func(cfg *Config) (Store, error) {
+		return NewShardedMemStore(cfg.Sharded.Shards, cfg.TTL), nil
+	})
+	RegisterDriver(DriverFs, func(cfg *Config) (Store, error) {
+		return NewFsStore(cfg.Dir, cfg.TTL)
+	})
+	RegisterDriver(DriverRedis, func(cfg *Config) (Store, error) {
+		client, err := rdb.New(&cfg.RDB)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStore(client, cfg.TTL)
+	})
+}
+
+// RegisterDriver 注册Store驱动，名称重复或 factory 为 nil 时 panic
+func RegisterDriver(name string, factory DriverFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if factory == nil {
+		panic("session: RegisterDriver factory is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("session: RegisterDriver called twice for driver " + name)
+	}
+	drivers[name] = factory
+}
+
+// Drivers 返回已注册的驱动名称（有序）
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewStore 根据配置创建Store，未指定驱动时使用内存存储
+func NewStore(cfg *Config) (Store, error) {
+	name := cfg.Driver
+	if name == "" {
+		name = DriverMemory
+	}
+	driversMu.RLock()
+	factory, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (registered: %v)", ErrUnknownDriver, name, Drivers())
+	}
+	store, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("session: driver %s: %w", name, err)
+	}
+	return store, nil
+}
+
+// DecodeOptions 将第三方驱动的 Options 配置解码到 v
+func (c *Config) DecodeOptions(v any) error {
+	buf, err := json.Marshal(c.Options)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
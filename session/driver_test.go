@@ -0,0 +1,89 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mulan-ext/auth/session"
+)
+
+type countingStore struct {
+	*session.MemStore
+	saves int
+}
+
+func (s *countingStore) Save(ctx context.Context, v session.Data, lifetime ...time.Duration) error {
+	s.saves++
+	return s.MemStore.Save(ctx, v, lifetime...)
+}
+
+func TestNewStoreBuiltinDrivers(t *testing.T) {
+	for _, name := range []string{"", session.DriverMemory, session.DriverSharded, session.DriverFs} {
+		store, err := session.NewStore(&session.Config{
+			Driver:  name,
+			Dir:     t.TempDir(),
+			Sharded: session.ShardedConfig{Shards: 8},
+		})
+		if err != nil {
+			t.Fatalf("driver %q: %v", name, err)
+		}
+		if _, ok := store.(session.Iterable); !ok {
+			t.Errorf("driver %q store is not iterable: %T", name, store)
+		}
+	}
+	store, _ := session.NewStore(&session.Config{Driver: session.DriverSharded})
+	if _, ok := store.(*session.ShardedMemStore); !ok {
+		t.Fatalf("sharded driver returned %T", store)
+	}
+}
+
+func TestNewStoreRejectsUnknownDriver(t *testing.T) {
+	_, err := session.NewStore(&session.Config{Driver: "redis"})
+	if !errors.Is(err, session.ErrUnknownDriver) {
+		t.Fatalf("expected ErrUnknownDriver, got %v", err)
+	}
+	if _, err := session.Init(&session.Config{Driver: "redis"}); err == nil {
+		t.Fatal("Init should not fall back to memory for an unknown driver")
+	}
+}
+
+func TestRegisterDriver(t *testing.T) {
+	var created *countingStore
+	session.RegisterDriver("test-counting", func(cfg *session.Config) (session.Store, error) {
+		var opts struct {
+			MaxAge int `json:"max_age"`
+		}
+		if err := cfg.DecodeOptions(&opts); err != nil {
+			return nil, err
+		}
+		created = &countingStore{MemStore: session.NewMemStore(opts.MaxAge)}
+		return created, nil
+	})
+	if !slices.Contains(session.Drivers(), "test-counting") {
+		t.Fatalf("registered driver not listed: %v", session.Drivers())
+	}
+
+	store, err := session.NewStore(&session.Config{
+		Driver:  "test-counting",
+		Options: map[string]any{"max_age": 60},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(context.Background(), &session.DefaultData{Token_: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	if created == nil || created.saves != 1 {
+		t.Fatal("custom driver was not used")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for duplicate registration")
+		}
+	}()
+	session.RegisterDriver(session.DriverMemory, func(*session.Config) (session.Store, error) { return nil, nil })
+}
@@ -0,0 +1,199 @@
+package session
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+// 认证信息在session中的item key
+const (
+	ItemAuthTime   = "auth_time"   // 认证时间，Unix 秒
+	ItemAuthMethod = "auth_method" // 认证方式，见 principal.Method*
+	ItemACR        = "acr"         // 认证上下文等级，如 OIDC 的 acr
+	ItemAMR        = "amr"         // 认证方法引用，如 ["pwd","otp"]
+	ItemStepUp     = "step_up"     // 进行中的重新认证，见 BeginStepUp
+)
+
+// AuthInfo 最近一次认证的信息
+type AuthInfo struct {
+	Time   time.Time
+	Method string
+	ACR    string
+	AMR    []string
+}
+
+// Fresh 判断认证是否在 maxAge 内完成且 ACR 属于 acr 之一（acr 为空时不检查）
+func (i AuthInfo) Fresh(maxAge time.Duration, acr ...string) bool {
+	if i.Time.IsZero() || time.Since(i.Time) > maxAge {
+		return false
+	}
+	return len(acr) == 0 || slices.Contains(acr, i.ACR)
+}
+
+// SetAuthInfo 记录认证信息，Time 为零值时使用当前时间
+//
+// 第三方登录回调会自动记录；表单登录等自定义流程应在 Login 的 apply 中调用。
+func (s *Session) SetAuthInfo(info AuthInfo) {
+	if info.Time.IsZero() {
+		info.Time = time.Now()
+	}
+	data := s.Data()
+	data.SetValues(ItemAuthTime, info.Time.Unix())
+	data.SetValues(ItemAuthMethod, info.Method)
+	if info.ACR != "" {
+		data.SetValues(ItemACR, info.ACR)
+	} else {
+		data.Delete(ItemACR)
+	}
+	if len(info.AMR) > 0 {
+		data.SetValues(ItemAMR, slices.Clone(info.AMR))
+	} else {
+		data.Delete(ItemAMR)
+	}
+}
+
+// AuthInfo 返回最近一次认证的信息，未记录时 Time 为零值
+func (s *Session) AuthInfo() AuthInfo {
+	return authInfo(s.Data())
+}
+
+func authInfo(data Data) AuthInfo {
+	var info AuthInfo
+	if sec, err := decodeItem[int64](data.Get(ItemAuthTime)); err == nil && sec > 0 {
+		info.Time = time.Unix(sec, 0)
+	}
+	info.Method, _ = data.Get(ItemAuthMethod).(string)
+	info.ACR, _ = data.Get(ItemACR).(string)
+	if raw := data.Get(ItemAMR); raw != nil {
+		info.AMR, _ = decodeItem[[]string](raw)
+	}
+	return info
+}
+
+// StepUp 需要重新认证时的要求
+type StepUp struct {
+	MaxAge time.Duration
+	ACR    []string
+}
+
+// StepUpFunc 处理需要重新认证的请求，如跳转到 OIDC 重新登录
+type StepUpFunc func(w http.ResponseWriter, r *http.Request, req StepUp)
+
+// stepUpState 保存在 ItemStepUp 中的重新认证状态
+type stepUpState struct {
+	MaxAge int64    `json:"max_age"`
+	ACR    []string `json:"acr,omitempty"`
+	Failed bool     `json:"failed,omitempty"`
+}
+
+// BeginStepUp 记录并保存进行中的重新认证要求，StepUpFunc 跳转前调用，登录回调通过 PendingStepUp 取回校验
+func (s *Session) BeginStepUp(req StepUp) error {
+	s.SetValues(ItemStepUp, stepUpState{MaxAge: int64(req.MaxAge / time.Second), ACR: slices.Clone(req.ACR)})
+	return s.Save()
+}
+
+// PendingStepUp 返回 BeginStepUp 记录的要求，应在 Login 之前读取
+func (s *Session) PendingStepUp() (StepUp, bool) {
+	st, ok := stepUpOf(s.Data())
+	if !ok || st.Failed {
+		return StepUp{}, false
+	}
+	return StepUp{MaxAge: time.Duration(st.MaxAge) * time.Second, ACR: st.ACR}, true
+}
+
+// FailStepUp 标记重新认证未满足要求，如 Provider 忽略了 max_age 或 acr_values，应在 Login 的 apply 中调用
+//
+// 下一次认证时效检查不再调用 StepUpFunc，直接返回 WriteStepUpError 并清除标记，避免循环跳转。
+func (s *Session) FailStepUp() {
+	s.SetValues(ItemStepUp, stepUpState{Failed: true})
+}
+
+func stepUpOf(data Data) (stepUpState, bool) {
+	raw := data.Get(ItemStepUp)
+	if raw == nil {
+		return stepUpState{}, false
+	}
+	st, err := decodeItem[stepUpState](raw)
+	return st, err == nil
+}
+
+// WriteStepUpError 默认的 StepUpFunc，按 RFC 9470 返回 401 与 insufficient_user_authentication 质询
+//
+//	{"error":"step_up_required","max_age":300,"acr_values":["mfa"],...}
+func WriteStepUpError(w http.ResponseWriter, r *http.Request, req StepUp) {
+	maxAge := int64(req.MaxAge / time.Second)
+	params := []string{"error", "insufficient_user_authentication", "max_age", strconv.FormatInt(maxAge, 10)}
+	p := problem.New(http.StatusUnauthorized, "step_up_required").With("max_age", maxAge)
+	if len(req.ACR) > 0 {
+		params = append(params, "acr_values", strings.Join(req.ACR, " "))
+		p.With("acr_values", req.ACR)
+	}
+	problem.Write(w, r, p.WithChallenge(problem.Bearer(params...)))
+}
+
+// RequireFreshAuth 要求用户在 maxAge 内完成认证且 ACR 属于 acr 之一，否则返回 WriteStepUpError
+func RequireFreshAuth(maxAge time.Duration, acr ...string) gin.HandlerFunc {
+	return RequireFreshAuthFunc(nil, maxAge, acr...)
+}
+
+// RequireFreshAuthFunc 同 RequireFreshAuth，不满足时交由 stepUp 处理，如 oidc.Authenticator.StepUp
+func RequireFreshAuthFunc(stepUp StepUpFunc, maxAge time.Duration, acr ...string) gin.HandlerFunc {
+	check := newFreshCheck(stepUp, maxAge, acr)
+	return func(c *gin.Context) {
+		sess, _ := FromGin(c)
+		if !check(c.Writer, c.Request, sess) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireFreshAuthMiddleware net/http 版本的 RequireFreshAuthFunc，stepUp 可为 nil
+func RequireFreshAuthMiddleware(stepUp StepUpFunc, maxAge time.Duration, acr ...string) func(http.Handler) http.Handler {
+	check := newFreshCheck(stepUp, maxAge, acr)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess, _ := FromContext(r.Context())
+			if check(w, r, sess) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// newFreshCheck 返回认证时效检查，不满足时写出响应并返回 false
+func newFreshCheck(stepUp StepUpFunc, maxAge time.Duration, acr []string) func(http.ResponseWriter, *http.Request, *Session) bool {
+	if stepUp == nil {
+		stepUp = WriteStepUpError
+	}
+	req := StepUp{MaxAge: maxAge, ACR: slices.Clone(acr)}
+	return func(w http.ResponseWriter, r *http.Request, sess *Session) bool {
+		if _, ok := principal.FromContext(r.Context()); !ok {
+			problem.Write(w, r, problem.Unauthenticated(r))
+			return false
+		}
+		// 非session认证（如 API Key）没有认证时间，视为不满足
+		if sess != nil && !sess.IsNil && sess.AuthInfo().Fresh(maxAge, acr...) {
+			return true
+		}
+		// 上一次重新认证未满足要求，不再跳转
+		if sess != nil && !sess.IsNil {
+			if st, ok := stepUpOf(sess.Data()); ok && st.Failed {
+				_ = sess.Delete(ItemStepUp)
+				WriteStepUpError(w, r, req)
+				return false
+			}
+		}
+		stepUp(w, r, req)
+		return false
+	}
+}
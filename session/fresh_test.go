@@ -0,0 +1,87 @@
+package session_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestAuthInfoRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := session.NewFsStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := session.NewSession(ctx, store, &session.DefaultData{})
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	sess.SetAuthInfo(session.AuthInfo{Time: authTime, Method: "password", ACR: "mfa", AMR: []string{"pwd", "otp"}})
+	if err := sess.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := session.NewSession(ctx, store, (&session.DefaultData{}).SetToken(sess.Token()))
+	info := loaded.AuthInfo()
+	if !info.Time.Equal(authTime) || info.Method != "password" || info.ACR != "mfa" || len(info.AMR) != 2 {
+		t.Fatalf("unexpected auth info: %+v", info)
+	}
+	if !info.Fresh(2*time.Minute, "mfa") || info.Fresh(30*time.Second) || info.Fresh(2*time.Minute, "hardware") {
+		t.Fatalf("freshness check mismatch: %+v", info)
+	}
+	if (session.AuthInfo{}).Fresh(time.Hour) {
+		t.Fatal("missing auth time must not be fresh")
+	}
+}
+
+func TestRequireFreshAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := session.NewMemStore()
+	router := gin.New()
+	router.Use(session.Mw("token", store))
+	router.POST("/login", func(c *gin.Context) {
+		err := session.Default(c).Login(func(sess *session.Session) error {
+			sess.SetID(1)
+			sess.SetAuthInfo(session.AuthInfo{Method: "password"})
+			return nil
+		})
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.DELETE("/account", session.RequireFreshAuth(5*time.Minute), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+	token := w.Header().Get("X-Token")
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/account", nil)
+	req.Header.Set("X-Token", token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("fresh login rejected: %d %s", w.Code, w.Body.String())
+	}
+
+	// 认证时间超过 maxAge
+	data, _ := store.Get(context.Background(), token)
+	data.SetValues(session.ItemAuthTime, time.Now().Add(-time.Hour).Unix())
+	_ = store.Save(context.Background(), data)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodDelete, "/account", nil)
+	req.Header.Set("X-Token", token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"error":"step_up_required","max_age":300`) {
+		t.Fatalf("stale login: %d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != `Bearer error="insufficient_user_authentication", max_age="300"` {
+		t.Fatalf("step-up challenge: %s", got)
+	}
+}
@@ -1,10 +1,13 @@
 package session
 
 import (
+	"net/http"
+	"net/url"
 	"slices"
-	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/principal"
 )
 
 // HasRole 检查用户是否拥有指定角色
@@ -30,41 +33,91 @@ func HasRoles(c *gin.Context, roles ...string) bool {
 	return true
 }
 
-// populateContext 将session数据填充到gin.Context
-func populateContext(c *gin.Context, data Data) {
-	roles := data.Roles()
-	c.Set(CtxKeyID, data.ID())
-	c.Set(CtxKeyAccount, data.Account())
-	c.Set(CtxKeyState, data.State())
-	c.Set(CtxKeyRoles, roles)
-	c.Set(CtxKeyIsAdmin, slices.Contains(roles, RoleAdmin))
-	for k, v := range data.Items() {
-		c.Set(k, v)
+// Populate 将session数据填充到gin.Context，并将 Principal 写入请求的 context
+//
+// method 为本次请求的认证方式，见 principal.Method* 常量。
+func Populate(c *gin.Context, data Data, method string) {
+	c.Request = WithPrincipal(c.Request, data, method)
+	setKeys(c, data)
+}
+
+// PopulatePrincipal 将非session认证（如 API Key）的主体填充到gin.Context，使 AuthMW、RoleMW 等同样生效
+//
+// p.Roles 应为当前租户内生效的角色。
+func PopulatePrincipal(c *gin.Context, p *principal.Principal) {
+	c.Request = c.Request.WithContext(principal.NewContext(c.Request.Context(), p))
+	c.Set(CtxKeyID, p.ID)
+	c.Set(CtxKeyAccount, p.Account)
+	c.Set(CtxKeyState, p.State)
+	c.Set(CtxKeyRoles, p.Roles)
+	c.Set(CtxKeyIsAdmin, slices.Contains(p.Roles, RoleAdmin))
+	c.Set(CtxKeyImpersonated, p.Impersonated())
+	if p.Scopes != nil {
+		c.Set(CtxKeyScopes, p.Scopes)
 	}
 }
 
-// extractToken 从请求中提取token（按优先级）
-func extractToken(c *gin.Context, name string, allowCookie bool) string {
-	// 1. 尝试从自定义Header获取
-	headerKeys := []string{"X-Token", "X-Api-Key", name, "X-" + name}
-	for _, key := range headerKeys {
-		if token := strings.TrimSpace(c.GetHeader(key)); token != "" {
-			return token
-		}
+// WithPrincipal 返回携带 Principal 的请求，用于 net/http 中间件，gin 中使用 Populate
+func WithPrincipal(r *http.Request, data Data, method string) *http.Request {
+	tenant := tenantOf(data)
+	p := &principal.Principal{
+		ID:      data.ID(),
+		Account: data.Account(),
+		Roles:   RolesFor(data.Roles(), tenant),
+		State:   data.State(),
+		Tenant:  tenant,
+		Method:  method,
 	}
-	// 2. 尝试从Authorization Header获取
-	if auth := c.GetHeader("Authorization"); auth != "" {
-		if token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")); token != "" {
-			return token
+	if actor, ok := impersonator(data); ok {
+		p.Actor = &principal.Principal{
+			ID:      actor.ID,
+			Account: actor.Account,
+			Roles:   RolesFor(actor.Roles, tenant),
+			State:   actor.State,
+			Tenant:  tenant,
+			Method:  method,
 		}
 	}
-	if allowCookie {
-		// 3. 尝试从Cookie获取
-		if token, err := c.Cookie(name); err == nil {
-			if token = strings.TrimSpace(token); token != "" {
-				return token
-			}
+	return r.WithContext(principal.NewContext(r.Context(), p))
+}
+
+// setKeys 将session数据写入gin.Context，CtxKeyRoles 为当前租户内生效的角色
+//
+// 与中间件写入的key同名的自定义数据不写入，避免覆盖 CtxKeyID、CtxKeyRoles 等认证结果。
+func setKeys(c *gin.Context, data Data) {
+	for k, v := range data.Items() {
+		if !reservedKey(k) {
+			c.Set(k, v)
 		}
 	}
-	return ""
+	roles := RolesFor(data.Roles(), tenantOf(data))
+	c.Set(CtxKeyID, data.ID())
+	c.Set(CtxKeyAccount, data.Account())
+	c.Set(CtxKeyState, data.State())
+	c.Set(CtxKeyRoles, roles)
+	c.Set(CtxKeyIsAdmin, slices.Contains(roles, RoleAdmin))
+	_, impersonated := impersonator(data)
+	c.Set(CtxKeyImpersonated, impersonated)
+}
+
+// reservedKey 由中间件写入gin.Context的key
+func reservedKey(k string) bool {
+	switch k {
+	case DefaultKey, TokenKey, CtxKeyID, CtxKeyAccount, CtxKeyState, CtxKeyRoles, CtxKeyIsAdmin,
+		CtxKeyImpersonated, CtxKeyScopes, CtxKeyTenant:
+		return true
+	}
+	return false
+}
+
+// setCookie 写入session Cookie，maxAge < 0 表示立即过期
+func setCookie(w http.ResponseWriter, name, value string, maxAge int, secure, httpOnly bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		MaxAge:   maxAge,
+		Path:     "/",
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	})
 }
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+// InitMiddleware net/http 版本的 Init
+func InitMiddleware(cfg *Config) (func(http.Handler) http.Handler, error) {
+	m, err := initMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return m.handler, nil
+}
+
+// Middleware net/http 版本的 Mw
+//
+// Session 与 Principal 写入请求的 context，可通过 FromContext / principal.FromContext 获取。
+func Middleware(name string, store Store, data ...Data) func(http.Handler) http.Handler {
+	return newMiddleware(name, store, false, data...).handler
+}
+
+func (m *middleware) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, r := m.begin(r)
+		sess.onDestroy = func() { expireCookie(w, sess) }
+		// net/http 在写出响应后无法修改Header，需在首次写出前下发token
+		rw := &responseWriter{ResponseWriter: w, before: func() { m.emit(w, sess) }}
+		next.ServeHTTP(rw, r)
+		rw.flushHeader()
+	})
+}
+
+// AuthMiddleware net/http 版本的 AuthMW
+func AuthMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := principal.FromContext(r.Context()); !ok {
+				emitUnauthenticated(r)
+				problem.Write(w, r, problem.Unauthenticated(r))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RoleMiddleware net/http 版本的 RoleMW
+func RoleMiddleware(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := principal.FromContext(r.Context()); ok && slices.ContainsFunc(roles, p.HasRole) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			emitRoleDenied(r, roles)
+			problem.Write(w, r, problem.New(http.StatusForbidden, "insufficient_role"))
+		})
+	}
+}
+
+// responseWriter 在首次写出响应前执行 before
+type responseWriter struct {
+	http.ResponseWriter
+	before  func()
+	written bool
+}
+
+func (w *responseWriter) flushHeader() {
+	if !w.written {
+		w.written = true
+		w.before()
+	}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.flushHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.flushHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	w.flushHeader()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
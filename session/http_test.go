@@ -0,0 +1,93 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+func newHTTPMux(t *testing.T) http.Handler {
+	t.Helper()
+	mw, err := session.InitMiddleware(&session.Config{Name: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		sess, _ := session.FromContext(r.Context())
+		sess.SetID(9)
+		sess.SetAccount("http-user")
+		sess.SetRoles([]string{"editor"})
+		if err := sess.Save(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(sess.Token()))
+	})
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := principal.FromContext(r.Context())
+		_, _ = w.Write([]byte(p.Account))
+	})
+	mux.Handle("/me", session.AuthMiddleware()(whoami))
+	mux.Handle("/admin", session.AuthMiddleware()(session.RoleMiddleware("admin")(whoami)))
+	mux.Handle("/logout", session.LogoutHTTP())
+	return mw(mux)
+}
+
+func TestHTTPMiddlewareSessionFlow(t *testing.T) {
+	handler := newHTTPMux(t)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	token := w.Body.String()
+	// 响应体写出前即应下发token
+	if got := w.Result().Header.Get("X-Token"); got != token {
+		t.Fatalf("X-Token not sent before body: got %q want %q", got, token)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous request: got %d want 401", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "http-user" {
+		t.Fatalf("authenticated request: %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("role check: got %d want 403", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	result := w.Result()
+	if w.Code != http.StatusNoContent || result.Header.Get("X-Token") != "" {
+		t.Fatalf("logout: %d token=%q", w.Code, result.Header.Get("X-Token"))
+	}
+	if cookie := result.Header.Get("Set-Cookie"); !strings.Contains(cookie, "Max-Age=0") {
+		t.Fatalf("logout did not expire cookie: %q", cookie)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("token still valid after logout: %d", w.Code)
+	}
+}
@@ -0,0 +1,183 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+const (
+	// ItemImpersonator session中保存真实操作者的item key
+	ItemImpersonator = "impersonator"
+	// CtxKeyImpersonated gin.Context 中标记当前为代为操作，可用于展示提示横幅
+	CtxKeyImpersonated = "is_impersonated"
+)
+
+var (
+	// ErrImpersonationForbidden 当前用户不能发起代为操作
+	ErrImpersonationForbidden = errors.New("session: impersonation forbidden")
+	// ErrAlreadyImpersonating 已处于代为操作中，不支持嵌套
+	ErrAlreadyImpersonating = errors.New("session: already impersonating")
+	// ErrNotImpersonating 当前未处于代为操作
+	ErrNotImpersonating = errors.New("session: not impersonating")
+)
+
+// Actor 代为操作时的真实操作者
+type Actor struct {
+	ID      uint64   `json:"id"`
+	Account string   `json:"account"`
+	Roles   []string `json:"roles"`
+	State   uint16   `json:"state"`
+	// Items 发起时操作者session的全部item，含认证时间与方式，StopImpersonation 时原样恢复
+	Items map[string]any `json:"items,omitempty"`
+}
+
+// UserLoader 将用户 id 的信息写入session，用于代为操作；返回 ErrImpersonationForbidden 可拒绝代为操作该用户
+type UserLoader func(ctx context.Context, id uint64, sess *Session) error
+
+// Impersonate 以管理员身份代为操作用户 id
+//
+// 仅拥有 RoleAdmin 的用户可以发起，目标用户在当前租户内同样拥有 RoleAdmin 时拒绝，其他特权用户可由 load 拒绝。
+// session 会被轮换，load 写入目标用户信息，原操作者的完整数据保存在 ItemImpersonator 中，StopImpersonation 时恢复。
+func (s *Session) Impersonate(id uint64, load UserLoader) error {
+	if load == nil {
+		return errors.New("session: impersonation loader is nil")
+	}
+	if _, ok := s.Impersonator(); ok {
+		return ErrAlreadyImpersonating
+	}
+	data := s.Data()
+	if s.IsNil || !s.HasRole(RoleAdmin) || id == 0 || id == data.ID() {
+		return ErrImpersonationForbidden
+	}
+	actor := Actor{ID: data.ID(), Account: data.Account(), Roles: data.Roles(), State: data.State(), Items: data.Items()}
+	// 先加载到临时session，加载失败时保留管理员原session
+	target := &Session{ctx: s.ctx, store: s.store, data: &DefaultData{}, loaded: true, IsNil: true}
+	if err := load(s.ctx, id, target); err != nil {
+		return err
+	}
+	if slices.Contains(RolesFor(target.Data().Roles(), s.Tenant()), RoleAdmin) {
+		return ErrImpersonationForbidden
+	}
+	err := s.rotate(func(sess *Session) error {
+		loaded := target.Data()
+		sess.SetID(loaded.ID())
+		sess.SetAccount(loaded.Account())
+		sess.SetRoles(loaded.Roles())
+		sess.SetState(loaded.State())
+		for k, v := range loaded.Items() {
+			sess.SetValues(k, v)
+		}
+		sess.SetValues(ItemImpersonator, actor)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	zap.L().Info("impersonation started",
+		zap.Uint64("actor_id", actor.ID),
+		zap.String("actor_account", actor.Account),
+		zap.Uint64("target_id", s.ID()),
+		zap.String("target_account", s.Account()))
+	s.emitImpersonation(audit.ImpersonateStart, &actor, s.ID(), s.Account())
+	return nil
+}
+
+// StopImpersonation 结束代为操作并恢复原操作者的session
+func (s *Session) StopImpersonation() error {
+	actor, ok := s.Impersonator()
+	if !ok {
+		return ErrNotImpersonating
+	}
+	target, account := s.ID(), s.Account()
+	err := s.rotate(func(sess *Session) error {
+		sess.SetID(actor.ID)
+		sess.SetAccount(actor.Account)
+		sess.SetRoles(actor.Roles)
+		sess.SetState(actor.State)
+		for k, v := range actor.Items {
+			sess.SetValues(k, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	zap.L().Info("impersonation stopped",
+		zap.Uint64("actor_id", actor.ID),
+		zap.String("actor_account", actor.Account),
+		zap.Uint64("target_id", target),
+		zap.String("target_account", account))
+	s.emitImpersonation(audit.ImpersonateStop, actor, target, account)
+	return nil
+}
+
+// emitImpersonation 记录代为操作审计事件，主体为真实操作者
+func (s *Session) emitImpersonation(typ audit.Type, actor *Actor, target uint64, account string) {
+	audit.Emit(s.ctx, audit.Event{
+		Type:    typ,
+		UserID:  actor.ID,
+		Account: actor.Account,
+		Tenant:  s.Tenant(),
+		Session: audit.TokenID(s.Token()),
+		Fields:  map[string]any{"target_id": target, "target_account": account},
+	})
+}
+
+// Impersonator 返回代为操作时的真实操作者
+func (s *Session) Impersonator() (*Actor, bool) {
+	return impersonator(s.Data())
+}
+
+func impersonator(data Data) (*Actor, bool) {
+	raw := data.Get(ItemImpersonator)
+	if raw == nil {
+		return nil, false
+	}
+	actor, err := decodeItem[Actor](raw)
+	if err != nil || actor.ID == 0 {
+		return nil, false
+	}
+	return &actor, true
+}
+
+// IsImpersonated 当前请求是否为代为操作
+func IsImpersonated(c *gin.Context) bool {
+	return c.GetBool(CtxKeyImpersonated)
+}
+
+// NoImpersonationMW 拒绝代为操作的请求，用于修改密码、支付等敏感路由
+func NoImpersonationMW() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p, ok := principal.FromContext(c.Request.Context()); ok && p.Impersonated() {
+			problem.Abort(c, impersonationForbidden())
+			return
+		}
+		c.Next()
+	}
+}
+
+// NoImpersonationMiddleware net/http 版本的 NoImpersonationMW
+func NoImpersonationMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := principal.FromContext(r.Context()); ok && p.Impersonated() {
+				problem.Write(w, r, impersonationForbidden())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func impersonationForbidden() *problem.Problem {
+	return problem.New(http.StatusForbidden, "impersonation_forbidden")
+}
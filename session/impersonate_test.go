@@ -0,0 +1,138 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+func loadUser(_ context.Context, id uint64, sess *session.Session) error {
+	if id == 404 {
+		return errors.New("user not found")
+	}
+	if id == 99 {
+		sess.SetID(id)
+		sess.SetRoles([]string{session.RoleAdmin})
+		return nil
+	}
+	sess.SetID(id)
+	sess.SetAccount("user-" + strconv.FormatUint(id, 10))
+	sess.SetRoles([]string{"member"})
+	return nil
+}
+
+func TestImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := session.NewMemStore()
+	router := gin.New()
+	router.Use(session.Mw("token", store))
+	router.POST("/impersonate/:id", session.RoleMW(session.RoleAdmin), func(c *gin.Context) {
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err := session.Default(c).Impersonate(id, loadUser); err != nil {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/impersonate/stop", func(c *gin.Context) {
+		if err := session.Default(c).StopImpersonation(); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/me", session.AuthMW(), func(c *gin.Context) {
+		p, _ := principal.FromContext(c.Request.Context())
+		actor := ""
+		if p.Impersonated() {
+			actor = p.Actor.Account
+		}
+		c.JSON(http.StatusOK, gin.H{"account": p.Account, "actor": actor, "banner": session.IsImpersonated(c)})
+	})
+	router.POST("/password", session.NoImpersonationMW(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	admin := (&session.DefaultData{}).SetID(1).SetAccount("root").SetRoles([]string{session.RoleAdmin})
+	admin.SetValues("locale", "zh-CN")
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	admin.SetValues(session.ItemAuthTime, authTime.Unix()).SetValues(session.ItemAuthMethod, "oidc")
+	token := admin.New()
+	if err := store.Save(context.Background(), admin); err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Token", token)
+		router.ServeHTTP(w, req)
+		if next := w.Header().Get("X-Token"); next != "" {
+			token = next
+		}
+		return w
+	}
+
+	if w := do(http.MethodPost, "/impersonate/404"); w.Code != http.StatusForbidden {
+		t.Fatalf("loader error should abort: %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/impersonate/99"); w.Code != http.StatusForbidden {
+		t.Fatalf("impersonating another admin: %d", w.Code)
+	}
+	adminToken := token
+	if w := do(http.MethodPost, "/impersonate/42"); w.Code != http.StatusNoContent {
+		t.Fatalf("start impersonation: %d %s", w.Code, w.Body.String())
+	}
+	if token == adminToken {
+		t.Fatal("impersonation did not rotate the session")
+	}
+	if w := do(http.MethodGet, "/me"); w.Body.String() != `{"account":"user-42","actor":"root","banner":true}` {
+		t.Fatalf("impersonated principal: %s", w.Body.String())
+	}
+	if w := do(http.MethodPost, "/password"); w.Code != http.StatusForbidden {
+		t.Fatalf("sensitive route allowed while impersonating: %d", w.Code)
+	}
+	// 被代为操作的用户不是管理员，无法嵌套发起
+	if w := do(http.MethodPost, "/impersonate/43"); w.Code != http.StatusForbidden {
+		t.Fatalf("nested impersonation: %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/impersonate/stop"); w.Code != http.StatusNoContent {
+		t.Fatalf("stop impersonation: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/me"); w.Body.String() != `{"account":"root","actor":"","banner":false}` {
+		t.Fatalf("restored principal: %s", w.Body.String())
+	}
+	restored := session.NewSession(context.Background(), store, (&session.DefaultData{}).SetToken(token))
+	if restored.Get("locale") != "zh-CN" {
+		t.Fatalf("admin items lost: %v", restored.Data().Items())
+	}
+	if info := restored.AuthInfo(); !info.Time.Equal(authTime) || info.Method != "oidc" {
+		t.Fatalf("admin auth info lost: %+v", info)
+	}
+	if w := do(http.MethodPost, "/impersonate/stop"); w.Code != http.StatusBadRequest {
+		t.Fatalf("stop without impersonation: %d", w.Code)
+	}
+}
+
+func TestImpersonateRequiresAdmin(t *testing.T) {
+	store := session.NewMemStore()
+	support := (&session.DefaultData{}).SetID(5).SetRoles([]string{"support"})
+	support.New()
+	if err := store.Save(context.Background(), support); err != nil {
+		t.Fatal(err)
+	}
+	sess := session.NewSession(context.Background(), store, (&session.DefaultData{}).SetToken(support.Token()))
+	if err := sess.Impersonate(42, loadUser); !errors.Is(err, session.ErrImpersonationForbidden) {
+		t.Fatalf("non-admin impersonation: %v", err)
+	}
+	anonymous := session.NewSession(context.Background(), store, &session.DefaultData{})
+	if err := anonymous.Impersonate(42, loadUser); !errors.Is(err, session.ErrImpersonationForbidden) {
+		t.Fatalf("anonymous impersonation: %v", err)
+	}
+}
@@ -1,11 +1,15 @@
 package session
 
 import (
+	"context"
+	"net/http"
 	"regexp"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 
-	"github.com/mulan-ext/rdb"
+	"github.com/mulan-ext/auth/credential"
+	"github.com/mulan-ext/auth/principal"
 )
 
 const (
@@ -22,91 +26,169 @@ const (
 
 var tokenValid = regexp.MustCompile(`^[a-f0-9]{40}$`)
 
-// Default 获取当前请求的Session
+// Default 获取当前请求的Session，未初始化时 panic
 func Default(c *gin.Context) *Session {
-	if value, exists := c.Get(DefaultKey); exists {
-		if sess, ok := value.(*Session); ok {
-			return sess
-		}
+	if sess, ok := FromGin(c); ok {
+		return sess
 	}
 	panic("Session does not init or type mismatch")
 }
 
+// FromGin 获取当前请求的Session
+func FromGin(c *gin.Context) (*Session, bool) {
+	if value, exists := c.Get(DefaultKey); exists {
+		sess, ok := value.(*Session)
+		return sess, ok
+	}
+	return nil, false
+}
+
+type ctxKey struct{}
+
+// NewContext 返回携带Session的 context
+func NewContext(ctx context.Context, sess *Session) context.Context {
+	return context.WithValue(ctx, ctxKey{}, sess)
+}
+
+// FromContext 获取 context 中的Session，适用于只接收 context.Context 的服务层
+func FromContext(ctx context.Context) (*Session, bool) {
+	sess, ok := ctx.Value(ctxKey{}).(*Session)
+	return sess, ok && sess != nil
+}
+
 // Init 初始化Session中间件
 func Init(cfg *Config) (gin.HandlerFunc, error) {
-	var mw gin.HandlerFunc
+	m, err := initMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return m.gin(), nil
+}
+
+func Mw(name string, store Store, data ...Data) gin.HandlerFunc {
+	return newMiddleware(name, store, false, data...).gin()
+}
 
+func initMiddleware(cfg *Config) (*middleware, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	name := cfg.Name
 	if name == "" {
 		name = "token"
 	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m := newMiddleware(name, store, cfg.HeaderOnly)
+	if chain, _ := cfg.chain(); len(chain) > 0 {
+		m.chain = chain
+	}
+	return m, nil
+}
 
-	// 初始化 Auth Token 中间件
-	switch cfg.Driver {
-	// 使用 Redis 作为存储
-	case "rdb":
-		// 初始化连接 Redis
-		client, err := rdb.New(&cfg.RDB)
-		if err != nil {
-			return nil, err
-		}
-		store, err := NewRedisStore(client, cfg.TTL)
-		if err != nil {
-			return nil, err
-		}
-		mw = newMiddleware(name, store, cfg.HeaderOnly)
-	// 使用文件系统作为存储
-	case "fs":
-		store, err := NewFsStore(cfg.Dir, cfg.TTL)
-		if err != nil {
-			return nil, err
-		}
-		mw = newMiddleware(name, store, cfg.HeaderOnly)
-	// 默认使用内存存储
-	default:
-		store := NewMemStore(cfg.TTL)
-		mw = newMiddleware(name, store, cfg.HeaderOnly)
+// middleware Session中间件的框架无关实现，gin 与 net/http 版本均基于它
+type middleware struct {
+	name       string
+	store      Store
+	headerOnly bool
+	chain      credential.Chain
+	data       []Data
+}
+
+func newMiddleware(name string, store Store, headerOnly bool, data ...Data) *middleware {
+	return &middleware{name: name, store: store, headerOnly: headerOnly, chain: DefaultExtractors(name, headerOnly), data: data}
+}
+
+// DefaultExtractors 默认的token来源：X-Token、name、X-name 请求头，Bearer，非 headerOnly 时为 Cookie name
+func DefaultExtractors(name string, headerOnly bool) credential.Chain {
+	chain := credential.Chain{
+		credential.Header("X-Token"),
+		credential.Header(name),
+		credential.Header("X-" + name),
+		credential.Bearer(),
+	}
+	if !headerOnly {
+		chain = append(chain, credential.Cookie(name))
 	}
-	return mw, nil
+	return chain
 }
 
-func Mw(name string, store Store, data ...Data) gin.HandlerFunc {
-	return newMiddleware(name, store, false, data...)
+// extractToken 提取session token，忽略格式不符的值（如 API Key）；多个来源的token不一致时视为未携带
+func (m *middleware) extractToken(r *http.Request) string {
+	token, _, err := m.chain.ExtractIf(r, tokenValid.MatchString)
+	if err != nil {
+		zap.L().Warn("session: ignoring conflicting tokens", zap.Error(err))
+		return ""
+	}
+	return token
 }
 
-func newMiddleware(name string, store Store, headerOnly bool, data ...Data) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 提取token
-		token := extractToken(c, name, !headerOnly)
-		if token != "" && !tokenValid.MatchString(token) {
-			token = ""
-		}
-		// 创建或获取Data实例
-		var _data Data
-		if len(data) > 0 {
-			_data = data[0]
-			_data.Clear().SetToken(token)
-		} else {
-			_data = &DefaultData{Token_: token}
+// begin 加载当前请求的Session，返回携带Session与Principal的请求
+func (m *middleware) begin(r *http.Request) (*Session, *http.Request) {
+	token := m.extractToken(r)
+	// 创建或获取Data实例
+	var _data Data
+	if len(m.data) > 0 {
+		_data = m.data[0]
+		_data.Clear().SetToken(token)
+	} else {
+		_data = &DefaultData{Token_: token}
+	}
+	// 创建Session
+	sess := NewSession(r.Context(), m.store, _data)
+	sess.name, sess.headerOnly = m.name, m.headerOnly
+	// 其他租户的session视为未登录，不复用其token
+	if tenant := TenantFromContext(r.Context()); tenant != "" && !sess.IsNil && tenantOf(sess.Data()) != tenant {
+		zap.L().Warn("session tenant mismatch",
+			zap.String("tenant", tenant),
+			zap.String("session_tenant", tenantOf(sess.Data())))
+		sess = NewSession(r.Context(), m.store, _data.Clear())
+		sess.name, sess.headerOnly = m.name, m.headerOnly
+	}
+	r = r.WithContext(NewContext(r.Context(), sess))
+
+	// 如果Session有效，设置 Principal 到 context
+	if !sess.IsNil {
+		r = WithPrincipal(r, sess.Data(), principal.MethodSession)
+	}
+	return sess, r
+}
+
+// emit 下发token到 Header 和 Cookie，已销毁的session只过期Cookie
+func (m *middleware) emit(w http.ResponseWriter, sess *Session) {
+	if sess.Destroyed() {
+		expireCookie(w, sess)
+		return
+	}
+	if t := sess.Token(); t != "" {
+		w.Header().Set("X-Token", t)
+		if !m.headerOnly {
+			setCookie(w, m.name, t, sess.maxAge, sess.secure, sess.httpOnly)
 		}
-		// 创建Session
-		sess := NewSession(c, store, _data)
+	}
+}
+
+func (m *middleware) gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess, r := m.begin(c.Request)
+		sess.onDestroy = func() { expireCookie(c.Writer, sess) }
+		c.Request = r
 		c.Set(DefaultKey, sess)
-		c.Set(TokenKey, token)
+		c.Set(TokenKey, sess.Token())
 
 		// 如果Session有效，设置用户信息到Context
 		if !sess.IsNil {
-			populateContext(c, sess.Data())
+			setKeys(c, sess.Data())
 		}
 
 		c.Next()
 
-		// 请求处理完后，如果 token 存在（可能是新生成的），设置到 Header 和 Cookie
-		if t := sess.Token(); t != "" {
-			c.Header("X-Token", t)
-			if !headerOnly {
-				c.SetCookie(name, t, sess.maxAge, "/", "", sess.secure, sess.httpOnly)
-			}
+		// 请求处理完后，如果 token 存在（可能是新生成的），设置到 Header 和 Cookie；已销毁的session已在 Destroy 时过期Cookie
+		if sess.Destroyed() {
+			return
 		}
+		m.emit(c.Writer, sess)
 	}
 }
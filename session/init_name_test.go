@@ -133,3 +133,71 @@ func TestInitHeaderOnlyDisablesCookieTransport(t *testing.T) {
 		t.Fatalf("bearer token was rejected: %d", response.Code)
 	}
 }
+
+func TestInitTokenSources(t *testing.T) {
+	router := buildInitNameRouter(t, &session.Config{Driver: "memory", Sources: []string{"query:sid"}})
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/login", nil))
+	token := strings.TrimSpace(login.Body.String())
+
+	cases := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{"configured query", "/me?sid=" + token, "", http.StatusOK},
+		{"default header not configured", "/me", "X-Token", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: got %d want %d", tc.name, w.Code, tc.want)
+		}
+	}
+
+	if err := (&session.Config{Sources: []string{"path:sid"}}).Validate(); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
+
+func TestDefaultSourcesIgnoreAPIKeyAndRejectConflicts(t *testing.T) {
+	router := buildInitNameRouter(t, &session.Config{Driver: "memory"})
+	tokens := make([]string, 2)
+	for i := range tokens {
+		login := httptest.NewRecorder()
+		router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/login", nil))
+		tokens[i] = strings.TrimSpace(login.Body.String())
+	}
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		cookie  string
+		want    int
+	}{
+		{"x-api-key is not a session source", map[string]string{"X-Api-Key": tokens[0]}, "", http.StatusUnauthorized},
+		{"same token in header and cookie", map[string]string{"X-Token": tokens[0]}, tokens[0], http.StatusOK},
+		{"different tokens", map[string]string{"X-Token": tokens[0]}, tokens[1], http.StatusUnauthorized},
+		{"api key in bearer is ignored", map[string]string{"Authorization": "Bearer mk_abc.def_123"}, tokens[0], http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "token", Value: tc.cookie})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: got %d want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
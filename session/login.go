@@ -0,0 +1,111 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mulan-ext/auth/audit"
+)
+
+// CarryOver 登录轮换session时将旧数据带入新session
+//
+// old 为轮换前数据的快照，current 为已写入登录信息的新数据。old.ID() 非 0 时旧session属于已登录用户，
+// 自定义策略应通过 SameOwner 判断，避免把他人的数据带入新session。
+type CarryOver func(old, current Data)
+
+// SameOwner 旧session是否为匿名或与新session属于同一租户的同一用户
+func SameOwner(old, current Data) bool {
+	id := old.ID()
+	return id == 0 || id == current.ID() && tenantOf(old) == tenantOf(current)
+}
+
+// CarryKeys 返回按白名单带入item的 CarryOver，新session中已存在的key不会被覆盖
+//
+// 只带入匿名session或同一用户重新登录时的数据，见 SameOwner；内置字段 id、account、roles 不会被带入。
+func CarryKeys(keys ...string) CarryOver {
+	return func(old, current Data) {
+		if !SameOwner(old, current) {
+			return
+		}
+		items := current.Items()
+		for _, key := range keys {
+			switch key {
+			case "id", "account", "roles":
+				continue
+			}
+			if _, exists := items[key]; exists {
+				continue
+			}
+			if val := old.Get(key); val != nil {
+				current.SetValues(key, val)
+			}
+		}
+	}
+}
+
+// Carry 依次应用多个 CarryOver，忽略 nil
+func Carry(policies ...CarryOver) CarryOver {
+	return func(old, current Data) {
+		for _, policy := range policies {
+			if policy != nil {
+				policy(old, current)
+			}
+		}
+	}
+}
+
+// Login 以登录信息替换当前session：清空数据并轮换token以防止会话固定，
+// 调用 apply 写入用户信息，按 carry 带入旧数据后保存
+//
+// 第三方登录回调与表单登录均应使用该方法，而不是直接 Set 后 Save。
+func (s *Session) Login(apply func(*Session) error, carry ...CarryOver) error {
+	if err := s.rotate(apply, carry...); err != nil {
+		return err
+	}
+	data := s.Data()
+	audit.Emit(s.ctx, audit.Event{
+		Type:    audit.LoginSucceeded,
+		Method:  authInfo(data).Method,
+		UserID:  data.ID(),
+		Account: data.Account(),
+		Tenant:  tenantOf(data),
+		Session: audit.TokenID(s.Token()),
+	})
+	return nil
+}
+
+// rotate Login 的实现，不记录登录事件，代为操作复用
+func (s *Session) rotate(apply func(*Session) error, carry ...CarryOver) error {
+	if apply == nil {
+		return errors.New("session: login apply is nil")
+	}
+	var old Data
+	if len(carry) > 0 {
+		old = s.snapshot()
+	}
+	s.Data().Clear()
+	if err := s.Clear(); err != nil {
+		return fmt.Errorf("session: rotate session: %w", err)
+	}
+	if err := apply(s); err != nil {
+		return err
+	}
+	if old != nil {
+		Carry(carry...)(old, s.Data())
+	}
+	if err := s.Save(); err != nil {
+		return fmt.Errorf("session: save session: %w", err)
+	}
+	return nil
+}
+
+// snapshot 复制当前数据，用于轮换后读取旧值
+func (s *Session) snapshot() Data {
+	data := s.Data()
+	old := &DefaultData{}
+	old.SetID(data.ID()).SetAccount(data.Account()).SetState(data.State()).SetRoles(data.Roles())
+	for k, v := range data.Items() {
+		old.SetValues(k, v)
+	}
+	return old
+}
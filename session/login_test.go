@@ -0,0 +1,123 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mulan-ext/auth/session"
+)
+
+func newGuestSession(t *testing.T, store session.Store) *session.Session {
+	t.Helper()
+	sess := session.NewSession(context.Background(), store, &session.DefaultData{})
+	sess.Set("cart", []any{"sku-1"})
+	sess.Set("locale", "zh-CN")
+	sess.Set("csrf", "guest-csrf")
+	if err := sess.Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	return sess
+}
+
+func TestLoginRotatesAndCarriesAllowlistedKeys(t *testing.T) {
+	store := session.NewMemStore()
+	sess := newGuestSession(t, store)
+	guestToken := sess.Token()
+
+	err := sess.Login(func(s *session.Session) error {
+		s.SetID(1)
+		s.SetAccount("alice")
+		s.Set("locale", "en-US")
+		return nil
+	}, session.CarryKeys("cart", "locale", "account"))
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if sess.Token() == guestToken {
+		t.Fatal("登录未轮换token")
+	}
+	if _, err := store.Get(context.Background(), guestToken); err == nil {
+		t.Fatal("匿名session未删除")
+	}
+
+	loaded, err := store.Get(context.Background(), sess.Token())
+	if err != nil {
+		t.Fatalf("读取新session失败: %v", err)
+	}
+	if loaded.Account() != "alice" || loaded.ID() != 1 {
+		t.Fatalf("登录信息丢失: %s %d", loaded.Account(), loaded.ID())
+	}
+	if loaded.Get("cart") == nil {
+		t.Fatal("白名单key未带入")
+	}
+	if loaded.Get("locale") != "en-US" {
+		t.Fatalf("登录写入的值被覆盖: %v", loaded.Get("locale"))
+	}
+	if loaded.Get("csrf") != nil {
+		t.Fatal("非白名单key被带入")
+	}
+}
+
+func TestLoginMergeCallback(t *testing.T) {
+	sess := newGuestSession(t, session.NewMemStore())
+	var seenAccount string
+	err := sess.Login(func(s *session.Session) error {
+		s.SetAccount("bob")
+		return nil
+	}, func(old, current session.Data) {
+		seenAccount = current.Account()
+		if cart, ok := old.Get("cart").([]any); ok {
+			current.Set("cart", append(cart, "sku-2"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if seenAccount != "bob" {
+		t.Fatalf("合并回调应在写入登录信息后执行, got %q", seenAccount)
+	}
+	if cart, _ := sess.Get("cart").([]any); len(cart) != 2 {
+		t.Fatalf("合并结果错误: %v", sess.Get("cart"))
+	}
+
+	// 无策略时不带入任何数据
+	wantErr := errors.New("denied")
+	if err := sess.Login(func(*session.Session) error { return wantErr }); !errors.Is(err, wantErr) {
+		t.Fatalf("apply 错误未返回: %v", err)
+	}
+	if sess.Get("cart") != nil {
+		t.Fatal("未配置策略时不应带入数据")
+	}
+}
+
+func TestLoginDoesNotCarryAnotherUsersData(t *testing.T) {
+	store := session.NewMemStore()
+	sess := newGuestSession(t, store)
+	login := func(id uint64, account string) {
+		t.Helper()
+		err := sess.Login(func(s *session.Session) error {
+			s.SetID(id)
+			s.SetAccount(account)
+			return nil
+		}, session.CarryKeys("cart", "locale"))
+		if err != nil {
+			t.Fatalf("登录失败: %v", err)
+		}
+	}
+
+	login(1, "alice")
+	if sess.Get("cart") == nil {
+		t.Fatal("匿名数据未带入")
+	}
+	// 同一用户重新登录保留数据
+	login(1, "alice")
+	if sess.Get("cart") == nil {
+		t.Fatal("同一用户重新登录丢失数据")
+	}
+	// 其他用户在同一浏览器登录
+	login(2, "bob")
+	if sess.Get("cart") != nil || sess.Get("locale") != nil {
+		t.Fatalf("其他用户的数据被带入: %v", sess.Data().Items())
+	}
+}
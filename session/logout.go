@@ -0,0 +1,105 @@
+package session
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogoutConfig 退出登录配置
+type LogoutConfig struct {
+	// ReturnTo 默认回跳地址（站内绝对路径），为空且请求未携带 return_to 时返回 204
+	ReturnTo string
+	// ClearSiteData Clear-Site-Data 指令，如 "cookies"、"storage"、"cache"、"*"
+	ClearSiteData []string
+}
+
+// LogoutHandler 销毁当前session、过期Cookie并回跳
+//
+// 回跳地址优先取查询参数 return_to，只接受站内绝对路径，否则使用 ReturnTo。
+// 为防止跨站注销，建议注册为 POST 路由。
+func LogoutHandler(cfg ...LogoutConfig) gin.HandlerFunc {
+	logout := newLogout(cfg...)
+	return func(c *gin.Context) {
+		if !logout(c.Writer, c.Request, Default(c)) {
+			c.Abort()
+		}
+	}
+}
+
+// LogoutHTTP net/http 版本的 LogoutHandler，需挂在 Middleware 之后
+func LogoutHTTP(cfg ...LogoutConfig) http.HandlerFunc {
+	logout := newLogout(cfg...)
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess, ok := FromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logout(w, r, sess)
+	}
+}
+
+// newLogout 返回退出登录的框架无关实现，失败时返回 false
+func newLogout(cfg ...LogoutConfig) func(http.ResponseWriter, *http.Request, *Session) bool {
+	var conf LogoutConfig
+	if len(cfg) > 0 {
+		conf = cfg[0]
+	}
+	returnTo := safeReturnTo(conf.ReturnTo)
+	clearSiteData := make([]string, 0, len(conf.ClearSiteData))
+	for _, directive := range conf.ClearSiteData {
+		if directive = strings.Trim(strings.TrimSpace(directive), `"`); directive != "" {
+			clearSiteData = append(clearSiteData, `"`+directive+`"`)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request, sess *Session) bool {
+		if err := sess.Destroy(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return false
+		}
+		if len(clearSiteData) > 0 {
+			w.Header().Set("Clear-Site-Data", strings.Join(clearSiteData, ", "))
+		}
+		target := safeReturnTo(r.URL.Query().Get("return_to"))
+		if target == "" {
+			target = returnTo
+		}
+		if target == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return true
+	}
+}
+
+// expireCookie 以与下发时相同的属性过期session Cookie
+func expireCookie(w http.ResponseWriter, sess *Session) {
+	if sess.headerOnly || sess.name == "" {
+		return
+	}
+	sess.mu.Lock()
+	done := sess.expired
+	sess.expired = true
+	sess.mu.Unlock()
+	if done {
+		return
+	}
+	setCookie(w, sess.name, "", -1, sess.secure, sess.httpOnly)
+}
+
+// safeReturnTo 只接受站内绝对路径，防止开放重定向
+func safeReturnTo(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.IsAbs() || u.Host != "" || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(value, "//") || strings.HasPrefix(u.Path, "//") || strings.Contains(u.Path, "\\") {
+		return ""
+	}
+	return u.String()
+}
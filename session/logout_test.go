@@ -0,0 +1,139 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mulan-ext/auth/session"
+)
+
+func buildLogoutRouter(t *testing.T, cfg session.LogoutConfig) (*gin.Engine, session.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := session.NewMemStore()
+	r := gin.New()
+	r.Use(session.Mw("token", store))
+	r.GET("/login", func(c *gin.Context) {
+		sess := session.Default(c)
+		sess.SetID(7)
+		if err := sess.Save(); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, sess.Token())
+	})
+	r.POST("/logout", session.LogoutHandler(cfg))
+	r.POST("/api/logout", func(c *gin.Context) {
+		if err := session.Default(c).Destroy(); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	r.POST("/api/logout-json", func(c *gin.Context) {
+		if err := session.Default(c).Destroy(); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	return r, store
+}
+
+func loginToken(t *testing.T, r http.Handler) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	return strings.TrimSpace(w.Body.String())
+}
+
+func TestLogoutHandlerDestroysSessionAndCookie(t *testing.T) {
+	r, store := buildLogoutRouter(t, session.LogoutConfig{
+		ReturnTo:      "/goodbye",
+		ClearSiteData: []string{"cookies", `"storage"`},
+	})
+	token := loginToken(t, r)
+
+	req := httptest.NewRequest(http.MethodPost, "/logout?return_to=https://evil.example", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/goodbye" {
+		t.Fatalf("unexpected redirect: %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if got := w.Header().Get("X-Token"); got != "" {
+		t.Fatalf("token header emitted after logout: %q", got)
+	}
+	if got := w.Header().Get("Clear-Site-Data"); got != `"cookies", "storage"` {
+		t.Fatalf("unexpected Clear-Site-Data: %q", got)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "token" || cookies[0].MaxAge >= 0 || cookies[0].Value != "" || !cookies[0].HttpOnly {
+		t.Fatalf("cookie not expired: %+v", cookies)
+	}
+	if _, err := store.Get(req.Context(), token); err == nil {
+		t.Fatal("session still present in store")
+	}
+}
+
+func TestLogoutHandlerReturnTo(t *testing.T) {
+	r, _ := buildLogoutRouter(t, session.LogoutConfig{})
+	token := loginToken(t, r)
+
+	req := httptest.NewRequest(http.MethodPost, "/logout?return_to=/signin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/signin" {
+		t.Fatalf("unexpected redirect: %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/logout", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 without return path, got %d", w.Code)
+	}
+}
+
+func TestSessionDestroySuppressesToken(t *testing.T) {
+	r, _ := buildLogoutRouter(t, session.LogoutConfig{})
+	token := loginToken(t, r)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Token"); got != "" {
+		t.Fatalf("token header emitted after Destroy: %q", got)
+	}
+	if cookie := w.Header().Get("Set-Cookie"); !strings.Contains(cookie, "token=;") || !strings.Contains(cookie, "Max-Age=0") {
+		t.Fatalf("cookie not expired: %q", cookie)
+	}
+}
+
+func TestDestroyThenJSONExpiresCookie(t *testing.T) {
+	r, store := buildLogoutRouter(t, session.LogoutConfig{})
+	token := loginToken(t, r)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/logout-json", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ok":true`) {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	cookies := w.Header().Values("Set-Cookie")
+	if len(cookies) != 1 || !strings.Contains(cookies[0], "token=;") || !strings.Contains(cookies[0], "Max-Age=0") {
+		t.Fatalf("expected a single expiring cookie, got %q", cookies)
+	}
+	if w.Header().Get("X-Token") != "" {
+		t.Fatalf("destroyed session must not return X-Token")
+	}
+	if _, err := store.Get(req.Context(), token); err == nil {
+		t.Fatal("session not deleted")
+	}
+}
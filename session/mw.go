@@ -1,16 +1,28 @@
 package session
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
 )
 
+// AuthMW 要求请求已认证，与 AuthMiddleware 相同以 Principal 判断，兼容只设置了 CtxKeyID 的旧中间件
 func AuthMW() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if _, ok := c.Get(CtxKeyID); ok {
+		_, ok := principal.FromContext(c.Request.Context())
+		if !ok {
+			_, ok = c.Get(CtxKeyID)
+		}
+		if ok {
 			c.Next()
 			return
 		}
-		c.AbortWithStatus(401)
+		emitUnauthenticated(c.Request)
+		problem.Abort(c, problem.Unauthenticated(c.Request))
 	}
 }
 
@@ -27,6 +39,19 @@ func RoleMW(roles ...string) gin.HandlerFunc {
 				return
 			}
 		}
-		c.AbortWithStatus(403)
+		emitRoleDenied(c.Request, roles)
+		problem.Abort(c, problem.New(http.StatusForbidden, "insufficient_role"))
 	}
 }
+
+func emitUnauthenticated(r *http.Request) {
+	audit.Emit(r.Context(), audit.Event{Type: audit.Unauthenticated, Reason: "no_session"})
+}
+
+func emitRoleDenied(r *http.Request, roles []string) {
+	audit.Emit(r.Context(), audit.Event{
+		Type:   audit.AccessDenied,
+		Reason: "insufficient_role",
+		Fields: map[string]any{"required_roles": roles},
+	})
+}
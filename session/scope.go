@@ -0,0 +1,77 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/audit"
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+// CtxKeyScopes gin.Context 中当前凭证被授予的 scope，session 登录时不设置
+const CtxKeyScopes = "scopes"
+
+// HasScope 检查当前请求的凭证是否被授予指定 scope，session 登录不受 scope 限制
+func HasScope(c *gin.Context, scope string) bool {
+	p, ok := principal.FromContext(c.Request.Context())
+	return ok && p.HasScope(scope)
+}
+
+// RequireScope scope 中间件 - 要求当前凭证拥有全部指定 scope
+//
+// scope 只约束 API Key 等委托凭证，session 登录的用户仍由 RoleMW 控制权限。
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	check := newScopeCheck(scopes)
+	return func(c *gin.Context) {
+		if !check(c.Writer, c.Request) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScopeMiddleware net/http 版本的 RequireScope
+func RequireScopeMiddleware(scopes ...string) func(http.Handler) http.Handler {
+	check := newScopeCheck(scopes)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if check(w, r) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// newScopeCheck 返回 scope 检查，不满足时写出 insufficient_scope 响应并返回 false
+func newScopeCheck(scopes []string) func(http.ResponseWriter, *http.Request) bool {
+	required := strings.Join(scopes, " ")
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		p, ok := principal.FromContext(r.Context())
+		if !ok {
+			emitUnauthenticated(r)
+			problem.Write(w, r, problem.Unauthenticated(r))
+			return false
+		}
+		for _, scope := range scopes {
+			if !p.HasScope(scope) {
+				audit.Emit(r.Context(), audit.Event{
+					Type:   audit.AccessDenied,
+					Reason: "insufficient_scope",
+					Fields: map[string]any{"required_scopes": scopes},
+				})
+				challenge := problem.Bearer("error", "insufficient_scope", "scope", required)
+				if p.Method == principal.MethodAPIKey {
+					challenge = problem.APIKey("error", "insufficient_scope", "scope", required)
+				}
+				problem.Write(w, r, problem.New(http.StatusForbidden, "insufficient_scope").
+					With("scope", required).WithChallenge(challenge))
+				return false
+			}
+		}
+		return true
+	}
+}
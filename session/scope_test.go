@@ -0,0 +1,49 @@
+package session_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestRequireScopeMiddleware(t *testing.T) {
+	handler := session.RequireScopeMiddleware("read:reports", "write:orders")(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	cases := []struct {
+		name      string
+		p         *principal.Principal
+		want      int
+		challenge string
+	}{
+		{"anonymous", nil, http.StatusUnauthorized, "Bearer"},
+		{"session", &principal.Principal{ID: 1, Method: principal.MethodSession}, http.StatusOK, ""},
+		{"all scopes", &principal.Principal{Scopes: []string{"read:reports", "write:orders"}, Method: principal.MethodAPIKey}, http.StatusOK, ""},
+		{"wildcard", &principal.Principal{Scopes: []string{"*"}, Method: principal.MethodAPIKey}, http.StatusOK, ""},
+		{"missing scope", &principal.Principal{Scopes: []string{"read:reports"}, Method: principal.MethodAPIKey},
+			http.StatusForbidden, `ApiKey error="insufficient_scope", scope="read:reports write:orders"`},
+		{"no scopes", &principal.Principal{Scopes: []string{}, Method: principal.MethodAPIKey}, http.StatusForbidden, "ApiKey"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.p != nil {
+				r = r.WithContext(principal.NewContext(r.Context(), tc.p))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.want {
+				t.Fatalf("expected status %d, got %d", tc.want, w.Code)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, tc.challenge) {
+				t.Fatalf("unexpected challenge %q", got)
+			}
+			if tc.want == http.StatusForbidden && !strings.Contains(w.Body.String(), `"error":"insufficient_scope"`) {
+				t.Fatalf("unexpected body %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"slices"
 	"sync"
 	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/audit"
 )
 
 const (
@@ -16,17 +20,22 @@ const (
 )
 
 type Session struct {
-	store     Store
-	ctx       context.Context
-	data      Data
-	keyPrefix string
-	token     string
-	maxAge    int
-	secure    bool
-	httpOnly  bool
-	mu        sync.RWMutex
-	IsNil     bool
-	loaded    bool
+	store      Store
+	ctx        context.Context
+	data       Data
+	keyPrefix  string
+	token      string
+	name       string
+	maxAge     int
+	secure     bool
+	httpOnly   bool
+	headerOnly bool
+	mu         sync.RWMutex
+	IsNil      bool
+	loaded     bool
+	destroyed  bool
+	expired    bool   // 已下发过期Cookie
+	onDestroy  func() // 由中间件设置，Destroy 时立即过期Cookie
 }
 
 func (s *Session) Token() string {
@@ -39,11 +48,13 @@ func (s *Session) SetMaxAge(v int)    { s.maxAge = v }
 func (s *Session) SetSecure(v bool)   { s.secure = v }
 func (s *Session) SetHttpOnly(v bool) { s.httpOnly = v }
 
-func (s *Session) ID() uint64               { return s.Data().ID() }
-func (s *Session) Account() string          { return s.Data().Account() }
-func (s *Session) State() uint16            { return s.Data().State() }
-func (s *Session) Roles() []string          { return s.Data().Roles() }
-func (s *Session) HasRole(role string) bool { return slices.Contains(s.Roles(), role) }
+func (s *Session) ID() uint64      { return s.Data().ID() }
+func (s *Session) Account() string { return s.Data().Account() }
+func (s *Session) State() uint16   { return s.Data().State() }
+func (s *Session) Roles() []string { return s.Data().Roles() }
+func (s *Session) HasRole(role string) bool {
+	return slices.Contains(RolesFor(s.Roles(), s.Tenant()), role)
+}
 
 // Data 获取session数据，首次调用时从store加载
 func (s *Session) Data() Data {
@@ -77,18 +88,76 @@ func (s *Session) Clear() error {
 	defer s.mu.Unlock()
 
 	// 删除旧token
-	if s.token != "" {
-		if err := s.store.Clear(s.ctx, s.token); err != nil {
+	previous := s.token
+	if previous != "" {
+		if err := s.store.Clear(s.ctx, previous); err != nil {
 			return err
 		}
 	}
 
 	// 生成新token
+	s.bindTenant(s.data)
 	s.token = s.data.New()
 	s.loaded = false
+	s.destroyed = false
 
 	// 保存新session
-	return s.store.Save(s.ctx, s.data)
+	if err := s.store.Save(s.ctx, s.data); err != nil {
+		return err
+	}
+	audit.Emit(s.ctx, audit.Event{
+		Type:    audit.SessionRotated,
+		Session: audit.TokenID(s.token),
+		Tenant:  tenantOf(s.data),
+		Fields:  map[string]any{"previous": audit.TokenID(previous)},
+	})
+	return nil
+}
+
+// Destroy 删除session并停止下发token，用于退出登录
+//
+// 挂载中间件时立即写出过期Cookie，之后写出的响应同样生效，且不再返回 X-Token；之后调用 Save 或 Clear 会重新创建session。
+func (s *Session) Destroy() error {
+	if err := s.destroy(); err != nil {
+		return err
+	}
+	if s.onDestroy != nil {
+		s.onDestroy()
+	}
+	return nil
+}
+
+func (s *Session) destroy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" {
+		if err := s.store.Clear(s.ctx, s.token); err != nil {
+			return err
+		}
+	}
+	if id := s.data.ID(); id != 0 {
+		audit.Emit(s.ctx, audit.Event{
+			Type:    audit.Logout,
+			UserID:  id,
+			Account: s.data.Account(),
+			Tenant:  tenantOf(s.data),
+			Session: audit.TokenID(s.token),
+		})
+	}
+	s.data.Clear()
+	s.token = ""
+	s.loaded = true
+	s.IsNil = true
+	s.destroyed = true
+	return nil
+}
+
+// Destroyed 当前请求中session是否已被销毁
+func (s *Session) Destroyed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.destroyed
 }
 
 // Delete 删除指定key
@@ -98,20 +167,33 @@ func (s *Session) Delete(key string) error {
 	return s.store.Save(s.ctx, data)
 }
 
-func (s *Session) Get(key string) any            { return s.Data().Get(key) }
-func (s *Session) Set(key string, val any)       { s.Data().Set(key, val) }
-func (s *Session) SetID(val uint64)              { s.Data().SetID(val) }
-func (s *Session) SetAccount(val string)         { s.Data().SetAccount(val) }
-func (s *Session) SetState(val uint16)           { s.Data().SetState(val) }
-func (s *Session) SetRoles(roles []string)       { s.Data().SetRoles(roles) }
+func (s *Session) Get(key string) any { return s.Data().Get(key) }
+
+// Set 写入值，key 经 RegisterItem 注册时按类型与校验函数检查，不通过时不写入并记录错误；需要错误返回值时使用 SetAs
+func (s *Session) Set(key string, val any) {
+	if err := CheckItem(key, val); err != nil {
+		zap.L().Error("session: rejected item", zap.String("key", key), zap.Error(err))
+		return
+	}
+	s.Data().Set(key, val)
+}
+
+func (s *Session) SetID(val uint64)        { s.Data().SetID(val) }
+func (s *Session) SetAccount(val string)   { s.Data().SetAccount(val) }
+func (s *Session) SetState(val uint16)     { s.Data().SetState(val) }
+func (s *Session) SetRoles(roles []string) { s.Data().SetRoles(roles) }
+
+// SetValues 直接写入 items，不做 RegisterItem 校验，用于复制已保存的数据
 func (s *Session) SetValues(key string, val any) { s.Data().SetValues(key, val) }
 
 // Save 保存session数据
 func (s *Session) Save(lifetime ...time.Duration) error {
 	data := s.Data()
+	s.bindTenant(data)
 	s.mu.Lock()
 	if s.token == "" {
 		s.token = data.New()
+		s.destroyed = false
 	}
 	s.mu.Unlock()
 
@@ -0,0 +1,175 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+// 账号状态位，Data.State 为各位的组合，0 表示正常
+const (
+	StateActive          uint16 = 0
+	StateLocked          uint16 = 1 << 0 // 锁定或封禁
+	StateEmailUnverified uint16 = 1 << 1 // 邮箱未验证
+	StatePasswordExpired uint16 = 1 << 2 // 密码已过期
+)
+
+var stateNames = []struct {
+	flag uint16
+	name string
+}{
+	{StateLocked, "locked"},
+	{StateEmailUnverified, "email_unverified"},
+	{StatePasswordExpired, "password_expired"},
+}
+
+// StateString 返回状态位名称，以 "," 分隔，未知位以数值表示
+func StateString(state uint16) string {
+	if state == StateActive {
+		return "active"
+	}
+	names := make([]string, 0, len(stateNames))
+	for _, s := range stateNames {
+		if state&s.flag != 0 {
+			names = append(names, s.name)
+			state &^= s.flag
+		}
+	}
+	for bit := uint16(1); state != 0; bit <<= 1 {
+		if state&bit != 0 {
+			names = append(names, fmt.Sprintf("%#x", bit))
+			state &^= bit
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// StateRule 状态拦截规则
+type StateRule struct {
+	Flags    uint16   // 命中任一位时拦截
+	Redirect string   // 非空时 303 重定向到该地址，否则返回 Status
+	Status   int      // 默认 403
+	Except   []string // 放行的路径前缀，如验证邮箱、修改密码页面；Redirect 地址自动放行
+}
+
+// DefaultStateRules StateMW 未指定规则时使用：锁定账号一律拒绝
+var DefaultStateRules = []StateRule{{Flags: StateLocked}}
+
+// StateMW 状态中间件 - 按当前用户的状态位拦截请求，需挂在认证中间件之后，未认证请求直接放行
+//
+// 规则按顺序匹配，第一条命中的规则生效，响应头 X-Account-State 返回状态名称。
+func StateMW(rules ...StateRule) gin.HandlerFunc {
+	check := newStateCheck(rules)
+	return func(c *gin.Context) {
+		if check(c.Writer, c.Request) {
+			c.Next()
+			return
+		}
+		c.Abort()
+	}
+}
+
+// StateMiddleware net/http 版本的 StateMW
+func StateMiddleware(rules ...StateRule) func(http.Handler) http.Handler {
+	check := newStateCheck(rules)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if check(w, r) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// newStateCheck 返回状态检查的框架无关实现，拦截时写出响应并返回 false
+func newStateCheck(rules []StateRule) func(http.ResponseWriter, *http.Request) bool {
+	if len(rules) == 0 {
+		rules = DefaultStateRules
+	}
+	rules = slices.Clone(rules)
+	for i := range rules {
+		if rules[i].Redirect != "" {
+			rules[i].Except = append(slices.Clone(rules[i].Except), rules[i].Redirect)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		p, ok := principal.FromContext(r.Context())
+		if !ok || p.State == StateActive {
+			return true
+		}
+		for _, rule := range rules {
+			if p.State&rule.Flags == 0 || exempt(r.URL.Path, rule.Except) {
+				continue
+			}
+			w.Header().Set("X-Account-State", StateString(p.State))
+			if rule.Redirect != "" {
+				http.Redirect(w, r, rule.Redirect, http.StatusSeeOther)
+				return false
+			}
+			status := rule.Status
+			if status == 0 {
+				status = http.StatusForbidden
+			}
+			problem.Write(w, r, problem.New(status, "account_state").With("state", StateString(p.State)))
+			return false
+		}
+		return true
+	}
+}
+
+func exempt(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// StateHook 用户状态变更后调用，用于使保存了旧状态的凭证失效，如 remember.Store.DeleteUser、apikey.Manager.RevokeUser
+type StateHook func(ctx context.Context, tenant string, id uint64) error
+
+// UpdateUserState 更新租户内用户全部在线session的状态，保留各session剩余有效期，返回更新数量
+//
+// 用户ID按租户区分，单租户应用 tenant 传 ""。update 接收当前状态并返回新状态，
+// 如 func(s uint16) uint16 { return s | StateLocked }。
+// hooks 无论是否有在线session都会执行，记住登录、API Key 等保存了签发时状态的凭证应在此吊销，
+// 否则会以旧状态恢复登录。Store 需实现 Iterable。
+func UpdateUserState(ctx context.Context, store Store, tenant string, id uint64, update func(uint16) uint16, hooks ...StateHook) (int, error) {
+	n := 0
+	now := time.Now()
+	err := Walk(ctx, store, DefaultScanCount, func(e Entry) error {
+		if e.Data.ID() != id || tenantOf(e.Data) != tenant || e.Expired(now) {
+			return nil
+		}
+		state := update(e.Data.State())
+		if state == e.Data.State() {
+			return nil
+		}
+		e.Data.SetState(state)
+		var lifetime []time.Duration
+		if !e.Expire.IsZero() {
+			lifetime = append(lifetime, e.Expire.Sub(now))
+		}
+		if err := store.Save(ctx, e.Data, lifetime...); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	// 部分session更新失败时仍吊销其他凭证
+	errs := []error{err}
+	for _, hook := range hooks {
+		errs = append(errs, hook(ctx, tenant, id))
+	}
+	return n, errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package session_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestStateString(t *testing.T) {
+	cases := map[uint16]string{
+		session.StateActive: "active",
+		session.StateLocked: "locked",
+		session.StateEmailUnverified | session.StateLocked: "locked,email_unverified",
+		session.StatePasswordExpired | 1<<8:                "password_expired,0x100",
+	}
+	for state, want := range cases {
+		if got := session.StateString(state); got != want {
+			t.Errorf("StateString(%d) = %q, want %q", state, got, want)
+		}
+	}
+}
+
+func TestStateMW(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := session.NewMemStore()
+	router := gin.New()
+	router.Use(session.Mw("token", store), session.StateMW(
+		session.StateRule{Flags: session.StateLocked},
+		session.StateRule{Flags: session.StateEmailUnverified, Redirect: "/verify-email"},
+	))
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/orders", ok)
+	router.GET("/verify-email", ok)
+
+	tokens := map[uint16]string{}
+	for _, state := range []uint16{session.StateActive, session.StateLocked, session.StateEmailUnverified} {
+		data := (&session.DefaultData{}).SetID(1).SetState(state)
+		tokens[state] = data.New()
+		if err := store.Save(context.Background(), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		state uint16
+		path  string
+		want  int
+	}{
+		{session.StateActive, "/orders", http.StatusNoContent},
+		{session.StateLocked, "/orders", http.StatusForbidden},
+		{session.StateLocked, "/verify-email", http.StatusForbidden},
+		{session.StateEmailUnverified, "/orders", http.StatusSeeOther},
+		{session.StateEmailUnverified, "/verify-email", http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("X-Token", tokens[tc.state])
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("state %s %s: got %d want %d", session.StateString(tc.state), tc.path, w.Code, tc.want)
+		}
+		if tc.want == http.StatusSeeOther && w.Header().Get("Location") != "/verify-email" {
+			t.Errorf("redirect location: %q", w.Header().Get("Location"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("anonymous request should pass: %d", w.Code)
+	}
+}
+
+func TestUpdateUserState(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range iterableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var mine []string
+			for i := range 5 {
+				data := (&session.DefaultData{}).SetID(uint64(i%2 + 1))
+				token := data.New()
+				if data.ID() == 1 {
+					mine = append(mine, token)
+				}
+				if err := store.Save(ctx, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// 其他租户的同ID用户不受影响
+			other := (&session.DefaultData{}).SetID(1)
+			other.SetValues(session.ItemTenant, "acme")
+			otherToken := other.New()
+			if err := store.Save(ctx, other); err != nil {
+				t.Fatal(err)
+			}
+			lock := func(s uint16) uint16 { return s | session.StateLocked }
+			var hooked []string
+			hook := func(_ context.Context, tenant string, id uint64) error {
+				hooked = append(hooked, fmt.Sprintf("%s/%d", tenant, id))
+				return nil
+			}
+			n, err := session.UpdateUserState(ctx, store, "", 1, lock, hook)
+			if err != nil || n != len(mine) {
+				t.Fatalf("UpdateUserState = %d, %v; want %d", n, err, len(mine))
+			}
+			for _, token := range mine {
+				data, err := store.Get(ctx, token)
+				if err != nil || data.State() != session.StateLocked {
+					t.Fatalf("session %s not locked: %v", token, err)
+				}
+			}
+			if data, err := store.Get(session.WithTenant(ctx, "acme"), otherToken); err != nil || data.State() != session.StateActive {
+				t.Fatalf("user of another tenant locked: %v", err)
+			}
+			if len(hooked) != 1 || hooked[0] != "/1" {
+				t.Fatalf("unexpected hook calls %v", hooked)
+			}
+			// 状态未变化时不重复写入
+			if n, _ := session.UpdateUserState(ctx, store, "", 1, lock); n != 0 {
+				t.Fatalf("unchanged sessions rewritten: %d", n)
+			}
+		})
+	}
+}
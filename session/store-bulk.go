@@ -0,0 +1,84 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotIterable Store 未实现 Iterable 接口
+var ErrNotIterable = errors.New("store is not iterable")
+
+// Walk 分批遍历Store中的全部条目，fn 返回错误时终止遍历
+func Walk(ctx context.Context, store Store, batch int, fn func(Entry) error) error {
+	iter, ok := store.(Iterable)
+	if !ok {
+		return ErrNotIterable
+	}
+	if batch <= 0 {
+		batch = DefaultScanCount
+	}
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries, next, err := iter.Scan(ctx, cursor, batch)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Count 统计未过期的Session数量
+func Count(ctx context.Context, store Store) (int, error) {
+	n := 0
+	now := time.Now()
+	err := Walk(ctx, store, DefaultScanCount, func(e Entry) error {
+		if !e.Expired(now) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// DeleteWhere 删除满足条件的Session，返回删除数量
+func DeleteWhere(ctx context.Context, store Store, predicate func(Entry) bool) (int, error) {
+	n := 0
+	err := Walk(ctx, store, DefaultScanCount, func(e Entry) error {
+		if !predicate(e) {
+			return nil
+		}
+		if err := store.Clear(e.Context(ctx), e.Token); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// PurgeExpired 删除已过期但尚未清理的Session
+func PurgeExpired(ctx context.Context, store Store) (int, error) {
+	now := time.Now()
+	return DeleteWhere(ctx, store, func(e Entry) bool { return e.Expired(now) })
+}
+
+// Touch 刷新Session的过期时间，未指定 lifetime 时使用Store默认时长
+func Touch(ctx context.Context, store Store, token string, lifetime ...time.Duration) error {
+	data, err := store.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	return store.Save(ctx, data, lifetime...)
+}
@@ -0,0 +1,176 @@
+package session_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mulan-ext/auth/session"
+)
+
+// iterableStores 返回需要测试遍历能力的Store
+func iterableStores(t *testing.T) map[string]session.Store {
+	t.Helper()
+	fs, err := session.NewFsStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建文件存储失败: %v", err)
+	}
+	return map[string]session.Store{
+		"memory":  session.NewMemStore(),
+		"sharded": session.NewShardedMemStore(4),
+		"fs":      fs,
+	}
+}
+
+func TestStoreScanVisitsEveryEntry(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range iterableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			want := make(map[string]uint64)
+			for i := range 25 {
+				data := &session.DefaultData{}
+				data.SetID(uint64(i + 1))
+				want[data.New()] = uint64(i + 1)
+				if err := store.Save(ctx, data); err != nil {
+					t.Fatalf("保存失败: %v", err)
+				}
+			}
+
+			seen := make(map[string]uint64)
+			err := session.Walk(ctx, store, 7, func(e session.Entry) error {
+				if _, dup := seen[e.Token]; dup {
+					return fmt.Errorf("重复的token: %s", e.Token)
+				}
+				seen[e.Token] = e.Data.ID()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("遍历失败: %v", err)
+			}
+			if len(seen) != len(want) {
+				t.Fatalf("遍历数量不匹配: got %d, want %d", len(seen), len(want))
+			}
+			for token, id := range want {
+				if seen[token] != id {
+					t.Errorf("token %s 的ID不匹配: got %d, want %d", token, seen[token], id)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreBulkHelpers(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range iterableStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var tokens []string
+			for i := range 10 {
+				data := &session.DefaultData{}
+				data.SetID(uint64(i % 2))
+				tokens = append(tokens, data.New())
+				if err := store.Save(ctx, data); err != nil {
+					t.Fatalf("保存失败: %v", err)
+				}
+			}
+			expired := &session.DefaultData{}
+			expired.New()
+			if err := store.Save(ctx, expired, time.Millisecond); err != nil {
+				t.Fatalf("保存失败: %v", err)
+			}
+			time.Sleep(5 * time.Millisecond)
+
+			if n, err := session.Count(ctx, store); err != nil || n != 10 {
+				t.Fatalf("Count: got %d, %v; want 10", n, err)
+			}
+			if n, err := session.PurgeExpired(ctx, store); err != nil || n != 1 {
+				t.Fatalf("PurgeExpired: got %d, %v; want 1", n, err)
+			}
+			n, err := session.DeleteWhere(ctx, store, func(e session.Entry) bool { return e.Data.ID() == 1 })
+			if err != nil || n != 5 {
+				t.Fatalf("DeleteWhere: got %d, %v; want 5", n, err)
+			}
+			if n, _ := session.Count(ctx, store); n != 5 {
+				t.Fatalf("删除后数量不匹配: got %d, want 5", n)
+			}
+			if err := session.Touch(ctx, store, tokens[0], time.Hour); err != nil {
+				t.Fatalf("Touch失败: %v", err)
+			}
+			if err := session.Touch(ctx, store, tokens[1]); err == nil {
+				t.Fatal("Touch已删除的token应返回错误")
+			}
+		})
+	}
+}
+
+func TestWalkRejectsNonIterableStore(t *testing.T) {
+	var store struct{ session.Store }
+	if err := session.Walk(context.Background(), store, 0, nil); err != session.ErrNotIterable {
+		t.Fatalf("expected ErrNotIterable, got %v", err)
+	}
+}
+
+// TestTenantEntryCrossDriver 租户session经 Scan 复制到其他驱动后仍可用原token读取
+func TestTenantEntryCrossDriver(t *testing.T) {
+	ctx := context.Background()
+	newFs := func() session.Store {
+		store, err := session.NewFsStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+	drivers := map[string]func() session.Store{
+		"memory": func() session.Store { return session.NewMemStore() },
+		"fs":     newFs,
+	}
+	if client, ok := getRedisClient(); ok {
+		defer client.Close()
+		drivers["redis"] = func() session.Store {
+			store, err := session.NewRedisStore(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		}
+	}
+	for srcName, newSrc := range drivers {
+		for dstName, newDst := range drivers {
+			t.Run(srcName+"->"+dstName, func(t *testing.T) {
+				src, dst := newSrc(), newDst()
+				data := &session.DefaultData{}
+				data.SetID(7)
+				data.SetValues(session.ItemTenant, "acme")
+				if err := src.Save(ctx, data, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+				token := data.Token()
+				defer session.DeleteWhere(ctx, src, func(e session.Entry) bool { return e.Token == token })
+
+				err := session.Walk(ctx, src, 0, func(e session.Entry) error {
+					if e.Data.ID() != 7 {
+						return nil
+					}
+					if e.Token != token || e.Tenant != "acme" {
+						return fmt.Errorf("unexpected entry %q tenant %q", e.Token, e.Tenant)
+					}
+					e.Data.SetToken(e.Token)
+					return dst.Save(ctx, e.Data, time.Minute)
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := dst.Get(session.WithTenant(ctx, "acme"), token)
+				if err != nil || got.ID() != 7 {
+					t.Fatalf("copied session not found by token: %v", err)
+				}
+				if n, err := session.DeleteWhere(ctx, dst, func(e session.Entry) bool { return e.Token == token }); err != nil || n != 1 {
+					t.Fatalf("DeleteWhere = %d, %v", n, err)
+				}
+				if _, err := dst.Get(session.WithTenant(ctx, "acme"), token); err == nil {
+					t.Fatal("session not deleted")
+				}
+			})
+		}
+	}
+}
@@ -3,8 +3,11 @@ package session
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -17,7 +20,11 @@ const (
 	DefaultDirMode = 0755
 )
 
-var _ Store = (*FsStore)(nil)
+var (
+	_ Store        = (*FsStore)(nil)
+	_ Iterable     = (*FsStore)(nil)
+	_ TenantPurger = (*FsStore)(nil)
+)
 
 type FsData struct {
 	Data   Data      `json:"data"`
@@ -46,7 +53,7 @@ func NewFsStore(dir string, maxAge ...int) (*FsStore, error) {
 }
 
 func (s *FsStore) Clear(ctx context.Context, token string) error {
-	err := os.Remove(s.getFilePath(token))
+	err := os.Remove(s.getFilePath(TenantFromContext(ctx), token))
 	// 忽略文件不存在的错误
 	if os.IsNotExist(err) {
 		return nil
@@ -55,16 +62,8 @@ func (s *FsStore) Clear(ctx context.Context, token string) error {
 }
 
 func (s *FsStore) Get(ctx context.Context, token string) (Data, error) {
-	buf, err := os.ReadFile(s.getFilePath(token))
+	data, err := s.read(s.getFilePath(TenantFromContext(ctx), token))
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrTokenNotFound
-		}
-		return nil, err
-	}
-
-	var data FsData
-	if err := json.Unmarshal(buf, &data); err != nil {
 		return nil, err
 	}
 
@@ -109,12 +108,89 @@ func (s *FsStore) Save(ctx context.Context, v Data, lifetime ...time.Duration) e
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.getFilePath(token), buf, DefaultFileMode)
+	return os.WriteFile(s.getFilePath(tenantOf(v), token), buf, DefaultFileMode)
+}
+
+// Scan 按文件名顺序分批遍历目录，游标为上一批最后一个文件名（不含前缀）
+func (s *FsStore) Scan(ctx context.Context, cursor string, count int) ([]Entry, string, error) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, "", err
+	}
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		name, ok := strings.CutPrefix(file.Name(), s.prefix)
+		if ok && !file.IsDir() && name > cursor {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	next := ""
+	if len(names) > count {
+		names = names[:count]
+		next = names[count-1]
+	}
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		data, err := s.read(filepath.Join(s.dir, s.prefix+name))
+		if errors.Is(err, ErrTokenNotFound) {
+			// 遍历期间被删除
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		tenant, token := splitTenantKey(".", name)
+		entries = append(entries, Entry{Token: token, Tenant: tenant, Data: data.Data, Expire: data.Expire})
+	}
+	return entries, next, nil
+}
+
+// PurgeTenant 删除租户的全部session文件，返回删除数量
+func (s *FsStore) PurgeTenant(ctx context.Context, tenant string) (int, error) {
+	if !ValidTenant(tenant) {
+		return 0, ErrInvalidTenant
+	}
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), s.prefix+tenant+".") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// read 读取并解析Session文件
+func (s *FsStore) read(path string) (*FsData, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	// Data 为接口类型，需预先指定具体类型才能反序列化
+	data := &FsData{Data: &DefaultData{}}
+	if err := json.Unmarshal(buf, data); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
-// getFilePath 获取文件完整路径
-func (s *FsStore) getFilePath(token string) string {
-	return filepath.Join(s.dir, s.prefix+filepath.Base(token))
+// getFilePath 获取文件完整路径，租户session文件名为 prefix + "tenant." + token
+func (s *FsStore) getFilePath(tenant, token string) string {
+	return filepath.Join(s.dir, s.prefix+filepath.Base(tenantKey(tenant, ".", token)))
 }
 
 // calculateExpireTime 计算过期时间
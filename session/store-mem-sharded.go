@@ -2,7 +2,11 @@ package session
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"hash/fnv"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -60,4 +64,43 @@ func (s *ShardedMemStore) Save(ctx context.Context, v Data, lifetime ...time.Dur
 	return s.getShard(token).Save(ctx, v, lifetime...)
 }
 
-var _ Store = (*ShardedMemStore)(nil)
+// Scan 依次遍历各分片，游标格式为 "分片序号:分片内游标"
+func (s *ShardedMemStore) Scan(ctx context.Context, cursor string, count int) ([]Entry, string, error) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	shard, inner := 0, ""
+	if cursor != "" {
+		idx, rest, ok := strings.Cut(cursor, ":")
+		n, err := strconv.Atoi(idx)
+		if !ok || err != nil || n < 0 || n >= len(s.shards) {
+			return nil, "", errors.New("invalid cursor")
+		}
+		shard, inner = n, rest
+	}
+	var entries []Entry
+	for shard < len(s.shards) {
+		batch, next, err := s.shards[shard].Scan(ctx, inner, count-len(entries))
+		if err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, batch...)
+		if next == "" {
+			shard, inner = shard+1, ""
+		} else {
+			inner = next
+		}
+		if len(entries) >= count {
+			break
+		}
+	}
+	if shard >= len(s.shards) {
+		return entries, "", nil
+	}
+	return entries, fmt.Sprintf("%d:%s", shard, inner), nil
+}
+
+var (
+	_ Store    = (*ShardedMemStore)(nil)
+	_ Iterable = (*ShardedMemStore)(nil)
+)
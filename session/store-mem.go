@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"errors"
+	"slices"
 	"sync"
 	"time"
 )
@@ -14,7 +15,10 @@ var (
 	ErrTokenExpired = errors.New("token expired")
 )
 
-var _ Store = (*MemStore)(nil)
+var (
+	_ Store    = (*MemStore)(nil)
+	_ Iterable = (*MemStore)(nil)
+)
 
 type memData struct {
 	data   Data
@@ -81,6 +85,34 @@ func (s *MemStore) Save(ctx context.Context, v Data, lifetime ...time.Duration)
 	return nil
 }
 
+// Scan 按token字典序分批遍历，游标为上一批最后一个token
+func (s *MemStore) Scan(ctx context.Context, cursor string, count int) ([]Entry, string, error) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	s.mu.RLock()
+	tokens := make([]string, 0, len(s.data))
+	for token := range s.data {
+		if token > cursor {
+			tokens = append(tokens, token)
+		}
+	}
+	slices.Sort(tokens)
+	next := ""
+	if len(tokens) > count {
+		tokens = tokens[:count]
+		next = tokens[count-1]
+	}
+	entries := make([]Entry, 0, len(tokens))
+	for _, token := range tokens {
+		if data, ok := s.data[token]; ok {
+			entries = append(entries, Entry{Token: token, Tenant: tenantOf(data.data), Data: data.data, Expire: data.expire})
+		}
+	}
+	s.mu.RUnlock()
+	return entries, next, nil
+}
+
 // calculateExpireTime 计算过期时间（提取公共逻辑）
 func (s *MemStore) calculateExpireTime(lifetime ...time.Duration) time.Time {
 	if len(lifetime) > 0 && lifetime[0] > 0 {
@@ -2,13 +2,19 @@ package session
 
 import (
 	"context"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
-var _ Store = (*RedisStore)(nil)
+var (
+	_ Store        = (*RedisStore)(nil)
+	_ Iterable     = (*RedisStore)(nil)
+	_ TenantPurger = (*RedisStore)(nil)
+)
 
 type RedisStore struct {
 	client    redis.UniversalClient
@@ -29,11 +35,11 @@ func NewRedisStore(client redis.UniversalClient, maxAge ...int) (*RedisStore, er
 }
 
 func (s *RedisStore) Clear(ctx context.Context, token string) error {
-	return s.client.Del(ctx, s.getKey(token)).Err()
+	return s.client.Del(ctx, s.getKey(TenantFromContext(ctx), token)).Err()
 }
 
 func (s *RedisStore) Get(ctx context.Context, token string) (Data, error) {
-	key := s.getKey(token)
+	key := s.getKey(TenantFromContext(ctx), token)
 	result := s.client.HGetAll(ctx, key)
 
 	if result.Err() != nil {
@@ -63,7 +69,7 @@ func (s *RedisStore) Save(ctx context.Context, v Data, lifetime ...time.Duration
 		token = v.New()
 	}
 
-	key := s.getKey(token)
+	key := s.getKey(tenantOf(v), token)
 	expiration := s.calculateExpireTime(lifetime...)
 
 	// 使用Pipeline提高性能
@@ -84,9 +90,100 @@ func (s *RedisStore) Save(ctx context.Context, v Data, lifetime ...time.Duration
 	return nil
 }
 
-// getKey 获取完整的Redis key
-func (s *RedisStore) getKey(token string) string {
-	return s.keyPrefix + token
+// Scan 使用 SCAN 遍历 keyPrefix 下的key，游标为Redis游标
+//
+// 与 SCAN 语义一致：遍历期间变化的key可能被重复返回或遗漏。
+func (s *RedisStore) Scan(ctx context.Context, cursor string, count int) ([]Entry, string, error) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	var pos uint64
+	if cursor != "" {
+		var err error
+		if pos, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", err
+		}
+	}
+	keys, pos, err := s.client.Scan(ctx, pos, s.keyPrefix+"*", int64(count)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if pos != 0 {
+		next = strconv.FormatUint(pos, 10)
+	}
+	if len(keys) == 0 {
+		return nil, next, nil
+	}
+
+	pipe := s.client.Pipeline()
+	values := make([]*redis.MapStringStringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		values[i] = pipe.HGetAll(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	entries := make([]Entry, 0, len(keys))
+	for i, key := range keys {
+		if len(values[i].Val()) == 0 {
+			// 遍历期间被删除或过期
+			continue
+		}
+		data := &DefaultData{}
+		if err := values[i].Scan(data); err != nil {
+			zap.L().Error("Failed to scan redis data",
+				zap.String("key", key),
+				zap.Error(err))
+			return nil, "", err
+		}
+		tenant, token := splitTenantKey(":", strings.TrimPrefix(key, s.keyPrefix))
+		entry := Entry{Token: token, Tenant: tenant, Data: data}
+		if ttl := ttls[i].Val(); ttl > 0 {
+			entry.Expire = now.Add(ttl)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, next, nil
+}
+
+// PurgeTenant 删除租户的全部session，返回删除数量
+func (s *RedisStore) PurgeTenant(ctx context.Context, tenant string) (int, error) {
+	if !ValidTenant(tenant) {
+		return 0, ErrInvalidTenant
+	}
+	n := 0
+	iter := s.client.Scan(ctx, 0, s.keyPrefix+tenant+":*", DefaultScanCount).Iterator()
+	keys := make([]string, 0, DefaultScanCount)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		deleted, err := s.client.Del(ctx, keys...).Result()
+		n += int(deleted)
+		keys = keys[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		if keys = append(keys, iter.Val()); len(keys) == DefaultScanCount {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// getKey 获取完整的Redis key，租户session为 keyPrefix + "tenant:" + token
+func (s *RedisStore) getKey(tenant, token string) string {
+	return s.keyPrefix + tenantKey(tenant, ":", token)
 }
 
 // calculateExpireTime 计算过期时间
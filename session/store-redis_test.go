@@ -225,6 +225,53 @@ func TestRedisStoreWithSession(t *testing.T) {
 	store.Clear(ctx, "session_test_token")
 }
 
+// TestRedisStoreScan 测试RedisStore遍历
+func TestRedisStoreScan(t *testing.T) {
+	client, ok := getRedisClient()
+	if !ok {
+		t.Skip("Redis not available, skipping test")
+		return
+	}
+	defer client.Close()
+
+	store, err := session.NewRedisStore(client)
+	if err != nil {
+		t.Fatal("Failed to create RedisStore:", err)
+	}
+
+	ctx := context.Background()
+	tokens := make(map[string]struct{})
+	for i := range 20 {
+		data := &session.DefaultData{Token_: fmt.Sprintf("scan_test_token_%03d", i)}
+		data.SetID(uint64(i))
+		if err := store.Save(ctx, data, time.Minute); err != nil {
+			t.Fatal("Failed to save:", err)
+		}
+		tokens[data.Token()] = struct{}{}
+	}
+	defer session.DeleteWhere(ctx, store, func(e session.Entry) bool {
+		_, ok := tokens[e.Token]
+		return ok
+	})
+
+	seen := make(map[string]struct{})
+	err = session.Walk(ctx, store, 5, func(e session.Entry) error {
+		if _, ok := tokens[e.Token]; ok {
+			seen[e.Token] = struct{}{}
+			if e.Expire.IsZero() {
+				t.Errorf("Expected expire for %s", e.Token)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Failed to walk:", err)
+	}
+	if len(seen) != len(tokens) {
+		t.Errorf("Expected %d tokens, got %d", len(tokens), len(seen))
+	}
+}
+
 // BenchmarkRedisStoreSave 基准测试：保存
 func BenchmarkRedisStoreSave(b *testing.B) {
 	client, ok := getRedisClient()
@@ -480,3 +527,37 @@ func BenchmarkRedisStoreWithPipeline(b *testing.B) {
 		}
 	})
 }
+
+// TestRedisStoreTenant 测试租户命名空间与批量删除
+func TestRedisStoreTenant(t *testing.T) {
+	client, ok := getRedisClient()
+	if !ok {
+		t.Skip("Redis not available, skipping test")
+		return
+	}
+	defer client.Close()
+
+	store, err := session.NewRedisStore(client)
+	if err != nil {
+		t.Fatal("Failed to create RedisStore:", err)
+	}
+	ctx := session.WithTenant(context.Background(), "redis-acme")
+	data := &session.DefaultData{}
+	data.SetID(1).SetValues(session.ItemTenant, "redis-acme")
+	if err := store.Save(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, data.Token()); err != nil {
+		t.Fatalf("Get in tenant: %v", err)
+	}
+	if _, err := store.Get(context.Background(), data.Token()); err == nil {
+		t.Fatal("tenant session visible without tenant")
+	}
+	n, err := session.PurgeTenant(context.Background(), store, "redis-acme")
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeTenant = %d, %v", n, err)
+	}
+	if _, err := store.Get(ctx, data.Token()); err == nil {
+		t.Fatal("purged session still present")
+	}
+}
@@ -5,8 +5,40 @@ import (
 	"time"
 )
 
+// DefaultScanCount Scan 默认批大小
+const DefaultScanCount = 100
+
 type Store interface {
 	Clear(ctx context.Context, v string) error
 	Get(ctx context.Context, v string) (Data, error)
 	Save(ctx context.Context, v Data, lifetime ...time.Duration) error
 }
+
+// Iterable 可遍历的Store（可选接口）
+//
+// Scan 以游标方式分批返回条目，首次调用传入空游标，返回的 next 为空时表示遍历结束。
+// 返回的条目可能包含已过期但尚未清理的数据，调用方可通过 Entry.Expired 判断。
+type Iterable interface {
+	Scan(ctx context.Context, cursor string, count int) (entries []Entry, next string, err error)
+}
+
+// Entry Store 中的一条Session记录
+type Entry struct {
+	Token  string // 原始token，不含租户前缀
+	Tenant string // 所属租户，非租户session为空
+	Data   Data
+	Expire time.Time // 零值表示永不过期
+}
+
+// Context 返回携带条目租户的 context，用于按 Token 调用 Store.Get、Store.Clear
+func (e Entry) Context(ctx context.Context) context.Context {
+	if e.Tenant == "" {
+		return ctx
+	}
+	return WithTenant(ctx, e.Tenant)
+}
+
+// Expired 判断条目在指定时间是否已过期
+func (e Entry) Expired(now time.Time) bool {
+	return !e.Expire.IsZero() && e.Expire.Before(now)
+}
@@ -0,0 +1,238 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/problem"
+)
+
+const (
+	// ItemTenant session中保存租户ID的item key
+	ItemTenant = "tenant"
+	// CtxKeyTenant gin.Context 中当前请求的租户ID
+	CtxKeyTenant = "tenant"
+)
+
+// ErrInvalidTenant 租户ID为空或不合法
+var ErrInvalidTenant = errors.New("session: invalid tenant")
+
+// tenantValid 租户ID只允许字母、数字、"_"、"-"，用于Store key与文件名
+var tenantValid = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidTenant 判断租户ID是否合法
+func ValidTenant(tenant string) bool { return tenantValid.MatchString(tenant) }
+
+type tenantCtxKey struct{}
+
+// WithTenant 返回携带租户ID的 context
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenant)
+}
+
+// TenantFromContext 获取 context 中的租户ID，未设置时为空
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantCtxKey{}).(string)
+	return tenant
+}
+
+// Tenant 返回session所属租户
+func (s *Session) Tenant() string { return tenantOf(s.Data()) }
+
+// SetTenant 设置session所属租户，请求携带租户时 Save、Login 会自动设置
+func (s *Session) SetTenant(tenant string) { s.Data().SetValues(ItemTenant, tenant) }
+
+func tenantOf(data Data) string {
+	tenant, _ := data.Get(ItemTenant).(string)
+	return tenant
+}
+
+// bindTenant 为尚未绑定租户的数据写入请求的租户
+func (s *Session) bindTenant(data Data) {
+	if tenant := TenantFromContext(s.ctx); tenant != "" && tenantOf(data) == "" {
+		data.SetValues(ItemTenant, tenant)
+	}
+}
+
+// tenantKey 返回租户命名空间下的key，非租户session为 token 本身
+func tenantKey(tenant, sep, token string) string {
+	if !ValidTenant(tenant) {
+		return token
+	}
+	return tenant + sep + token
+}
+
+// splitTenantKey tenantKey 的逆操作
+func splitTenantKey(sep, key string) (tenant, token string) {
+	if tenant, token, ok := strings.Cut(key, sep); ok && ValidTenant(tenant) {
+		return tenant, token
+	}
+	return "", key
+}
+
+// TenantPurger 支持按租户批量删除session的Store（可选接口）
+type TenantPurger interface {
+	PurgeTenant(ctx context.Context, tenant string) (int, error)
+}
+
+// PurgeTenant 删除租户的全部session，返回删除数量
+//
+// RedisStore、FsStore 按租户命名空间直接删除，其他 Store 需实现 Iterable，遍历后按 ItemTenant 删除。
+func PurgeTenant(ctx context.Context, store Store, tenant string) (int, error) {
+	if !ValidTenant(tenant) {
+		return 0, ErrInvalidTenant
+	}
+	if purger, ok := store.(TenantPurger); ok {
+		return purger.PurgeTenant(ctx, tenant)
+	}
+	return DeleteWhere(ctx, store, func(e Entry) bool { return tenantOf(e.Data) == tenant })
+}
+
+// TenantRole 返回租户内角色名，如 TenantRole("editor", "acme") 为 "editor@acme"
+//
+// 不带 "@" 的角色在所有租户内生效，带 "@" 的角色只在对应租户内生效。
+func TenantRole(role, tenant string) string { return role + "@" + tenant }
+
+// RolesFor 返回在租户 tenant 内生效的角色，租户角色去掉 "@tenant" 后缀
+func RolesFor(roles []string, tenant string) []string {
+	if roles == nil {
+		return nil
+	}
+	effective := make([]string, 0, len(roles))
+	for _, role := range roles {
+		name, scope, scoped := strings.Cut(role, "@")
+		if !scoped {
+			effective = append(effective, role)
+		} else if scope == tenant && tenant != "" {
+			effective = append(effective, name)
+		}
+	}
+	return effective
+}
+
+// TenantResolver 从请求中解析租户ID，无法解析时返回空
+type TenantResolver func(r *http.Request) string
+
+// TenantFromHeader 从请求头 name 解析租户
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	}
+}
+
+// TenantFromSubdomain 从 domain 的一级子域名解析租户，如 domain 为 "example.com" 时 "acme.example.com" 为 "acme"
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(r *http.Request) string {
+		sub, ok := strings.CutSuffix(hostname(r), suffix)
+		if !ok || strings.Contains(sub, ".") {
+			return ""
+		}
+		return sub
+	}
+}
+
+// TenantFromHost 按完整主机名映射租户，用于租户自定义域名
+func TenantFromHost(hosts map[string]string) TenantResolver {
+	m := make(map[string]string, len(hosts))
+	for host, tenant := range hosts {
+		m[strings.ToLower(host)] = tenant
+	}
+	return func(r *http.Request) string {
+		return m[hostname(r)]
+	}
+}
+
+// TenantFromPath 从路径前缀后的第一段解析租户，如 prefix 为 "/t/" 时 "/t/acme/orders" 为 "acme"
+func TenantFromPath(prefix string) TenantResolver {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	if prefix == "//" {
+		prefix = "/"
+	}
+	return func(r *http.Request) string {
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok {
+			return ""
+		}
+		tenant, _, _ := strings.Cut(rest, "/")
+		return tenant
+	}
+}
+
+// TenantChain 依次尝试多个解析器，返回第一个非空结果
+func TenantChain(resolvers ...TenantResolver) TenantResolver {
+	return func(r *http.Request) string {
+		for _, resolve := range resolvers {
+			if tenant := resolve(r); tenant != "" {
+				return tenant
+			}
+		}
+		return ""
+	}
+}
+
+func hostname(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// TenantMW 租户中间件 - 解析当前请求的租户并写入 context，需挂在session中间件之前
+//
+// 无法解析或租户ID不合法时返回 404；context 中已有的 Principal（如 API Key）租户不一致时返回 403。
+// session中间件随后按租户读取Store，其他租户的session视为未登录。
+func TenantMW(resolve TenantResolver) gin.HandlerFunc {
+	check := newTenantCheck(resolve)
+	return func(c *gin.Context) {
+		r, ok := check(c.Writer, c.Request)
+		if !ok {
+			c.Abort()
+			return
+		}
+		c.Request = r
+		c.Set(CtxKeyTenant, TenantFromContext(r.Context()))
+		c.Next()
+	}
+}
+
+// TenantMiddleware net/http 版本的 TenantMW
+func TenantMiddleware(resolve TenantResolver) func(http.Handler) http.Handler {
+	check := newTenantCheck(resolve)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r, ok := check(w, r); ok {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// newTenantCheck 返回租户解析的框架无关实现，拒绝时写出响应并返回 false
+func newTenantCheck(resolve TenantResolver) func(http.ResponseWriter, *http.Request) (*http.Request, bool) {
+	return func(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+		tenant := resolve(r)
+		if !ValidTenant(tenant) {
+			problem.Write(w, r, problem.New(http.StatusNotFound, "unknown_tenant"))
+			return r, false
+		}
+		if p, ok := principal.FromContext(r.Context()); ok && p.Tenant != tenant {
+			zap.L().Warn("tenant mismatch",
+				zap.String("tenant", tenant),
+				zap.String("principal_tenant", p.Tenant),
+				zap.Uint64("id", p.ID))
+			problem.Write(w, r, problem.New(http.StatusForbidden, "tenant_mismatch"))
+			return r, false
+		}
+		return r.WithContext(WithTenant(r.Context(), tenant)), true
+	}
+}
@@ -0,0 +1,159 @@
+package session_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mulan-ext/auth/principal"
+	"github.com/mulan-ext/auth/session"
+)
+
+func TestTenantResolvers(t *testing.T) {
+	resolve := session.TenantChain(
+		session.TenantFromHost(map[string]string{"shop.acme.io": "acme"}),
+		session.TenantFromSubdomain("example.com"),
+		session.TenantFromHeader("X-Tenant"),
+		session.TenantFromPath("/t/"),
+	)
+	cases := []struct {
+		host, path, header, want string
+	}{
+		{"shop.acme.io:8443", "/", "", "acme"},
+		{"globex.example.com", "/", "", "globex"},
+		{"a.b.example.com", "/", "", ""},
+		{"example.com", "/", "initech", "initech"},
+		{"localhost", "/t/umbrella/orders", "", "umbrella"},
+		{"localhost", "/orders", "", ""},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		r.Host = tc.host
+		if tc.header != "" {
+			r.Header.Set("X-Tenant", tc.header)
+		}
+		if got := resolve(r); got != tc.want {
+			t.Errorf("resolve(%s%s) = %q, want %q", tc.host, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRolesFor(t *testing.T) {
+	roles := []string{"user", session.TenantRole("admin", "acme"), "editor@globex"}
+	if got := session.RolesFor(roles, "acme"); !slices.Equal(got, []string{"user", "admin"}) {
+		t.Fatalf("acme roles: %v", got)
+	}
+	if got := session.RolesFor(roles, ""); !slices.Equal(got, []string{"user"}) {
+		t.Fatalf("untenanted roles: %v", got)
+	}
+}
+
+func TestTenantMW(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, err := session.NewFsStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.Use(session.TenantMW(session.TenantFromSubdomain("example.com")), session.Mw("token", store))
+	router.POST("/login", func(c *gin.Context) {
+		err := session.Default(c).Login(func(sess *session.Session) error {
+			sess.SetID(1)
+			sess.SetRoles([]string{"user", session.TenantRole(session.RoleAdmin, "acme")})
+			return nil
+		})
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/admin", session.AuthMW(), session.RoleMW(session.RoleAdmin), func(c *gin.Context) {
+		p, _ := principal.FromContext(c.Request.Context())
+		c.String(http.StatusOK, "%s %s", c.GetString(session.CtxKeyTenant), p.Tenant)
+	})
+	do := func(method, host, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, "/login", nil)
+		if method == http.MethodGet {
+			r = httptest.NewRequest(method, "/admin", nil)
+		}
+		r.Host = host
+		if token != "" {
+			r.Header.Set("X-Token", token)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	token := do(http.MethodPost, "acme.example.com", "").Header().Get("X-Token")
+	if token == "" {
+		t.Fatal("login did not issue a token")
+	}
+	if w := do(http.MethodGet, "acme.example.com", token); w.Code != http.StatusOK || w.Body.String() != "acme acme" {
+		t.Fatalf("same tenant: %d %s", w.Code, w.Body.String())
+	}
+
+	// 其他租户的session视为未登录
+	if w := do(http.MethodGet, "globex.example.com", token); w.Code != http.StatusUnauthorized {
+		t.Fatalf("cross tenant: %d", w.Code)
+	}
+	// 租户角色只在所属租户内生效
+	other := do(http.MethodPost, "globex.example.com", "").Header().Get("X-Token")
+	if w := do(http.MethodGet, "globex.example.com", other); w.Code != http.StatusForbidden {
+		t.Fatalf("tenant role leaked: %d", w.Code)
+	}
+	if w := do(http.MethodGet, "example.com", token); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown tenant: %d", w.Code)
+	}
+
+	n, err := session.PurgeTenant(context.Background(), store, "acme")
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeTenant = %d, %v", n, err)
+	}
+	if w := do(http.MethodGet, "acme.example.com", token); w.Code != http.StatusUnauthorized {
+		t.Fatalf("purged session still valid: %d", w.Code)
+	}
+	if _, err := store.Get(session.WithTenant(context.Background(), "globex"), other); err != nil {
+		t.Fatalf("other tenant purged: %v", err)
+	}
+}
+
+func TestTenantMiddlewarePrincipalMismatch(t *testing.T) {
+	handler := session.TenantMiddleware(session.TenantFromHeader("X-Tenant"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(session.TenantFromContext(r.Context())))
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Tenant", "acme")
+	r = r.WithContext(principal.NewContext(r.Context(), &principal.Principal{ID: 1, Tenant: "globex"}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("mismatched principal: %d", w.Code)
+	}
+}
+
+func TestPurgeTenantWalk(t *testing.T) {
+	ctx := context.Background()
+	store := session.NewMemStore()
+	for _, tenant := range []string{"acme", "acme", "globex"} {
+		data := &session.DefaultData{}
+		data.SetValues(session.ItemTenant, tenant)
+		if err := store.Save(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := session.PurgeTenant(ctx, store, "acme"); err != nil || n != 2 {
+		t.Fatalf("PurgeTenant = %d, %v", n, err)
+	}
+	if n, _ := session.Count(ctx, store); n != 1 {
+		t.Fatalf("remaining sessions = %d", n)
+	}
+	if _, err := session.PurgeTenant(ctx, store, "a:b"); err == nil {
+		t.Fatal("invalid tenant accepted")
+	}
+}
@@ -0,0 +1,136 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	// ErrItemNotFound session中不存在该key
+	ErrItemNotFound = errors.New("session: item not found")
+	// ErrItemType 值与目标类型或注册的类型不符
+	ErrItemType = errors.New("session: item type mismatch")
+)
+
+// itemSchema 已注册key的类型与校验函数
+type itemSchema struct {
+	typ   reflect.Type
+	check func(any) error
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]itemSchema)
+)
+
+// RegisterItem 为key注册值类型 T 及可选校验函数，Set、SetAs 写入时校验，GetAs 读取时要求类型一致
+//
+// 内置字段 id、account、roles 不可注册；key 重复注册时 panic。
+func RegisterItem[T any](key string, validate ...func(T) error) {
+	switch key {
+	case "", "id", "account", "roles":
+		panic("session: RegisterItem invalid key " + key)
+	}
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	if _, dup := schemas[key]; dup {
+		panic("session: RegisterItem called twice for key " + key)
+	}
+	schemas[key] = itemSchema{
+		typ: reflect.TypeFor[T](),
+		check: func(v any) error {
+			for _, fn := range validate {
+				if err := fn(v.(T)); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// CheckItem 按注册信息校验值，未注册的key始终通过
+func CheckItem(key string, val any) error {
+	schemasMu.RLock()
+	schema, ok := schemas[key]
+	schemasMu.RUnlock()
+	if !ok {
+		return nil
+	}
+	if val == nil || reflect.TypeOf(val) != schema.typ {
+		return fmt.Errorf("%w: %s requires %s, got %T", ErrItemType, key, schema.typ, val)
+	}
+	if err := schema.check(val); err != nil {
+		return fmt.Errorf("session: invalid item %s: %w", key, err)
+	}
+	return nil
+}
+
+// SetAs 校验后写入值，不会自动保存
+func SetAs[T any](s *Session, key string, val T) error {
+	if err := CheckItem(key, val); err != nil {
+		return err
+	}
+	s.Data().Set(key, val)
+	return nil
+}
+
+// GetAs 读取值并转换为 T
+//
+// 经 FsStore、RedisStore 序列化后数字变为 float64、结构体变为 map[string]any，
+// 此时通过 JSON 重新解码为 T 并写回当前session，后续读取无需再次解码。
+func GetAs[T any](s *Session, key string) (T, error) {
+	var zero T
+	if err := checkType[T](key); err != nil {
+		return zero, err
+	}
+	raw := s.Get(key)
+	if raw == nil {
+		return zero, fmt.Errorf("%w: %s", ErrItemNotFound, key)
+	}
+	if v, ok := raw.(T); ok {
+		return v, nil
+	}
+	v, err := decodeItem[T](raw)
+	if err != nil {
+		return zero, fmt.Errorf("%w: %s: %v", ErrItemType, key, err)
+	}
+	s.Data().SetValues(key, v)
+	return v, nil
+}
+
+// decodeItem 通过 JSON 将反序列化后的通用值转换为 T
+func decodeItem[T any](raw any) (T, error) {
+	var v T
+	if typed, ok := raw.(T); ok {
+		return typed, nil
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(buf, &v)
+	return v, err
+}
+
+// GetOr 读取值，不存在或无法转换时返回 def
+func GetOr[T any](s *Session, key string, def T) T {
+	if v, err := GetAs[T](s, key); err == nil {
+		return v
+	}
+	return def
+}
+
+// checkType 检查 T 与key注册的类型是否一致
+func checkType[T any](key string) error {
+	schemasMu.RLock()
+	schema, ok := schemas[key]
+	schemasMu.RUnlock()
+	if ok && schema.typ != reflect.TypeFor[T]() {
+		return fmt.Errorf("%w: %s requires %s, got %s", ErrItemType, key, schema.typ, reflect.TypeFor[T]())
+	}
+	return nil
+}
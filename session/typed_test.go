@@ -0,0 +1,100 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mulan-ext/auth/session"
+)
+
+type typedProfile struct {
+	Nickname string `json:"nickname"`
+	Level    int    `json:"level"`
+}
+
+func init() {
+	session.RegisterItem("typed.level", func(v int) error {
+		if v < 0 {
+			return errors.New("level must not be negative")
+		}
+		return nil
+	})
+}
+
+func TestGetAsDecodesAfterStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := session.NewFsStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建文件存储失败: %v", err)
+	}
+	sess := session.NewSession(ctx, store, &session.DefaultData{})
+	if err := session.SetAs(sess, "profile", typedProfile{Nickname: "alice", Level: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.SetAs(sess, "visits", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	loaded := session.NewSession(ctx, store, (&session.DefaultData{}).SetToken(sess.Token()))
+	if _, ok := loaded.Get("visits").(float64); !ok {
+		t.Fatalf("预期JSON往返后为float64, got %T", loaded.Get("visits"))
+	}
+	visits, err := session.GetAs[int](loaded, "visits")
+	if err != nil || visits != 7 {
+		t.Fatalf("GetAs[int] = %d, %v", visits, err)
+	}
+	profile, err := session.GetAs[typedProfile](loaded, "profile")
+	if err != nil || profile.Nickname != "alice" || profile.Level != 3 {
+		t.Fatalf("GetAs[typedProfile] = %+v, %v", profile, err)
+	}
+	// 解码结果写回session
+	if _, ok := loaded.Get("profile").(typedProfile); !ok {
+		t.Fatalf("解码结果未缓存, got %T", loaded.Get("profile"))
+	}
+
+	if _, err := session.GetAs[string](loaded, "missing"); !errors.Is(err, session.ErrItemNotFound) {
+		t.Fatalf("缺失key应返回 ErrItemNotFound, got %v", err)
+	}
+	if _, err := session.GetAs[int](loaded, "profile"); !errors.Is(err, session.ErrItemType) {
+		t.Fatalf("类型不匹配应返回 ErrItemType, got %v", err)
+	}
+	if got := session.GetOr(loaded, "missing", "default"); got != "default" {
+		t.Fatalf("GetOr = %q", got)
+	}
+}
+
+func TestSetAsValidatesRegisteredItems(t *testing.T) {
+	sess := session.NewSession(context.Background(), session.NewMemStore(), &session.DefaultData{})
+	if err := session.SetAs(sess, "typed.level", 2); err != nil {
+		t.Fatalf("合法值被拒绝: %v", err)
+	}
+	if err := session.SetAs(sess, "typed.level", -1); err == nil {
+		t.Fatal("校验函数未生效")
+	}
+	if err := session.SetAs(sess, "typed.level", "2"); !errors.Is(err, session.ErrItemType) {
+		t.Fatalf("注册类型不符应返回 ErrItemType, got %v", err)
+	}
+	sess.Set("typed.level", "3")
+	sess.Set("typed.level", -3)
+	if got := sess.Get("typed.level"); got != 2 {
+		t.Fatalf("非法写入覆盖了原值: %v", got)
+	}
+	sess.Set("typed.level", 4)
+	if got := sess.Get("typed.level"); got != 4 {
+		t.Fatalf("Set 未写入合法值: %v", got)
+	}
+	if _, err := session.GetAs[string](sess, "typed.level"); !errors.Is(err, session.ErrItemType) {
+		t.Fatalf("读取类型与注册不符应返回 ErrItemType, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("重复注册应 panic")
+		}
+	}()
+	session.RegisterItem[int]("typed.level")
+}